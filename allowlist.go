@@ -0,0 +1,212 @@
+package libinjection
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+/*
+ * AllowRule turns down SQLi matches of a known false positive. Fingerprint
+ * is required, the other fields are optional and narrow the rule down.
+ */
+type AllowRule struct {
+	Fingerprint string
+	Param       string /* glob of the parameter name, path.Match syntax */
+	Route       string /* glob of the route, e.g. "/products/*" */
+	Value       string /* regular expression the whole value must match */
+}
+
+/*
+ * String formats r as a line of Load.
+ */
+func (r AllowRule) String() string {
+	param, route := r.Param, r.Route
+	if param == "" {
+		param = "*"
+	}
+	if route == "" {
+		route = "*"
+	}
+	fields := []string{r.Fingerprint, param, route}
+	if r.Value != "" {
+		fields = append(fields, r.Value)
+	}
+	return strings.Join(fields, "\t")
+}
+
+/* the types a fingerprint is made of */
+const sqliTokenTypes = "kUBEtfn1vsoc&A(){}.,:;T?X\\"
+
+type allowRule struct {
+	AllowRule
+	value      *regexp.Regexp
+	suppressed int64 /* atomic */
+}
+
+func (r *allowRule) match(param, route, value string) bool {
+	if r.Param != "" {
+		if ok, _ := path.Match(r.Param, param); !ok {
+			return false
+		}
+	}
+	if r.Route != "" {
+		if ok, _ := path.Match(r.Route, route); !ok {
+			return false
+		}
+	}
+	return r.value == nil || r.value.MatchString(value)
+}
+
+/*
+ * AllowCount is the number of hits a rule suppressed.
+ */
+type AllowCount struct {
+	Rule       AllowRule
+	Suppressed int64
+}
+
+/*
+ * Allowlist holds rules for known false positives, such as product names
+ * like "O'Reilly & Sons" in a search box. A rule is applied after
+ * libinjection_sqli_check_fingerprint matched a pass: an allowed match
+ * doesn't end the search, the next passes still run, so a real injection
+ * in the same value is still caught.
+ *
+ * Rules are added before use, after that an Allowlist is safe for
+ * concurrent use.
+ */
+type Allowlist struct {
+	rules map[string][]*allowRule /* by fingerprint */
+	order []*allowRule
+}
+
+/*
+ * NewAllowlist returns an allowlist of rules.
+ */
+func NewAllowlist(rules ...AllowRule) (*Allowlist, error) {
+	a := &Allowlist{rules: map[string][]*allowRule{}}
+	for _, rule := range rules {
+		if err := a.Add(rule); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+/*
+ * Add appends a rule, rules are tried in the order they were added.
+ */
+func (a *Allowlist) Add(rule AllowRule) error {
+	if rule.Fingerprint == "" {
+		return fmt.Errorf("allow rule %q: no fingerprint", rule)
+	}
+	if len(rule.Fingerprint) > LIBINJECTION_SQLI_MAX_TOKENS {
+		return fmt.Errorf("allow rule %q: fingerprint longer than %d tokens", rule, LIBINJECTION_SQLI_MAX_TOKENS)
+	}
+	/* the case of a type is meaningful, 't' is a SQL type and 'T' TSQL */
+	for i := 0; i < len(rule.Fingerprint); i++ {
+		if strings.IndexByte(sqliTokenTypes, rule.Fingerprint[i]) == -1 {
+			return fmt.Errorf("allow rule %q: %q is not a token type, fingerprints are case sensitive", rule, rule.Fingerprint[i])
+		}
+	}
+	for _, glob := range []string{rule.Param, rule.Route} {
+		if _, err := path.Match(glob, ""); err != nil {
+			return fmt.Errorf("allow rule %q: %q: %v", rule, glob, err)
+		}
+	}
+	r := &allowRule{AllowRule: rule}
+	if rule.Value != "" {
+		var err error
+		if r.value, err = regexp.Compile("^(?:" + rule.Value + ")$"); err != nil {
+			return fmt.Errorf("allow rule %q: %v", rule, err)
+		}
+	}
+	a.rules[rule.Fingerprint] = append(a.rules[rule.Fingerprint], r)
+	a.order = append(a.order, r)
+	return nil
+}
+
+/*
+ * Load adds rules, one per line, fields separated by tabs:
+ *
+ *	fingerprint  param  route  [value]
+ *
+ * "*" for param or route matches anything. Empty lines and lines
+ * starting with '#' are ignored.
+ */
+func (a *Allowlist) Load(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	lineno := 0
+	for scanner.Scan() {
+		lineno++
+		line := scanner.Text()
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 && len(fields) != 4 {
+			return fmt.Errorf("line %d: expected 3 or 4 fields, got %d", lineno, len(fields))
+		}
+		rule := AllowRule{Fingerprint: strings.TrimSpace(fields[0]), Param: fields[1], Route: fields[2]}
+		if len(fields) == 4 {
+			rule.Value = fields[3]
+		}
+		if rule.Param == "*" {
+			rule.Param = ""
+		}
+		if rule.Route == "*" {
+			rule.Route = ""
+		}
+		if err := a.Add(rule); err != nil {
+			return fmt.Errorf("line %d: %v", lineno, err)
+		}
+	}
+	return scanner.Err()
+}
+
+/*
+ * DetectSQLi is DetectSQLi for the value of parameter param on route.
+ * When a match is allowed and no later pass matches, the input is benign
+ * and the first rule that allowed it counts a suppressed hit.
+ */
+func (a *Allowlist) DetectSQLi(input, param, route string) Result {
+	var suppressed *allowRule
+	sqli := &Sqli{state: newState(input, len(input), 0)}
+	sqli.allow = func(fingerprint string) bool {
+		for _, r := range a.rules[fingerprint] {
+			if r.match(param, route, input) {
+				if suppressed == nil {
+					suppressed = r
+				}
+				return true
+			}
+		}
+		return false
+	}
+	injection := sqli.libinjection_is_sqli()
+	if !injection && suppressed != nil {
+		atomic.AddInt64(&suppressed.suppressed, 1)
+	}
+	return Result{
+		Injection:   injection,
+		Fingerprint: sqli.state.fingerprint,
+		Flags:       sqli.state.flags,
+	}
+}
+
+/*
+ * Counts returns every rule with the hits it suppressed, in the order the
+ * rules were added.
+ */
+func (a *Allowlist) Counts() []AllowCount {
+	counts := make([]AllowCount, len(a.order))
+	for i, r := range a.order {
+		counts[i] = AllowCount{Rule: r.AllowRule, Suppressed: atomic.LoadInt64(&r.suppressed)}
+	}
+	return counts
+}
@@ -0,0 +1,104 @@
+package libinjection
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAllowlist(t *testing.T) {
+	allowlist, err := NewAllowlist(
+		AllowRule{Fingerprint: "1&1", Param: "q", Route: "/search", Value: `-?\d+ or \d+`},
+		AllowRule{Fingerprint: "v&1", Param: "filter*", Route: "/products/*"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input, param, route string
+		injection           bool
+	}{
+		{"-1 or 2", "q", "/search", false},
+		{"-1 or 2", "q", "/login", true},
+		{"-1 or 2", "name", "/search", true},
+		/* the value must match as a whole */
+		{"-1 or 2 or 3", "q", "/search", true},
+		/* other fingerprints are still caught */
+		{"1 UNION SELECT 1", "q", "/search", true},
+		{"null or 1", "filter[name]", "/products/shoes", false},
+		{"null or 1", "filter", "/products/shoes/red", true},
+		/* the single quote pass still runs after an allowed match */
+		{"null or 1' OR '1'='1", "filter", "/products/shoes", true},
+	}
+	for _, test := range tests {
+		result := allowlist.DetectSQLi(test.input, test.param, test.route)
+		if result.Injection != test.injection {
+			t.Errorf("%q, %s on %s: expected %v, got %+v", test.input, test.param, test.route, test.injection, result)
+		}
+	}
+
+	counts := allowlist.Counts()
+	if len(counts) != 2 || counts[0].Suppressed != 1 || counts[1].Suppressed != 1 {
+		t.Errorf("unexpected counts %+v", counts)
+	}
+	if counts[0].Rule.Value != `-?\d+ or \d+` {
+		t.Errorf("unexpected rule %+v", counts[0].Rule)
+	}
+
+	/* the case of a type is meaningful: 't' is a SQL type, 'T' TSQL */
+	sqltype, _ := NewAllowlist(AllowRule{Fingerprint: "1;tn("})
+	if result := sqltype.DetectSQLi("1; exec xp_cmdshell('dir')", "q", "/search"); !result.Injection || result.Fingerprint != "1;Tn(" {
+		t.Errorf("a rule for 1;tn( should not allow 1;Tn(: %+v", result)
+	}
+	tsql, _ := NewAllowlist(AllowRule{Fingerprint: "1;Tn("})
+	if result := tsql.DetectSQLi("1; exec xp_cmdshell('dir')", "q", "/search"); result.Injection {
+		t.Errorf("expected the rule to allow it, got %+v", result)
+	}
+	for _, fingerprint := range []string{"1ue1", "1UE1x", "1UE1 ", "1UEEEE"} {
+		if _, err := NewAllowlist(AllowRule{Fingerprint: fingerprint}); err == nil {
+			t.Errorf("%q: expected an error", fingerprint)
+		}
+	}
+
+	/* without rules the allowlist is DetectSQLi */
+	empty, _ := NewAllowlist()
+	if result := empty.DetectSQLi("-1 or 2", "q", "/search"); result != DetectSQLi("-1 or 2") {
+		t.Errorf("unexpected %+v", result)
+	}
+}
+
+func TestAllowlistLoad(t *testing.T) {
+	rules := "# search box\n" +
+		"1&1\tq\t/search\t-?\\d+ or \\d+\n" +
+		"\n" +
+		"v&1\t*\t*\n"
+	allowlist, _ := NewAllowlist()
+	if err := allowlist.Load(strings.NewReader(rules)); err != nil {
+		t.Fatal(err)
+	}
+	counts := allowlist.Counts()
+	if len(counts) != 2 || counts[1].Rule != (AllowRule{Fingerprint: "v&1"}) {
+		t.Fatalf("unexpected rules %+v", counts)
+	}
+	var lines []string
+	for _, c := range counts {
+		lines = append(lines, c.Rule.String())
+	}
+	if got := strings.Join(lines, "\n"); got != "1&1\tq\t/search\t-?\\d+ or \\d+\nv&1\t*\t*" {
+		t.Errorf("unexpected rules %q", got)
+	}
+	if result := allowlist.DetectSQLi("null or 1", "id", "/"); result.Injection {
+		t.Errorf("expected the match to be allowed anywhere, got %+v", result)
+	}
+
+	for _, rules := range []string{
+		"1&1\tq\n",
+		"\tq\t/search\n",
+		"1&1\t[\t/search\n",
+		"1&1\tq\t/search\t(\n",
+	} {
+		if err := allowlist.Load(strings.NewReader(rules)); err == nil {
+			t.Errorf("%q: expected an error", rules)
+		}
+	}
+}
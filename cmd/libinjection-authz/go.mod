@@ -0,0 +1,21 @@
+module github.com/jptosso/libinjection-go/cmd/libinjection-authz
+
+go 1.24.0
+
+require (
+	github.com/envoyproxy/go-control-plane/envoy v1.37.0
+	github.com/jptosso/libinjection-go/extauthz v0.1.0
+	google.golang.org/grpc v1.79.3
+)
+
+require (
+	github.com/cncf/xds/go v0.0.0-20251210132809-ee656c7534f5 // indirect
+	github.com/envoyproxy/protoc-gen-validate v1.3.0 // indirect
+	github.com/jptosso/libinjection-go v0.1.0 // indirect
+	github.com/planetscale/vtprotobuf v0.6.1-0.20240319094008-0393e58bdf10 // indirect
+	golang.org/x/net v0.48.0 // indirect
+	golang.org/x/sys v0.39.0 // indirect
+	golang.org/x/text v0.32.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20251202230838-ff82c1b0f217 // indirect
+	google.golang.org/protobuf v1.36.12 // indirect
+)
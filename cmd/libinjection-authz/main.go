@@ -0,0 +1,107 @@
+/*
+ * Command libinjection-authz is a sidecar WAF for Envoy: a gRPC server
+ * implementing the ext_authz and ext_proc APIs that denies requests with
+ * SQLi or XSS in their path, query, headers, cookies or body.
+ *
+ * Point either filter at it, for example:
+ *
+ *	http_filters:
+ *	- name: envoy.filters.http.ext_authz
+ *	  typed_config:
+ *	    "@type": type.googleapis.com/envoy.extensions.filters.http.ext_authz.v3.ExtAuthz
+ *	    transport_api_version: V3
+ *	    with_request_body: {max_request_bytes: 65536, allow_partial_message: true}
+ *	    grpc_service: {envoy_grpc: {cluster_name: libinjection}}
+ *
+ * Denied requests get a 403 with the x-libinjection-type and
+ * x-libinjection-fingerprint headers. With -mode log requests go through,
+ * flagged ones with those headers set for the upstream. Findings are
+ * logged in both modes. The standard gRPC health service is served too.
+ *
+ * It shuts down gracefully on SIGINT and SIGTERM.
+ */
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/jptosso/libinjection-go/extauthz"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:9191", "listen `address`")
+	modeName := flag.String("mode", "reject", "what to do with flagged requests: reject or log")
+	maxBody := flag.Int("max-body", 1<<20, "maximum request body checked through ext_proc, in `bytes`")
+	grace := flag.Duration("shutdown-timeout", 10*time.Second, "how long to wait for in flight calls on shutdown")
+	flag.Parse()
+
+	var mode extauthz.Mode
+	switch *modeName {
+	case "reject":
+		mode = extauthz.ModeReject
+	case "log":
+		mode = extauthz.ModeLog
+	default:
+		log.Fatalf("-mode must be reject or log, got %q", *modeName)
+	}
+
+	s := extauthz.NewServer(mode, func(ctx context.Context, f extauthz.Finding) {
+		log.Printf("%s %s %q: %s %s %q", f.Method, f.Path, f.Location, f.Type, f.Fingerprint, f.Value)
+	})
+	s.SetMaxBody(*maxBody)
+
+	srv := grpc.NewServer()
+	authv3.RegisterAuthorizationServer(srv, s)
+	extprocv3.RegisterExternalProcessorServer(srv, s)
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+
+	lis, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errc := make(chan error, 1)
+	go func() {
+		log.Printf("libinjection-authz listening on %s", lis.Addr())
+		errc <- srv.Serve(lis)
+	}()
+
+	select {
+	case err := <-errc:
+		log.Fatal(err)
+	case <-ctx.Done():
+	}
+	stop()
+
+	log.Print("shutting down")
+	done := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(*grace):
+		/* ext_proc streams live as long as their request */
+		srv.Stop()
+	}
+	if err := <-errc; err != nil {
+		log.Fatal(err)
+	}
+}
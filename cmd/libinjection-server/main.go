@@ -0,0 +1,69 @@
+/*
+ * Command libinjection-server exposes the detectors as a JSON HTTP service,
+ * for services that can't embed this package.
+ *
+ *	POST /v1/sqli   {"input": "1' OR 1=1--"}
+ *	POST /v1/xss    {"input": "<script>"}
+ *	POST /v1/batch  {"items": [{"type": "sqli", "input": "..."}, ...]}
+ *	GET  /metrics   Prometheus metrics
+ *	GET  /healthz
+ *
+ * It shuts down gracefully on SIGINT and SIGTERM: in flight requests are
+ * answered, new connections are refused.
+ */
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:8089", "listen `address`")
+	maxBody := flag.Int64("max-body", 1<<20, "maximum request body size in `bytes`")
+	maxBatch := flag.Int("max-batch", 1000, "maximum number of `items` in a batch")
+	grace := flag.Duration("shutdown-timeout", 10*time.Second, "how long to wait for in flight requests on shutdown")
+	flag.Parse()
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           newServer(config{maxBody: *maxBody, maxBatch: *maxBatch}),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errc := make(chan error, 1)
+	go func() {
+		log.Printf("libinjection-server listening on %s", *addr)
+		errc <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		log.Fatal(err)
+	case <-ctx.Done():
+	}
+	stop()
+
+	log.Print("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *grace)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Fatalf("shutdown: %v", err)
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+}
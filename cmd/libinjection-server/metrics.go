@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+ * Upper bounds of the request duration histogram, in seconds. Detection
+ * takes microseconds, most of the time is JSON and HTTP.
+ */
+var durationBuckets = []float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 1}
+
+type histogram struct {
+	counts []uint64 /* per bucket, not cumulative */
+	sum    float64
+	count  uint64
+}
+
+/*
+ * metrics is the few counters the server exports, in the Prometheus text
+ * format. Label values are fixed strings chosen by the server, they are
+ * never taken from requests.
+ */
+type metrics struct {
+	mu         sync.Mutex
+	requests   map[[2]string]uint64 /* path, code */
+	inputs     map[string]uint64    /* detector */
+	detections map[string]uint64    /* detector */
+	durations  map[string]*histogram
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		requests:   map[[2]string]uint64{},
+		inputs:     map[string]uint64{},
+		detections: map[string]uint64{},
+		durations:  map[string]*histogram{},
+	}
+}
+
+func (m *metrics) request(path string, code int, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[[2]string{path, strconv.Itoa(code)}]++
+
+	h := m.durations[path]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(durationBuckets))}
+		m.durations[path] = h
+	}
+	s := d.Seconds()
+	for i, le := range durationBuckets {
+		if s <= le {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += s
+	h.count++
+}
+
+func (m *metrics) input(detector string, injection bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inputs[detector]++
+	if injection {
+		m.detections[detector]++
+	}
+}
+
+func sortedNames(m map[string]uint64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+/*
+ * sortedPaths sorts request keys by path, then status code.
+ */
+func sortedPaths(m map[[2]string]uint64) [][2]string {
+	keys := make([][2]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i][0] < keys[j][0] || (keys[i][0] == keys[j][0] && keys[i][1] < keys[j][1])
+	})
+	return keys
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func (m *metrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP libinjection_http_requests_total HTTP requests by path and status code.")
+	fmt.Fprintln(w, "# TYPE libinjection_http_requests_total counter")
+	for _, k := range sortedPaths(m.requests) {
+		fmt.Fprintf(w, "libinjection_http_requests_total{path=%q,code=%q} %d\n", k[0], k[1], m.requests[k])
+	}
+
+	fmt.Fprintln(w, "# HELP libinjection_inputs_total Inputs checked by detector.")
+	fmt.Fprintln(w, "# TYPE libinjection_inputs_total counter")
+	for _, k := range sortedNames(m.inputs) {
+		fmt.Fprintf(w, "libinjection_inputs_total{detector=%q} %d\n", k, m.inputs[k])
+	}
+
+	fmt.Fprintln(w, "# HELP libinjection_detections_total Inputs found to be injections by detector.")
+	fmt.Fprintln(w, "# TYPE libinjection_detections_total counter")
+	for _, k := range sortedNames(m.inputs) {
+		fmt.Fprintf(w, "libinjection_detections_total{detector=%q} %d\n", k, m.detections[k])
+	}
+
+	fmt.Fprintln(w, "# HELP libinjection_http_request_duration_seconds HTTP request latency by path.")
+	fmt.Fprintln(w, "# TYPE libinjection_http_request_duration_seconds histogram")
+	paths := make([]string, 0, len(m.durations))
+	for path := range m.durations {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		h := m.durations[path]
+		var cumulative uint64
+		for i, le := range durationBuckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(w, "libinjection_http_request_duration_seconds_bucket{path=%q,le=%q} %d\n", path, formatFloat(le), cumulative)
+		}
+		fmt.Fprintf(w, "libinjection_http_request_duration_seconds_bucket{path=%q,le=\"+Inf\"} %d\n", path, h.count)
+		fmt.Fprintf(w, "libinjection_http_request_duration_seconds_sum{path=%q} %s\n", path, formatFloat(h.sum))
+		fmt.Fprintf(w, "libinjection_http_request_duration_seconds_count{path=%q} %d\n", path, h.count)
+	}
+}
+
+func (m *metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var b strings.Builder
+	m.write(&b)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	io.WriteString(w, b.String())
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	libinjection "github.com/jptosso/libinjection-go"
+)
+
+/*
+ * checkRequest is the body of /v1/sqli and /v1/xss, and an item of
+ * /v1/batch, where Type picks the detector.
+ */
+type checkRequest struct {
+	Type  string `json:"type,omitempty"`
+	Input string `json:"input"`
+}
+
+/*
+ * checkResult is the structured result returned for every input. Pass is
+ * the SQLi pass ("single/ansi") or the html5 context ("attr-double") that
+ * decided it. Benign SQLi input still gets the fingerprint and pass of the
+ * last pass tried.
+ */
+type checkResult struct {
+	Type        string `json:"type"`
+	Injection   bool   `json:"injection"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Pass        string `json:"pass,omitempty"`
+}
+
+type batchRequest struct {
+	Items []checkRequest `json:"items"`
+}
+
+type batchResponse struct {
+	Results []checkResult `json:"results"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+type config struct {
+	maxBody  int64 /* bytes */
+	maxBatch int   /* items */
+}
+
+type server struct {
+	config
+	metrics *metrics
+}
+
+/*
+ * newServer returns the handler of every endpoint.
+ */
+func newServer(c config) http.Handler {
+	s := &server{config: c, metrics: newMetrics()}
+	mux := http.NewServeMux()
+	mux.Handle("/v1/sqli", s.instrument("/v1/sqli", s.detector("sqli")))
+	mux.Handle("/v1/xss", s.instrument("/v1/xss", s.detector("xss")))
+	mux.Handle("/v1/batch", s.instrument("/v1/batch", http.HandlerFunc(s.batch)))
+	mux.Handle("/metrics", s.metrics)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	return mux
+}
+
+/*
+ * check runs the detector of req.Type, which must be "sqli" or "xss".
+ */
+func (s *server) check(req checkRequest) checkResult {
+	if req.Type == "xss" {
+		result := libinjection.DetectXSS(req.Input)
+		s.metrics.input("xss", result.Injection)
+		r := checkResult{Type: "xss", Injection: result.Injection}
+		if result.Injection {
+			r.Pass = libinjection.XSSFlagsString(result.Flags)
+		}
+		return r
+	}
+
+	result := libinjection.DetectSQLi(req.Input)
+	s.metrics.input("sqli", result.Injection)
+	r := checkResult{Type: "sqli", Injection: result.Injection, Fingerprint: result.Fingerprint}
+	if req.Input != "" {
+		r.Pass = libinjection.SQLiFlagsString(result.Flags)
+	}
+	return r
+}
+
+/*
+ * statusRecorder keeps the status code for the metrics.
+ */
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (s *server) instrument(path string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		s.metrics.request(path, rec.code, time.Since(start))
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, errorResponse{Error: err.Error()})
+}
+
+/*
+ * decode reads the JSON body of a POST, answering the request itself when
+ * it can't.
+ */
+func (s *server) decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, errors.New("only POST is allowed"))
+		return false
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, s.maxBody+1))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("reading body: %v", err))
+		return false
+	}
+	if int64(len(body)) > s.maxBody {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("body larger than %d bytes", s.maxBody))
+		return false
+	}
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid JSON body: %v", err))
+		return false
+	}
+	return true
+}
+
+func (s *server) detector(typ string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req checkRequest
+		if !s.decode(w, r, &req) {
+			return
+		}
+		if req.Type != "" && req.Type != typ {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("type %q sent to the %s endpoint", req.Type, typ))
+			return
+		}
+		req.Type = typ
+		writeJSON(w, http.StatusOK, s.check(req))
+	})
+}
+
+func (s *server) batch(w http.ResponseWriter, r *http.Request) {
+	var req batchRequest
+	if !s.decode(w, r, &req) {
+		return
+	}
+	if len(req.Items) > s.maxBatch {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("more than %d items", s.maxBatch))
+		return
+	}
+	/* validate everything first, so metrics only count answered batches */
+	for i, item := range req.Items {
+		if item.Type != "sqli" && item.Type != "xss" {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("item %d: type must be sqli or xss, got %q", i, item.Type))
+			return
+		}
+	}
+
+	resp := batchResponse{Results: make([]checkResult, len(req.Items))}
+	for i, item := range req.Items {
+		resp.Results[i] = s.check(item)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
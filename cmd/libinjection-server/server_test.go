@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func post(t *testing.T, srv *httptest.Server, path, body string) (int, string) {
+	t.Helper()
+	resp, err := http.Post(srv.URL+path, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(b)
+}
+
+func TestServer(t *testing.T) {
+	srv := httptest.NewServer(newServer(config{maxBody: 1024, maxBatch: 3}))
+	defer srv.Close()
+
+	code, body := post(t, srv, "/v1/sqli", `{"input": "admin' OR 1=1--"}`)
+	var result checkResult
+	if err := json.Unmarshal([]byte(body), &result); err != nil || code != http.StatusOK {
+		t.Fatalf("%d %s", code, body)
+	}
+	if result != (checkResult{Type: "sqli", Injection: true, Fingerprint: "s&1c", Pass: "single/ansi"}) {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	code, body = post(t, srv, "/v1/xss", `{"input": "<img src=x onerror=alert(1)>"}`)
+	result = checkResult{}
+	if err := json.Unmarshal([]byte(body), &result); err != nil || code != http.StatusOK {
+		t.Fatalf("%d %s", code, body)
+	}
+	if result != (checkResult{Type: "xss", Injection: true, Pass: "data"}) {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	code, body = post(t, srv, "/v1/batch", `{"items": [{"type": "sqli", "input": "hello"}, {"type": "xss", "input": "hello"}, {"type": "sqli", "input": "1 UNION SELECT 1"}]}`)
+	var batch batchResponse
+	if err := json.Unmarshal([]byte(body), &batch); err != nil || code != http.StatusOK {
+		t.Fatalf("%d %s", code, body)
+	}
+	if len(batch.Results) != 3 || batch.Results[0].Injection || batch.Results[1].Injection || batch.Results[1].Type != "xss" ||
+		!batch.Results[2].Injection || batch.Results[2].Fingerprint != "1UE1" {
+		t.Errorf("unexpected batch %+v", batch)
+	}
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	for _, line := range []string{
+		`libinjection_http_requests_total{path="/v1/sqli",code="200"} 1`,
+		`libinjection_inputs_total{detector="sqli"} 3`,
+		`libinjection_detections_total{detector="sqli"} 2`,
+		`libinjection_detections_total{detector="xss"} 1`,
+		`libinjection_http_request_duration_seconds_count{path="/v1/batch"} 1`,
+	} {
+		if !strings.Contains(string(metrics), line+"\n") {
+			t.Errorf("missing %q in:\n%s", line, metrics)
+		}
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	srv := httptest.NewServer(newServer(config{maxBody: 64, maxBatch: 2}))
+	defer srv.Close()
+
+	tests := []struct {
+		path, body string
+		code       int
+	}{
+		{"/v1/sqli", `{"input": `, http.StatusBadRequest},
+		{"/v1/sqli", `{"type": "xss", "input": "x"}`, http.StatusBadRequest},
+		{"/v1/sqli", `{"input": "` + strings.Repeat("a", 100) + `"}`, http.StatusRequestEntityTooLarge},
+		{"/v1/batch", `{"items": [{"input": "x"}]}`, http.StatusBadRequest},
+		{"/v1/batch", `{"items": [{"type": "sqli"}, {"type": "sqli"}, {"type": "sqli"}]}`, http.StatusRequestEntityTooLarge},
+	}
+	for _, test := range tests {
+		if code, body := post(t, srv, test.path, test.body); code != test.code || !strings.Contains(body, `"error"`) {
+			t.Errorf("%s %s: expected %d, got %d %s", test.path, test.body, test.code, code, body)
+		}
+	}
+
+	resp, err := http.Get(srv.URL + "/v1/sqli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/jptosso/libinjection-go/httpparams"
+)
+
+/*
+ * A decoder undoes one layer of encoding. Decoding never fails: whatever
+ * can't be decoded is kept as is, since attackers send broken encodings on
+ * purpose.
+ */
+type decoder func(string) string
+
+var decoders = map[string]decoder{
+	"url":  httpparams.URLDecode,
+	"path": httpparams.PathDecode,
+	"html": html.UnescapeString,
+}
+
+/*
+ * parseDecoders parses a comma separated list of decoders, applied in
+ * order. "none" or "" is no decoding.
+ */
+func parseDecoders(list string) ([]decoder, error) {
+	var ds []decoder
+	if list == "" || list == "none" {
+		return ds, nil
+	}
+	for _, name := range strings.Split(list, ",") {
+		d, ok := decoders[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown decoder %q", name)
+		}
+		ds = append(ds, d)
+	}
+	return ds, nil
+}
+
+func decode(s string, ds []decoder) string {
+	for _, d := range ds {
+		s = d(s)
+	}
+	return s
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jptosso/libinjection-go/differential"
+)
+
+/*
+ * diffResult is one divergence in the JSON lines format.
+ */
+type diffResult struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Category string `json:"category"`
+	Flags    string `json:"flags"`
+	Input    string `json:"input"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+/*
+ * diffCorpus runs one corpus, gzip compressed if name ends in .gz.
+ */
+func diffCorpus(name string, r io.Reader, report func(differential.Divergence)) (differential.Stats, error) {
+	if strings.HasSuffix(name, ".gz") {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return differential.Stats{}, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	return differential.Run(r, report)
+}
+
+func runDiff(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: libinjection diff [flags] [corpus ...]")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Compares this port with corpora recorded from the C libinjection,")
+		fmt.Fprintln(stderr, "or stdin, and lists every divergence by category: tokenizer, fold")
+		fmt.Fprintln(stderr, "or blacklist. Corpora ending in .gz are decompressed.")
+		fmt.Fprintln(stderr)
+		fs.PrintDefaults()
+	}
+	format := fs.String("format", "text", "output `format`: text or json (JSON lines)")
+	quiet := fs.Bool("q", false, "only print the totals")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "libinjection diff: unknown format %q\n", *format)
+		return 2
+	}
+
+	enc := json.NewEncoder(stdout)
+	enc.SetEscapeHTML(false)
+	total := differential.Stats{Divergences: map[string]int{}}
+	status := 0
+	diff := func(name string, r io.Reader) {
+		stats, err := diffCorpus(name, r, func(d differential.Divergence) {
+			if *quiet {
+				return
+			}
+			flags := "*"
+			if d.Flags != differential.AllPasses {
+				flags = strconv.Itoa(d.Flags)
+			}
+			if *format == "json" {
+				enc.Encode(&diffResult{name, d.Line, d.Category, flags, d.Input, d.Expected, d.Actual})
+				return
+			}
+			fmt.Fprintf(stdout, "%s:%d: %s: flags %s, input %q: expected %q, got %q\n",
+				name, d.Line, d.Category, flags, d.Input, d.Expected, d.Actual)
+		})
+		total.Records += stats.Records
+		for category, n := range stats.Divergences {
+			total.Divergences[category] += n
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "libinjection diff: %s: %v\n", name, err)
+			status = 2
+		}
+	}
+
+	if fs.NArg() == 0 {
+		diff("-", stdin)
+	}
+	for _, name := range fs.Args() {
+		f, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintln(stderr, "libinjection diff:", err)
+			status = 2
+			continue
+		}
+		diff(name, f)
+		f.Close()
+	}
+
+	if *format == "text" {
+		fmt.Fprintf(stdout, "%d records, %d divergences", total.Records, total.Total())
+		for _, category := range []string{differential.Tokenizer, differential.Fold, differential.Blacklist} {
+			fmt.Fprintf(stdout, ", %s %d", category, total.Divergences[category])
+		}
+		fmt.Fprintln(stdout)
+	}
+	if status == 0 && total.Total() > 0 {
+		status = 1
+	}
+	return status
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	status := run([]string{"diff", "-q", "../../differential/testdata/tests.tsv"}, nil, &stdout, &stderr)
+	if status != 0 {
+		t.Fatalf("expected status 0, got %d: %s%s", status, stdout.String(), stderr.String())
+	}
+
+	/* 1 union select 1, recorded wrong at every stage */
+	corpus := "# comment\n" +
+		"9\t3120756e696f6e2073656c6563742031\t1UE1\t1UE1\t1UE1\t1\n" +
+		"9\t3120756e696f6e2073656c6563742031\t1UEn\t-\t-\t-\n" +
+		"9\t3120756e696f6e2073656c6563742031\t-\t1UE\t-\t-\n" +
+		"*\t3120756e696f6e2073656c6563742031\t-\t-\t1UE1\t0\n"
+	stdout.Reset()
+	status = run([]string{"diff"}, strings.NewReader(corpus), &stdout, &stderr)
+	if status != 1 {
+		t.Fatalf("expected status 1, got %d: %s", status, stderr.String())
+	}
+	if !strings.HasSuffix(stdout.String(), "4 records, 3 divergences, tokenizer 1, fold 1, blacklist 1\n") {
+		t.Errorf("unexpected output %q", stdout.String())
+	}
+
+	stdout.Reset()
+	run([]string{"diff", "-format", "json"}, strings.NewReader(corpus), &stdout, &stderr)
+	var categories []string
+	dec := json.NewDecoder(&stdout)
+	for dec.More() {
+		var r diffResult
+		if err := dec.Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		categories = append(categories, r.Category)
+		if r.Input != "1 union select 1" {
+			t.Errorf("unexpected input %q", r.Input)
+		}
+	}
+	if strings.Join(categories, ",") != "tokenizer,fold,blacklist" {
+		t.Errorf("unexpected divergences %v", categories)
+	}
+}
@@ -0,0 +1,522 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jptosso/libinjection-go/httpparams"
+	"github.com/jptosso/libinjection-go/internal/strutil"
+)
+
+/*
+ * logRequest is what logscan needs from one access log line.
+ */
+type logRequest struct {
+	ip     string
+	time   string
+	method string
+	target string /* request URI: path and query */
+}
+
+var errUnparsed = errors.New("not an access log line")
+
+/*
+ * parseCLF parses the common and combined log formats:
+ *
+ *	host ident user [time] "request" status bytes ["referer" "user-agent"]
+ *
+ * Only the fields up to the request are needed.
+ */
+func parseCLF(line string) (logRequest, error) {
+	var r logRequest
+
+	fields := strings.SplitN(line, " ", 4)
+	if len(fields) < 4 {
+		return r, errUnparsed
+	}
+	r.ip = fields[0]
+	rest := fields[3]
+
+	if !strings.HasPrefix(rest, "[") {
+		return r, errUnparsed
+	}
+	end := strings.IndexByte(rest, ']')
+	if end == -1 {
+		return r, errUnparsed
+	}
+	r.time = rest[1:end]
+	rest = strings.TrimLeft(rest[end+1:], " ")
+
+	if !strings.HasPrefix(rest, "\"") {
+		return r, errUnparsed
+	}
+	request, ok := quotedField(rest)
+	if !ok {
+		return r, errUnparsed
+	}
+	r.method, r.target = splitRequestLine(request)
+	return r, nil
+}
+
+/*
+ * quotedField returns the content of the double quoted field s starts
+ * with. Apache and nginx escape quotes inside as \".
+ */
+func quotedField(s string) (string, bool) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				if s[i] != '"' && s[i] != '\\' {
+					b.WriteByte('\\')
+				}
+				b.WriteByte(s[i])
+			}
+		case '"':
+			return b.String(), true
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", false
+}
+
+/*
+ * splitRequestLine splits "GET /path?q HTTP/1.1" into method and target.
+ * Servers log whatever the client sent, so the target may have spaces and
+ * the protocol may be missing.
+ */
+func splitRequestLine(request string) (string, string) {
+	method, target, found := strutil.Cut(request, " ")
+	if !found {
+		return "", request
+	}
+	if i := strings.LastIndexByte(target, ' '); i != -1 && strings.HasPrefix(target[i+1:], "HTTP/") {
+		target = target[:i]
+	}
+	return method, target
+}
+
+/*
+ * Field names used by common JSON access log formats (nginx log_format
+ * escape=json, Caddy, Traefik, load balancers), in order of preference.
+ */
+var (
+	jsonIPKeys      = []string{"remote_addr", "client_ip", "clientip", "remote_ip", "ip", "ClientHost", "client"}
+	jsonTimeKeys    = []string{"time_local", "time", "timestamp", "@timestamp", "time_iso8601", "ts", "StartUTC"}
+	jsonMethodKeys  = []string{"method", "request_method", "RequestMethod", "verb"}
+	jsonTargetKeys  = []string{"request_uri", "uri", "url", "RequestPath", "path"}
+	jsonQueryKeys   = []string{"args", "query_string", "query", "querystring"}
+	jsonRequestKeys = []string{"request", "request_line"}
+)
+
+func jsonField(m map[string]interface{}, keys []string) string {
+	for _, key := range keys {
+		switch v := m[key].(type) {
+		case string:
+			if v != "" {
+				return v
+			}
+		case float64:
+			return fmt.Sprint(v)
+		}
+	}
+	return ""
+}
+
+/*
+ * parseJSONLog parses one JSON object per line. The request is either a
+ * full request line, or a method and a URI with an optional separate query
+ * string. Fields nested in a "request" object, as Caddy logs them, are
+ * found too.
+ */
+func parseJSONLog(line string) (logRequest, error) {
+	var r logRequest
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &m); err != nil {
+		return r, errUnparsed
+	}
+	if nested, ok := m["request"].(map[string]interface{}); ok {
+		for k, v := range nested {
+			if _, exists := m[k]; !exists {
+				m[k] = v
+			}
+		}
+	}
+
+	r.ip = jsonField(m, jsonIPKeys)
+	r.time = jsonField(m, jsonTimeKeys)
+	r.method = jsonField(m, jsonMethodKeys)
+	r.target = jsonField(m, jsonTargetKeys)
+	if r.target == "" {
+		if request := jsonField(m, jsonRequestKeys); request != "" {
+			method, target := splitRequestLine(request)
+			if r.method == "" {
+				r.method = method
+			}
+			r.target = target
+		}
+	}
+	if r.target == "" {
+		return r, errUnparsed
+	}
+	if query := jsonField(m, jsonQueryKeys); query != "" && !strings.Contains(r.target, "?") {
+		r.target += "?" + query
+	}
+	return r, nil
+}
+
+/*
+ * logHit is one detection. Also the JSON format of -hits.
+ */
+type logHit struct {
+	File        string `json:"file,omitempty"` /* empty for stdin */
+	Line        int    `json:"line"`           /* in the file, blank lines counted */
+	IP          string `json:"ip"`
+	Time        string `json:"time,omitempty"`
+	Endpoint    string `json:"endpoint"`
+	Location    string `json:"location"`
+	Value       string `json:"value"`
+	Type        string `json:"type"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+/*
+ * logSummary aggregates the hits of one IP or endpoint.
+ */
+type logSummary struct {
+	Key          string         `json:"key"`
+	Requests     int            `json:"requests"` /* requests with at least one hit */
+	Hits         int            `json:"hits"`
+	SQLi         int            `json:"sqli"`
+	XSS          int            `json:"xss"`
+	CRLF         int            `json:"crlf"`
+	Fingerprints map[string]int `json:"fingerprints,omitempty"`
+	FirstSeen    string         `json:"first_seen,omitempty"`
+	LastSeen     string         `json:"last_seen,omitempty"`
+}
+
+func (s *logSummary) add(r logRequest, hits []logHit) {
+	s.Requests++
+	if s.FirstSeen == "" {
+		s.FirstSeen = r.time
+	}
+	s.LastSeen = r.time
+	for _, hit := range hits {
+		s.Hits++
+		switch hit.Type {
+		case "sqli":
+			s.SQLi++
+			if s.Fingerprints == nil {
+				s.Fingerprints = map[string]int{}
+			}
+			s.Fingerprints[hit.Fingerprint]++
+		case "xss":
+			s.XSS++
+		case "crlf":
+			s.CRLF++
+		}
+	}
+}
+
+/*
+ * logReport is the logscan output, also its JSON format.
+ */
+type logReport struct {
+	Lines     int           `json:"lines"`
+	Requests  int           `json:"requests"`
+	Unparsed  int           `json:"unparsed"`
+	Flagged   int           `json:"flagged"` /* requests with at least one hit */
+	Hits      int           `json:"hits"`
+	IPs       []*logSummary `json:"ips"`
+	Endpoints []*logSummary `json:"endpoints"`
+	HitList   []logHit      `json:"hit_list,omitempty"`
+}
+
+type logScanner struct {
+	parse     func(string) (logRequest, error)
+	detect    httpparams.Detectors
+	keepHits  bool
+	report    logReport
+	ips       map[string]*logSummary
+	endpoints map[string]*logSummary
+}
+
+func (s *logScanner) check(file string, lineno int, r logRequest) []logHit {
+	var hits []logHit
+	for _, param := range httpparams.Target(r.target) {
+		hit := logHit{
+			File:     file,
+			Line:     lineno,
+			IP:       r.ip,
+			Time:     r.time,
+			Location: param.Location,
+			Value:    param.Value,
+		}
+		if hit.Type, hit.Fingerprint = httpparams.Detect(param.Value, s.detect); hit.Type != "" {
+			hits = append(hits, hit)
+		}
+	}
+	endpoint := strings.TrimSpace(r.method + " " + endpointPath(r.target, hits))
+	for i := range hits {
+		hits[i].Endpoint = endpoint
+	}
+	return hits
+}
+
+/*
+ * endpointPath is the path of target without its query and fragment, and
+ * with "*" for the segments that carry a hit, so that attacks on one
+ * endpoint are counted together whatever their payload.
+ */
+func endpointPath(target string, hits []logHit) string {
+	path, _, _ := strutil.Cut(target, "?")
+	path, _, _ = strutil.Cut(path, "#")
+	/* numbered as httpparams.Target does */
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for _, hit := range hits {
+		if !strings.HasPrefix(hit.Location, "path:") {
+			continue
+		}
+		if i, err := strconv.Atoi(strings.TrimPrefix(hit.Location, "path:")); err == nil && i < len(segments) {
+			segments[i] = "*"
+		}
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+func summary(m map[string]*logSummary, key string) *logSummary {
+	s, ok := m[key]
+	if !ok {
+		s = &logSummary{Key: key}
+		m[key] = s
+	}
+	return s
+}
+
+/*
+ * scan reads the log file named file, "" for stdin.
+ */
+func (s *logScanner) scan(file string, r io.Reader) error {
+	br := bufio.NewReader(r)
+	for lineno := 1; ; lineno++ {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line != "" {
+			s.report.Lines++
+			s.line(file, lineno, line)
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+func (s *logScanner) line(file string, lineno int, line string) {
+	parse := s.parse
+	if parse == nil {
+		parse = parseCLF
+		if strings.HasPrefix(line, "{") {
+			parse = parseJSONLog
+		}
+	}
+	r, err := parse(line)
+	if err != nil {
+		s.report.Unparsed++
+		return
+	}
+	s.report.Requests++
+
+	hits := s.check(file, lineno, r)
+	if len(hits) == 0 {
+		return
+	}
+	s.report.Flagged++
+	s.report.Hits += len(hits)
+	summary(s.ips, r.ip).add(r, hits)
+	summary(s.endpoints, hits[0].Endpoint).add(r, hits)
+	if s.keepHits {
+		s.report.HitList = append(s.report.HitList, hits...)
+	}
+}
+
+/*
+ * sortedSummaries returns the summaries with the most hits first.
+ */
+func sortedSummaries(m map[string]*logSummary) []*logSummary {
+	list := make([]*logSummary, 0, len(m))
+	for _, s := range m {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Hits != list[j].Hits {
+			return list[i].Hits > list[j].Hits
+		}
+		return list[i].Key < list[j].Key
+	})
+	return list
+}
+
+func formatFingerprints(fingerprints map[string]int) string {
+	if len(fingerprints) == 0 {
+		return "-"
+	}
+	keys := make([]string, 0, len(fingerprints))
+	for fp := range fingerprints {
+		keys = append(keys, fp)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if fingerprints[keys[i]] != fingerprints[keys[j]] {
+			return fingerprints[keys[i]] > fingerprints[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	parts := make([]string, len(keys))
+	for i, fp := range keys {
+		parts[i] = fmt.Sprintf("%s:%d", fp, fingerprints[fp])
+	}
+	return strings.Join(parts, " ")
+}
+
+func writeLogReport(w io.Writer, report *logReport) {
+	for _, hit := range report.HitList {
+		fingerprint := hit.Fingerprint
+		if fingerprint == "" {
+			fingerprint = "-"
+		}
+		line := strconv.Itoa(hit.Line)
+		if hit.File != "" {
+			line = hit.File + ":" + line
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%q\n", line, hit.IP, strings.ToUpper(hit.Type), fingerprint, hit.Endpoint, hit.Location, hit.Value)
+	}
+	if len(report.HitList) > 0 {
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintf(w, "lines %d, requests %d, unparsed %d, flagged requests %d, hits %d\n",
+		report.Lines, report.Requests, report.Unparsed, report.Flagged, report.Hits)
+	for _, group := range []struct {
+		title string
+		list  []*logSummary
+	}{{"by ip", report.IPs}, {"by endpoint", report.Endpoints}} {
+		if len(group.list) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s:\n", group.title)
+		for _, s := range group.list {
+			fmt.Fprintf(w, "  %s\trequests %d\thits %d\tsqli %d\txss %d\tcrlf %d\t%s\t%s\t%s\n",
+				s.Key, s.Requests, s.Hits, s.SQLi, s.XSS, s.CRLF, formatFingerprints(s.Fingerprints), s.FirstSeen, s.LastSeen)
+		}
+	}
+}
+
+/*
+ * runLogScan retro-hunts attacks in access logs. Same exit status as scan.
+ */
+func runLogScan(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("logscan", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: libinjection logscan [flags] [file ...]")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Checks every URL-decoded query parameter and path segment of")
+		fmt.Fprintln(stderr, "access logs, or stdin, and prints per-IP and per-endpoint summaries.")
+		fmt.Fprintln(stderr)
+		fs.PrintDefaults()
+	}
+	logFormat := fs.String("log", "auto", "log `format`: auto, clf (common or combined) or json")
+	format := fs.String("format", "text", "output `format`: text or json")
+	detect := fs.String("detect", "sqli,xss,crlf", "comma separated `detectors`: sqli, xss, crlf")
+	hits := fs.Bool("hits", false, "also list every hit")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	s := &logScanner{
+		keepHits:  *hits,
+		ips:       map[string]*logSummary{},
+		endpoints: map[string]*logSummary{},
+	}
+	switch *logFormat {
+	case "auto":
+	case "clf", "common", "combined":
+		s.parse = parseCLF
+	case "json":
+		s.parse = parseJSONLog
+	default:
+		fmt.Fprintf(stderr, "libinjection logscan: unknown log format %q\n", *logFormat)
+		return 2
+	}
+	for _, name := range strings.Split(*detect, ",") {
+		switch strings.TrimSpace(name) {
+		case "sqli":
+			s.detect.SQLi = true
+		case "xss":
+			s.detect.XSS = true
+		case "crlf":
+			s.detect.CRLF = true
+		default:
+			fmt.Fprintf(stderr, "libinjection logscan: unknown detector %q\n", name)
+			return 2
+		}
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "libinjection logscan: unknown format %q\n", *format)
+		return 2
+	}
+
+	status := 0
+	if fs.NArg() == 0 {
+		if err := s.scan("", stdin); err != nil {
+			fmt.Fprintln(stderr, "libinjection logscan:", err)
+			return 2
+		}
+	}
+	for _, name := range fs.Args() {
+		f, err := os.Open(name)
+		if err == nil {
+			err = s.scan(name, f)
+			f.Close()
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, "libinjection logscan:", err)
+			status = 2
+		}
+	}
+
+	s.report.IPs = sortedSummaries(s.ips)
+	s.report.Endpoints = sortedSummaries(s.endpoints)
+	if *format == "json" {
+		enc := json.NewEncoder(stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(&s.report); err != nil {
+			fmt.Fprintln(stderr, "libinjection logscan:", err)
+			return 2
+		}
+	} else {
+		writeLogReport(stdout, &s.report)
+	}
+
+	if status == 0 && s.report.Hits > 0 {
+		status = 1
+	}
+	return status
+}
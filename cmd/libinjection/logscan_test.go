@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testLog = `10.0.0.1 - - [10/Oct/2023:13:55:36 -0700] "GET /search?q=shoes&page=2 HTTP/1.1" 200 2326 "-" "Mozilla/5.0"
+10.0.0.2 - - [10/Oct/2023:13:55:37 -0700] "GET /item?id=1%27%20OR%201%3D1--%20 HTTP/1.1" 200 12 "-" "sqlmap/1.7"
+10.0.0.2 - - [10/Oct/2023:13:55:38 -0700] "GET /item?id=1+UNION+SELECT+password+FROM+users HTTP/1.1" 500 0
+10.0.0.3 - frank [10/Oct/2023:13:55:39 -0700] "GET /comment/%3Cscript%3Ealert(1)%3C%2Fscript%3E HTTP/1.1" 404 0 "-" "curl/8.0"
+{"remote_addr":"10.0.0.2","time_local":"10/Oct/2023:13:55:40 -0700","request":"GET /item?id=-1%27%20or%20%271%27%3D%271 HTTP/1.1","status":200}
+{"client_ip":"10.0.0.4","method":"POST","uri":"/login","args":"user=admin%27--","ts":"2023-10-10T20:55:41Z"}
+10.0.0.6 - - [10/Oct/2023:13:55:41 -0700] "GET /comment/%3Cimg%20src=x%20onerror=alert(1)%3E?x=1 HTTP/1.1" 404 0
+10.0.0.5 - - [10/Oct/2023:13:55:42 -0700] "GET /redirect?to=%2F%0d%0aSet-Cookie:%20admin=1 HTTP/1.1" 302 0
+this is not a log line
+`
+
+func TestLogScan(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	status := run([]string{"logscan", "-format", "json", "-hits"}, strings.NewReader(testLog), &stdout, &stderr)
+	if status != 1 {
+		t.Fatalf("expected status 1, got %d: %s", status, stderr.String())
+	}
+
+	var report logReport
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+		t.Fatalf("%v: %s", err, stdout.String())
+	}
+	if report.Lines != 9 || report.Requests != 8 || report.Unparsed != 1 || report.Flagged != 7 {
+		t.Errorf("unexpected totals %+v", report)
+	}
+
+	ips := map[string]*logSummary{}
+	for _, s := range report.IPs {
+		ips[s.Key] = s
+	}
+	if s := ips["10.0.0.2"]; s == nil || s.Requests != 3 || s.SQLi < 3 || s.FirstSeen != "10/Oct/2023:13:55:37 -0700" {
+		t.Errorf("unexpected summary for 10.0.0.2: %+v", s)
+	}
+	if s := ips["10.0.0.3"]; s == nil || s.XSS != 1 {
+		t.Errorf("unexpected summary for 10.0.0.3: %+v", s)
+	}
+	if s := ips["10.0.0.4"]; s == nil || s.SQLi != 1 {
+		t.Errorf("unexpected summary for 10.0.0.4: %+v", s)
+	}
+	if s := ips["10.0.0.5"]; s == nil || s.CRLF != 1 {
+		t.Errorf("unexpected summary for 10.0.0.5: %+v", s)
+	}
+	if _, ok := ips["10.0.0.1"]; ok {
+		t.Error("benign requests should not be summarized")
+	}
+	if report.IPs[0].Key != "10.0.0.2" {
+		t.Errorf("expected the busiest IP first, got %s", report.IPs[0].Key)
+	}
+
+	endpoints := map[string]*logSummary{}
+	for _, s := range report.Endpoints {
+		endpoints[s.Key] = s
+	}
+	if s := endpoints["GET /item"]; s == nil || s.Requests != 3 || len(s.Fingerprints) == 0 {
+		t.Errorf("unexpected summary for GET /item: %+v", s)
+	}
+	/* payloads in path segments don't make their own endpoints */
+	if s := endpoints["GET /comment/*"]; s == nil || s.Requests != 2 {
+		t.Errorf("unexpected summary for GET /comment/*: %+v", s)
+	}
+
+	found := false
+	for _, hit := range report.HitList {
+		if hit.Location == "path:1" && hit.Value == "<script>alert(1)</script>" && hit.Type == "xss" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected a decoded XSS hit in a path segment, got %+v", report.HitList)
+	}
+}
+
+func TestLogScanFiles(t *testing.T) {
+	dir := t.TempDir()
+	lines := strings.Split(testLog, "\n")
+	first := filepath.Join(dir, "access.log")
+	second := filepath.Join(dir, "access.log.1")
+	/* blank lines count, so the line numbers are the ones an editor shows */
+	if err := os.WriteFile(first, []byte(lines[0]+"\n\n"+lines[1]+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(second, []byte(lines[3]+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if status := run([]string{"logscan", "-format", "json", "-hits", first, second}, nil, &stdout, &stderr); status != 1 {
+		t.Fatalf("expected status 1, got %d: %s", status, stderr.String())
+	}
+	var report logReport
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+		t.Fatalf("%v: %s", err, stdout.String())
+	}
+	if report.Lines != 3 || len(report.HitList) != 2 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if hit := report.HitList[0]; hit.File != first || hit.Line != 3 || hit.Type != "sqli" {
+		t.Errorf("unexpected hit %+v", hit)
+	}
+	if hit := report.HitList[1]; hit.File != second || hit.Line != 1 || hit.Type != "xss" {
+		t.Errorf("unexpected hit %+v", hit)
+	}
+
+	stdout.Reset()
+	run([]string{"logscan", "-hits", second}, nil, &stdout, &stderr)
+	if !strings.HasPrefix(stdout.String(), second+":1\t10.0.0.3\tXSS") {
+		t.Errorf("expected the file and line first, got %q", stdout.String())
+	}
+}
+
+func TestParseCLF(t *testing.T) {
+	r, err := parseCLF(`::1 - - [01/Jan/2024:00:00:00 +0000] "GET /a b?x=\"1\" HTTP/1.0" 400 0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.ip != "::1" || r.method != "GET" || r.target != `/a b?x="1"` {
+		t.Errorf("unexpected request %+v", r)
+	}
+	if _, err := parseCLF(`::1 - - [01/Jan/2024:00:00:00 +0000] "GET /`); err == nil {
+		t.Error("expected an error for an unterminated request")
+	}
+}
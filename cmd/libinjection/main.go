@@ -0,0 +1,69 @@
+/*
+ * Command libinjection runs the detectors from a shell.
+ *
+ *	libinjection scan [flags] [file ...]
+ *	libinjection logscan [flags] [file ...]
+ *	libinjection pcap [flags] [file ...]
+ *	libinjection diff [flags] [corpus ...]
+ *	libinjection train [flags] [corpus ...]
+ *	libinjection mutate [flags] [file ...]
+ *
+ * Run a command with -h for its flags.
+ */
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+/*
+ * A command gets its arguments, without the command name, and returns the
+ * exit status.
+ */
+type command func(args []string, stdin io.Reader, stdout, stderr io.Writer) int
+
+var commands = map[string]command{
+	"scan":    runScan,
+	"logscan": runLogScan,
+	"pcap":    runPcap,
+	"diff":    runDiff,
+	"train":   runTrain,
+	"mutate":  runMutate,
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: libinjection <command> [flags] [args]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	fmt.Fprintln(w, "  scan      check payloads, one per line, from files or stdin")
+	fmt.Fprintln(w, "  logscan   retro-hunt attacks in Apache/nginx and JSON access logs")
+	fmt.Fprintln(w, "  pcap      check the HTTP requests of pcap and pcapng captures")
+	fmt.Fprintln(w, "  diff      compare with corpora recorded from the C libinjection")
+	fmt.Fprintln(w, "  train     propose fingerprint database changes from labeled samples")
+	fmt.Fprintln(w, "  mutate    measure the bypass rate of evasion variants of SQLi payloads")
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+	switch args[0] {
+	case "-h", "-help", "--help", "help":
+		usage(stdout)
+		return 0
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "libinjection: unknown command %q\n", args[0])
+		usage(stderr)
+		return 2
+	}
+	return cmd(args[1:], stdin, stdout, stderr)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jptosso/libinjection-go/mutate"
+)
+
+/*
+ * readPayloads appends the non-empty lines of r to payloads.
+ */
+func readPayloads(payloads []string, r io.Reader) ([]string, error) {
+	br := bufio.NewReader(r)
+	for {
+		text, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return payloads, err
+		}
+		text = strings.TrimSuffix(strings.TrimSuffix(text, "\n"), "\r")
+		if text != "" {
+			payloads = append(payloads, text)
+		}
+		if err == io.EOF {
+			return payloads, nil
+		}
+	}
+}
+
+func writeMutateReport(w io.Writer, s *mutate.Summary) {
+	fmt.Fprintf(w, "%d payloads, %d undetected, %d variants, %d missed (%.2f%%)\n",
+		s.Payloads, len(s.Undetected), s.Variants, s.Missed, 100*s.BypassRate())
+	for _, payload := range s.Undetected {
+		fmt.Fprintf(w, "undetected %q\n", payload)
+	}
+	for _, st := range s.ByTransform {
+		fmt.Fprintf(w, "%-16s %d/%d missed\n", st.Transform, st.Missed, st.Variants)
+	}
+}
+
+/*
+ * runMutate measures the SQLi bypass rate of the variants of payloads,
+ * and compares it with a baseline recorded by an earlier run.
+ */
+func runMutate(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("mutate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: libinjection mutate [flags] [file ...]")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Rewrites every SQLi payload of the files, or stdin, one a line, with")
+		fmt.Fprintln(stderr, "the evasion transforms of package mutate and prints how many variants")
+		fmt.Fprintln(stderr, "the detector misses. With -baseline, the exit status is 1 when a")
+		fmt.Fprintln(stderr, "bypass rate is higher than in the baseline; -update records it there.")
+		fmt.Fprintln(stderr)
+		fs.PrintDefaults()
+	}
+	baselinePath := fs.String("baseline", "", "`file` of the bypass rates to compare with")
+	update := fs.Bool("update", false, "write the bypass rates to the -baseline file")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if *update && *baselinePath == "" {
+		fmt.Fprintln(stderr, "libinjection mutate: -update needs -baseline")
+		return 2
+	}
+
+	var payloads []string
+	var err error
+	if fs.NArg() == 0 {
+		payloads, err = readPayloads(payloads, stdin)
+	}
+	for _, name := range fs.Args() {
+		if err != nil {
+			break
+		}
+		if name == "-" {
+			payloads, err = readPayloads(payloads, stdin)
+		} else {
+			var f *os.File
+			if f, err = os.Open(name); err == nil {
+				payloads, err = readPayloads(payloads, f)
+				f.Close()
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "libinjection mutate:", err)
+		return 2
+	}
+
+	s := mutate.Run(payloads, mutate.SQLi)
+	writeMutateReport(stdout, &s)
+	if *baselinePath == "" {
+		return 0
+	}
+
+	if *update {
+		var b bytes.Buffer
+		if err := s.WriteBaseline(&b); err == nil {
+			err = os.WriteFile(*baselinePath, b.Bytes(), 0o644)
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, "libinjection mutate:", err)
+			return 2
+		}
+		return 0
+	}
+	f, err := os.Open(*baselinePath)
+	if err != nil {
+		fmt.Fprintln(stderr, "libinjection mutate:", err)
+		return 2
+	}
+	baseline, err := mutate.ReadBaseline(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintln(stderr, "libinjection mutate:", err)
+		return 2
+	}
+	regressions := s.Regressions(&baseline)
+	for _, r := range regressions {
+		fmt.Fprintln(stdout, "regression", r)
+	}
+	if len(regressions) != 0 {
+		return 1
+	}
+	return 0
+}
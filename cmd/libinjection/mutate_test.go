@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMutate(t *testing.T) {
+	baseline := filepath.Join(t.TempDir(), "baseline.tsv")
+	payloads := "-1 OR 2>1 AND name='admin'\n1 UNION SELECT username, password FROM users\n"
+
+	var stdout, stderr bytes.Buffer
+	if status := run([]string{"mutate", "-baseline", baseline, "-update"}, strings.NewReader(payloads), &stdout, &stderr); status != 0 {
+		t.Fatalf("expected status 0, got %d: %s", status, stderr.String())
+	}
+	if !strings.HasPrefix(stdout.String(), "2 payloads, 0 undetected, ") {
+		t.Errorf("unexpected report %q", stdout.String())
+	}
+	recorded, err := os.ReadFile(baseline)
+	if err != nil || !bytes.HasPrefix(recorded, []byte("# transform\tvariants\tmissed\ntotal\t")) {
+		t.Fatalf("unexpected baseline %q: %v", recorded, err)
+	}
+
+	stdout.Reset()
+	if status := run([]string{"mutate", "-baseline", baseline}, strings.NewReader(payloads), &stdout, &stderr); status != 0 {
+		t.Errorf("expected status 0 against its own baseline, got %d: %s", status, stdout.String())
+	}
+
+	/* a baseline where nothing was missed */
+	os.WriteFile(baseline, []byte("total\t1\t0\n"), 0o644)
+	stdout.Reset()
+	if status := run([]string{"mutate", "-baseline", baseline}, strings.NewReader(payloads), &stdout, &stderr); status != 1 {
+		t.Errorf("expected status 1 for a regression, got %d", status)
+	}
+	if !strings.Contains(stdout.String(), "\nregression total: ") {
+		t.Errorf("regression not reported: %s", stdout.String())
+	}
+
+	if status := run([]string{"mutate", "-update"}, strings.NewReader(payloads), &stdout, &stderr); status != 2 {
+		t.Errorf("expected status 2 for -update without -baseline, got %d", status)
+	}
+}
@@ -0,0 +1,445 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+/*
+ * A minimal reader for libpcap and pcapng capture files, and a decoder for
+ * the Ethernet, Linux cooked, loopback, IPv4, IPv6 and TCP headers. Only
+ * what is needed to get at TCP payloads.
+ */
+
+const (
+	pcapMagicMicro   = 0xa1b2c3d4
+	pcapMagicNano    = 0xa1b23c4d
+	pcapngSection    = 0x0a0d0d0a
+	pcapngByteOrder  = 0x1a2b3c4d
+	pcapngInterface  = 1
+	pcapngSimple     = 3
+	pcapngEnhanced   = 6
+	pcapngTSResolOpt = 9
+
+	/* a record larger than this is a corrupt file, not a packet */
+	pcapMaxRecord = 1 << 20
+
+	linkNull    = 0
+	linkEther   = 1
+	linkRaw     = 101
+	linkRawAlt  = 12 /* DLT_RAW on OpenBSD */
+	linkLinuxSL = 113
+	linkIPv4    = 228
+	linkIPv6    = 229
+	linkLinuxS2 = 276
+)
+
+type packet struct {
+	ts       time.Time
+	linkType uint32
+	data     []byte
+}
+
+type pcapngIface struct {
+	linkType uint32
+	/* timestamp units per second */
+	tsUnits uint64
+}
+
+type pcapReader struct {
+	r        io.Reader
+	order    binary.ByteOrder
+	ng       bool
+	nano     bool
+	linkType uint32
+	ifaces   []pcapngIface
+}
+
+var errPcapFormat = errors.New("not a pcap or pcapng file")
+
+func newPcapReader(r io.Reader) (*pcapReader, error) {
+	var hdr [24]byte
+	if _, err := io.ReadFull(r, hdr[:4]); err != nil {
+		return nil, errPcapFormat
+	}
+	p := &pcapReader{r: r}
+
+	if binary.LittleEndian.Uint32(hdr[:4]) == pcapngSection {
+		p.ng = true
+		if err := p.readSection(); err != nil {
+			return nil, err
+		}
+		return p, nil
+	}
+
+	switch {
+	case binary.LittleEndian.Uint32(hdr[:4]) == pcapMagicMicro:
+		p.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(hdr[:4]) == pcapMagicMicro:
+		p.order = binary.BigEndian
+	case binary.LittleEndian.Uint32(hdr[:4]) == pcapMagicNano:
+		p.order, p.nano = binary.LittleEndian, true
+	case binary.BigEndian.Uint32(hdr[:4]) == pcapMagicNano:
+		p.order, p.nano = binary.BigEndian, true
+	default:
+		return nil, errPcapFormat
+	}
+	if _, err := io.ReadFull(r, hdr[4:]); err != nil {
+		return nil, fmt.Errorf("pcap header: %w", err)
+	}
+	p.linkType = p.order.Uint32(hdr[20:24]) & 0x0fffffff /* upper bits are FCS info */
+	return p, nil
+}
+
+/*
+ * next returns the next packet, io.EOF at the end of the file.
+ */
+func (p *pcapReader) next() (packet, error) {
+	if p.ng {
+		return p.nextNG()
+	}
+
+	var hdr [16]byte
+	if _, err := io.ReadFull(p.r, hdr[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return packet{}, io.EOF /* truncated capture, like tcpdump -r */
+		}
+		return packet{}, err
+	}
+	sec := p.order.Uint32(hdr[0:4])
+	frac := p.order.Uint32(hdr[4:8])
+	caplen := p.order.Uint32(hdr[8:12])
+	if caplen > pcapMaxRecord {
+		return packet{}, fmt.Errorf("pcap record of %d bytes", caplen)
+	}
+	data := make([]byte, caplen)
+	if _, err := io.ReadFull(p.r, data); err != nil {
+		return packet{}, io.EOF
+	}
+
+	nsec := int64(frac) * 1000
+	if p.nano {
+		nsec = int64(frac)
+	}
+	return packet{ts: time.Unix(int64(sec), nsec).UTC(), linkType: p.linkType, data: data}, nil
+}
+
+/*
+ * readBlock reads a pcapng block whose type has already been read, and
+ * returns its body.
+ */
+func (p *pcapReader) readBlock() ([]byte, error) {
+	var l [4]byte
+	if _, err := io.ReadFull(p.r, l[:]); err != nil {
+		return nil, err
+	}
+	total := p.order.Uint32(l[:])
+	if total < 12 || total > pcapMaxRecord || total%4 != 0 {
+		return nil, fmt.Errorf("pcapng block of %d bytes", total)
+	}
+	body := make([]byte, total-8)
+	if _, err := io.ReadFull(p.r, body); err != nil {
+		return nil, err
+	}
+	/* drop the trailing copy of the length */
+	return body[:len(body)-4], nil
+}
+
+/*
+ * readSection reads a section header block. The byte order of the section
+ * is only known from the magic that follows the length.
+ */
+func (p *pcapReader) readSection() error {
+	var hdr [8]byte
+	if _, err := io.ReadFull(p.r, hdr[:]); err != nil {
+		return fmt.Errorf("pcapng header: %w", err)
+	}
+	switch {
+	case binary.LittleEndian.Uint32(hdr[4:8]) == pcapngByteOrder:
+		p.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(hdr[4:8]) == pcapngByteOrder:
+		p.order = binary.BigEndian
+	default:
+		return errPcapFormat
+	}
+	total := p.order.Uint32(hdr[0:4])
+	if total < 28 || total > pcapMaxRecord || total%4 != 0 {
+		return fmt.Errorf("pcapng section header of %d bytes", total)
+	}
+	if _, err := io.CopyN(io.Discard, p.r, int64(total-12)); err != nil {
+		return fmt.Errorf("pcapng header: %w", err)
+	}
+	/* interface ids are per section */
+	p.ifaces = p.ifaces[:0]
+	return nil
+}
+
+func (p *pcapReader) nextNG() (packet, error) {
+	for {
+		var t [4]byte
+		if _, err := io.ReadFull(p.r, t[:]); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return packet{}, io.EOF
+			}
+			return packet{}, err
+		}
+		typ := p.order.Uint32(t[:])
+		if typ == pcapngSection {
+			if err := p.readSection(); err != nil {
+				return packet{}, err
+			}
+			continue
+		}
+		body, err := p.readBlock()
+		if err != nil {
+			return packet{}, io.EOF
+		}
+
+		switch typ {
+		case pcapngInterface:
+			if len(body) < 8 {
+				return packet{}, errors.New("short pcapng interface block")
+			}
+			p.ifaces = append(p.ifaces, pcapngIface{
+				linkType: uint32(p.order.Uint16(body[0:2])),
+				tsUnits:  p.tsUnits(body[8:]),
+			})
+		case pcapngEnhanced:
+			if len(body) < 20 {
+				return packet{}, errors.New("short pcapng packet block")
+			}
+			id := p.order.Uint32(body[0:4])
+			if int(id) >= len(p.ifaces) {
+				return packet{}, fmt.Errorf("pcapng packet for unknown interface %d", id)
+			}
+			iface := p.ifaces[id]
+			ts := uint64(p.order.Uint32(body[4:8]))<<32 | uint64(p.order.Uint32(body[8:12]))
+			caplen := p.order.Uint32(body[12:16])
+			if int(caplen) > len(body)-20 {
+				return packet{}, errors.New("pcapng packet larger than its block")
+			}
+			return packet{ts: unitsToTime(ts, iface.tsUnits), linkType: iface.linkType, data: body[20 : 20+caplen]}, nil
+		case pcapngSimple:
+			if len(p.ifaces) == 0 || len(body) < 4 {
+				return packet{}, errors.New("bad pcapng simple packet block")
+			}
+			origlen := int(p.order.Uint32(body[0:4]))
+			data := body[4:]
+			if origlen < len(data) {
+				data = data[:origlen]
+			}
+			/* no timestamp in simple packet blocks */
+			return packet{linkType: p.ifaces[0].linkType, data: data}, nil
+		}
+		/* other blocks: name resolution, statistics, ... */
+	}
+}
+
+/*
+ * tsUnits reads the if_tsresol option of an interface block, microseconds
+ * by default.
+ */
+func (p *pcapReader) tsUnits(opts []byte) uint64 {
+	for len(opts) >= 4 {
+		code := p.order.Uint16(opts[0:2])
+		l := int(p.order.Uint16(opts[2:4]))
+		if code == 0 || 4+l > len(opts) {
+			break
+		}
+		if code == pcapngTSResolOpt && l >= 1 {
+			res := opts[4]
+			if res&0x80 != 0 {
+				if res&0x7f < 64 {
+					return 1 << (res & 0x7f)
+				}
+			} else if res <= 19 {
+				units := uint64(1)
+				for i := byte(0); i < res; i++ {
+					units *= 10
+				}
+				return units
+			}
+		}
+		if n := 4 + (l+3)&^3; n < len(opts) {
+			opts = opts[n:]
+		} else {
+			break
+		}
+	}
+	return 1000000
+}
+
+func unitsToTime(ts uint64, units uint64) time.Time {
+	sec := ts / units
+	frac := ts % units
+	var nsec uint64
+	if units <= 1000000000 {
+		nsec = frac * (1000000000 / units)
+		if 1000000000%units != 0 {
+			/* binary resolutions */
+			nsec = uint64(float64(frac) * 1e9 / float64(units))
+		}
+	} else {
+		nsec = frac / (units / 1000000000)
+	}
+	return time.Unix(int64(sec), int64(nsec)).UTC()
+}
+
+/*
+ * tcpSegment is a decoded TCP packet.
+ */
+type tcpSegment struct {
+	ts       time.Time
+	src, dst endpoint
+	seq      uint32
+	syn      bool
+	fin      bool
+	rst      bool
+	payload  []byte
+}
+
+/*
+ * decodeTCP decodes p down to TCP, false for anything else: other
+ * protocols, IP fragments, truncated headers.
+ */
+func decodeTCP(p packet) (tcpSegment, bool) {
+	b := p.data
+	var ethertype uint16
+
+	switch p.linkType {
+	case linkEther:
+		if len(b) < 14 {
+			return tcpSegment{}, false
+		}
+		ethertype = binary.BigEndian.Uint16(b[12:14])
+		b = b[14:]
+		/* 802.1Q and QinQ tags */
+		for ethertype == 0x8100 || ethertype == 0x88a8 || ethertype == 0x9100 {
+			if len(b) < 4 {
+				return tcpSegment{}, false
+			}
+			ethertype = binary.BigEndian.Uint16(b[2:4])
+			b = b[4:]
+		}
+	case linkLinuxSL:
+		if len(b) < 16 {
+			return tcpSegment{}, false
+		}
+		ethertype = binary.BigEndian.Uint16(b[14:16])
+		b = b[16:]
+	case linkLinuxS2:
+		if len(b) < 20 {
+			return tcpSegment{}, false
+		}
+		ethertype = binary.BigEndian.Uint16(b[0:2])
+		b = b[20:]
+	case linkNull:
+		/* address family, in the byte order of the capturing host */
+		if len(b) < 4 {
+			return tcpSegment{}, false
+		}
+		family := binary.LittleEndian.Uint32(b[0:4])
+		if family > 0xffff {
+			family = binary.BigEndian.Uint32(b[0:4])
+		}
+		switch family {
+		case 2:
+			ethertype = 0x0800
+		case 10, 24, 28, 30: /* AF_INET6 on Linux, BSDs and macOS */
+			ethertype = 0x86dd
+		default:
+			return tcpSegment{}, false
+		}
+		b = b[4:]
+	case linkRaw, linkRawAlt, linkIPv4, linkIPv6:
+		if len(b) < 1 {
+			return tcpSegment{}, false
+		}
+		switch b[0] >> 4 {
+		case 4:
+			ethertype = 0x0800
+		case 6:
+			ethertype = 0x86dd
+		default:
+			return tcpSegment{}, false
+		}
+	default:
+		return tcpSegment{}, false
+	}
+
+	var seg tcpSegment
+	var srcIP, dstIP net.IP
+	switch ethertype {
+	case 0x0800:
+		if len(b) < 20 || b[0]>>4 != 4 {
+			return seg, false
+		}
+		ihl := int(b[0]&0x0f) * 4
+		total := int(binary.BigEndian.Uint16(b[2:4]))
+		if ihl < 20 || total < ihl || len(b) < ihl {
+			return seg, false
+		}
+		/* more fragments, or a fragment offset */
+		if binary.BigEndian.Uint16(b[6:8])&0x3fff != 0 || b[9] != 6 {
+			return seg, false
+		}
+		srcIP = net.IP(b[12:16])
+		dstIP = net.IP(b[16:20])
+		/* drop the Ethernet padding */
+		if total < len(b) {
+			b = b[:total]
+		}
+		b = b[ihl:]
+	case 0x86dd:
+		if len(b) < 40 || b[0]>>4 != 6 {
+			return seg, false
+		}
+		payload := int(binary.BigEndian.Uint16(b[4:6]))
+		next := b[6]
+		srcIP = net.IP(b[8:24])
+		dstIP = net.IP(b[24:40])
+		b = b[40:]
+		if payload < len(b) {
+			b = b[:payload]
+		}
+		/* hop-by-hop, routing and destination options headers */
+		for next == 0 || next == 43 || next == 60 {
+			if len(b) < 8 {
+				return seg, false
+			}
+			l := (int(b[1]) + 1) * 8
+			if len(b) < l {
+				return seg, false
+			}
+			next = b[0]
+			b = b[l:]
+		}
+		if next != 6 {
+			return seg, false
+		}
+	default:
+		return seg, false
+	}
+
+	if len(b) < 20 {
+		return seg, false
+	}
+	off := int(b[12]>>4) * 4
+	if off < 20 || len(b) < off {
+		return seg, false
+	}
+	flags := b[13]
+	seg.ts = p.ts
+	seg.src = newEndpoint(srcIP, binary.BigEndian.Uint16(b[0:2]))
+	seg.dst = newEndpoint(dstIP, binary.BigEndian.Uint16(b[2:4]))
+	seg.seq = binary.BigEndian.Uint32(b[4:8])
+	seg.fin = flags&0x01 != 0
+	seg.syn = flags&0x02 != 0
+	seg.rst = flags&0x04 != 0
+	seg.payload = b[off:]
+	return seg, true
+}
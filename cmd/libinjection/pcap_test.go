@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testEpoch = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+/*
+ * tcpFrame builds an Ethernet/IPv4 or a raw IPv6 packet carrying a TCP
+ * segment. Checksums are left at zero, nothing checks them.
+ */
+func mustEndpoint(s string) endpoint {
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		panic(err)
+	}
+	n, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		panic(err)
+	}
+	return newEndpoint(net.ParseIP(host), uint16(n))
+}
+
+func tcpFrame(src, dst endpoint, seq uint32, flags byte, payload string) []byte {
+	tcp := make([]byte, 20, 20+len(payload))
+	binary.BigEndian.PutUint16(tcp[0:2], src.Port())
+	binary.BigEndian.PutUint16(tcp[2:4], dst.Port())
+	binary.BigEndian.PutUint32(tcp[4:8], seq)
+	tcp[12] = 5 << 4
+	tcp[13] = flags
+	tcp = append(tcp, payload...)
+
+	if src.Addr().To4() == nil {
+		ip := make([]byte, 40, 40+len(tcp))
+		ip[0] = 6 << 4
+		binary.BigEndian.PutUint16(ip[4:6], uint16(len(tcp)))
+		ip[6] = 6
+		ip[7] = 64
+		copy(ip[8:24], src.Addr())
+		copy(ip[24:40], dst.Addr())
+		return append(ip, tcp...)
+	}
+
+	ip := make([]byte, 20, 20+len(tcp))
+	ip[0] = 4<<4 | 5
+	binary.BigEndian.PutUint16(ip[2:4], uint16(20+len(tcp)))
+	ip[8] = 64
+	ip[9] = 6
+	copy(ip[12:16], src.Addr().To4())
+	copy(ip[16:20], dst.Addr().To4())
+
+	eth := make([]byte, 14, 14+len(ip)+len(tcp))
+	binary.BigEndian.PutUint16(eth[12:14], 0x0800)
+	return append(append(eth, ip...), tcp...)
+}
+
+func writePcap(frames [][]byte) []byte {
+	var b bytes.Buffer
+	hdr := make([]byte, 24)
+	binary.LittleEndian.PutUint32(hdr[0:4], pcapMagicMicro)
+	binary.LittleEndian.PutUint16(hdr[4:6], 2)
+	binary.LittleEndian.PutUint16(hdr[6:8], 4)
+	binary.LittleEndian.PutUint32(hdr[16:20], 65535)
+	binary.LittleEndian.PutUint32(hdr[20:24], linkEther)
+	b.Write(hdr)
+	for i, frame := range frames {
+		ts := testEpoch.Add(time.Duration(i) * time.Millisecond)
+		rec := make([]byte, 16)
+		binary.LittleEndian.PutUint32(rec[0:4], uint32(ts.Unix()))
+		binary.LittleEndian.PutUint32(rec[4:8], uint32(ts.Nanosecond()/1000))
+		binary.LittleEndian.PutUint32(rec[8:12], uint32(len(frame)))
+		binary.LittleEndian.PutUint32(rec[12:16], uint32(len(frame)))
+		b.Write(rec)
+		b.Write(frame)
+	}
+	return b.Bytes()
+}
+
+func pcapngBlock(typ uint32, body []byte) []byte {
+	for len(body)%4 != 0 {
+		body = append(body, 0)
+	}
+	b := make([]byte, 8, 12+len(body))
+	binary.BigEndian.PutUint32(b[0:4], typ)
+	binary.BigEndian.PutUint32(b[4:8], uint32(12+len(body)))
+	b = append(b, body...)
+	return binary.BigEndian.AppendUint32(b, uint32(12+len(body)))
+}
+
+/*
+ * writePcapng writes a big endian pcapng file with raw IP packets and a
+ * nanosecond timestamp resolution.
+ */
+func writePcapng(frames [][]byte) []byte {
+	var b bytes.Buffer
+	shb := binary.BigEndian.AppendUint32(nil, pcapngByteOrder)
+	shb = append(shb, 0, 1, 0, 0)
+	shb = append(shb, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff)
+	b.Write(pcapngBlock(pcapngSection, shb))
+
+	idb := []byte{0, linkRaw, 0, 0, 0, 0, 0xff, 0xff}
+	idb = append(idb, 0, pcapngTSResolOpt, 0, 1, 9, 0, 0, 0)
+	idb = append(idb, 0, 0, 0, 0)
+	b.Write(pcapngBlock(pcapngInterface, idb))
+
+	for i, frame := range frames {
+		ts := uint64(testEpoch.Add(time.Duration(i) * time.Nanosecond).UnixNano())
+		epb := binary.BigEndian.AppendUint32(nil, 0)
+		epb = binary.BigEndian.AppendUint32(epb, uint32(ts>>32))
+		epb = binary.BigEndian.AppendUint32(epb, uint32(ts))
+		epb = binary.BigEndian.AppendUint32(epb, uint32(len(frame)))
+		epb = binary.BigEndian.AppendUint32(epb, uint32(len(frame)))
+		epb = append(epb, frame...)
+		b.Write(pcapngBlock(pcapngEnhanced, epb))
+	}
+	return b.Bytes()
+}
+
+func runPcapTest(t *testing.T, capture []byte) []pcapFinding {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	status := run([]string{"pcap", "-format", "json"}, bytes.NewReader(capture), &stdout, &stderr)
+	if status == 2 {
+		t.Fatalf("pcap failed: %s", stderr.String())
+	}
+
+	var findings []pcapFinding
+	dec := json.NewDecoder(&stdout)
+	for dec.More() {
+		var f pcapFinding
+		if err := dec.Decode(&f); err != nil {
+			t.Fatal(err)
+		}
+		findings = append(findings, f)
+	}
+	return findings
+}
+
+func TestPcap(t *testing.T) {
+	client := mustEndpoint("10.0.0.1:40000")
+	server := mustEndpoint("10.0.0.2:80")
+
+	req1 := "GET /item?id=1%27%20OR%201%3D1--%20 HTTP/1.1\r\nHost: shop\r\nUser-Agent: curl\r\n\r\n"
+	req2 := "POST /comment HTTP/1.1\r\nHost: shop\r\nContent-Type: application/x-www-form-urlencoded\r\nContent-Length: 60\r\n\r\n" +
+		"author=bob&text=%3Cscript%3Ealert(1)%3C/"
+	resp := "HTTP/1.1 200 OK\r\nContent-Length: 17\r\n\r\n<script></script>"
+	split := 20
+	seq := uint32(1000)
+
+	frames := [][]byte{
+		tcpFrame(client, server, seq-1, 0x02, ""),
+		/* second half first, then the first half, then a retransmission */
+		tcpFrame(client, server, seq+uint32(split), 0x18, req1[split:]),
+		tcpFrame(client, server, seq, 0x18, req1[:split]),
+		tcpFrame(client, server, seq, 0x18, req1[:split]),
+		tcpFrame(server, client, 5000, 0x18, resp),
+		/* the body ends past the capture */
+		tcpFrame(client, server, seq+uint32(len(req1)), 0x18, req2),
+		tcpFrame(client, server, seq+uint32(len(req1)+len(req2)), 0x11, ""),
+	}
+
+	findings := runPcapTest(t, writePcap(frames))
+	if len(findings) != 2 {
+		t.Fatalf("expected 2 findings, got %+v", findings)
+	}
+
+	f := findings[0]
+	if f.Type != "sqli" || f.Location != "query:id" || f.Value != "1' OR 1=1-- " || f.Method != "GET" {
+		t.Errorf("unexpected finding %+v", f)
+	}
+	if f.SrcIP != "10.0.0.1" || f.SrcPort != 40000 || f.DstIP != "10.0.0.2" || f.DstPort != 80 || f.Proto != "tcp" {
+		t.Errorf("unexpected flow %+v", f)
+	}
+	if !f.Time.Equal(testEpoch.Add(2 * time.Millisecond)) {
+		t.Errorf("expected the time of the first in order packet, got %v", f.Time)
+	}
+
+	f = findings[1]
+	if f.Type != "xss" || f.Location != "body:text" || !strings.HasPrefix(f.Value, "<script>") || f.URI != "/comment" {
+		t.Errorf("unexpected finding %+v", f)
+	}
+}
+
+func TestAssembler(t *testing.T) {
+	client := mustEndpoint("10.0.0.1:40000")
+	server := mustEndpoint("10.0.0.2:80")
+	req := "GET / HTTP/1.1\r\nHost: shop\r\n\r\n"
+
+	var done []*tcpStream
+	a := newAssembler(func(s *tcpStream) { done = append(done, s) })
+
+	/* responses are not kept, the first line gives them away */
+	a.add(tcpSegment{ts: testEpoch, src: server, dst: client, seq: 1, payload: []byte("HTTP/1.1 200 OK\r\n")})
+	a.add(tcpSegment{ts: testEpoch, src: server, dst: client, seq: 18, payload: []byte(strings.Repeat("x", 1000))})
+	a.add(tcpSegment{ts: testEpoch, src: client, dst: server, seq: 1, payload: []byte(req)})
+	if a.buffered != len(req) {
+		t.Errorf("expected only the request to be buffered, got %d bytes", a.buffered)
+	}
+	/* nor is a first line that never ends */
+	other := mustEndpoint("10.0.0.3:40000")
+	a.add(tcpSegment{ts: testEpoch, src: other, dst: server, seq: 1, payload: make([]byte, maxRequestLine+1)})
+	if a.buffered != len(req) {
+		t.Errorf("expected a binary stream to be dropped, got %d bytes", a.buffered)
+	}
+
+	/* the oldest stream is closed to open a new one */
+	a.maxStreams = 3
+	a.add(tcpSegment{ts: testEpoch, src: mustEndpoint("10.0.0.4:40000"), dst: server, seq: 1, payload: []byte(req)})
+	if len(done) != 1 || done[0].key.src != server || len(a.streams) != 3 {
+		t.Fatalf("expected the response stream to be closed, got %d closed, %d open", len(done), len(a.streams))
+	}
+
+	/* and to stay under the buffered bytes limit */
+	a.maxBytes = 3 * len(req)
+	a.add(tcpSegment{ts: testEpoch, src: client, dst: server, seq: uint32(1 + len(req)), payload: []byte(req)})
+	if len(done) != 1 {
+		t.Fatalf("expected no stream to be closed, got %d", len(done))
+	}
+	a.add(tcpSegment{ts: testEpoch, src: client, dst: server, seq: uint32(1 + 2*len(req)), payload: []byte(req)})
+	if len(done) != 2 || done[1].key.src != client || len(done[1].data) != 3*len(req) || a.buffered != len(req) {
+		t.Fatalf("expected the oldest stream to be closed, got %d closed, %d bytes buffered", len(done), a.buffered)
+	}
+
+	a.flushAll()
+	if len(done) != 4 || a.buffered != 0 || a.order.Len() != 0 {
+		t.Errorf("expected every stream to be closed, got %d closed, %d bytes buffered", len(done), a.buffered)
+	}
+}
+
+func TestParseRequestLine(t *testing.T) {
+	tests := []struct {
+		line, method, target string
+	}{
+		{"GET / HTTP/1.1", "GET", "/"},
+		{"GET /?id=1' OR 1=1-- HTTP/1.1", "GET", "/?id=1' OR 1=1--"},
+		{"GET /%zz?id=1 HTTP/1.0", "GET", "/%zz?id=1"},
+		{"HTTP/1.1 200 OK", "", ""},
+		{"GET /", "", ""},
+		{"get / HTTP/1.1", "", ""},
+		{"GET  HTTP/1.1", "", ""},
+	}
+	for _, tt := range tests {
+		method, target, _, ok := parseRequestLine(tt.line)
+		if method != tt.method || target != tt.target || ok != (tt.method != "") {
+			t.Errorf("%q: got %q %q %v", tt.line, method, target, ok)
+		}
+	}
+}
+
+/*
+ * Requests net/http rejects are still checked, and don't hide the
+ * requests after them.
+ */
+func TestPcapLenient(t *testing.T) {
+	client := mustEndpoint("10.0.0.1:40000")
+	server := mustEndpoint("10.0.0.2:80")
+	chunked := "POST /c HTTP/1.1\r\nHost: shop\r\nContent-Type: application/x-www-form-urlencoded\r\n" +
+		"Transfer-Encoding: chunked\r\n\r\n5\r\nq=1%2\r\n12\r\n7 union select 1--\r\n0\r\n\r\n"
+	data := "GET /?id=1' OR 1=1-- HTTP/1.1\r\nHost: shop\r\n\r\n" +
+		"GET /%zz?id=1 union select 1-- HTTP/1.1\r\nHost: shop\r\n\r\n" +
+		"garbage\r\n" +
+		"GET /?q=<script>alert(1)</script> HTTP/1.1\r\nBad header\r\n\r\n" +
+		chunked
+
+	frames := [][]byte{
+		tcpFrame(client, server, 99, 0x02, ""),
+		tcpFrame(client, server, 100, 0x18, data),
+	}
+	findings := runPcapTest(t, writePcap(frames))
+
+	var got []string
+	for _, f := range findings {
+		got = append(got, f.Type+" "+f.Location+" "+f.Value)
+	}
+	expected := []string{
+		"sqli query:id 1' OR 1=1--",
+		"sqli query:id 1 union select 1--",
+		"xss query:q <script>alert(1)</script>",
+		"sqli body:q 1' union select 1--",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestPcapng(t *testing.T) {
+	client := mustEndpoint("[2001:db8::1]:50000")
+	server := mustEndpoint("[2001:db8::2]:8080")
+	body := `{"user":{"name":"admin' OR '1'='1"},"tags":["a"]}`
+	req := "POST /api/login HTTP/1.1\r\nHost: api\r\nCookie: session=abc; theme=%22%3E%3Cscript%3E\r\n" +
+		"Content-Type: application/json\r\nContent-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n" + body
+
+	frames := [][]byte{
+		tcpFrame(client, server, 99, 0x02, ""),
+		tcpFrame(client, server, 100, 0x18, req),
+	}
+	findings := runPcapTest(t, writePcapng(frames))
+
+	locations := map[string]pcapFinding{}
+	for _, f := range findings {
+		locations[f.Location] = f
+	}
+	if f, ok := locations["body.user.name"]; !ok || f.Type != "sqli" || f.SrcIP != "2001:db8::1" || f.DstPort != 8080 {
+		t.Errorf("expected SQLi in the JSON body, got %+v", findings)
+	}
+	if f, ok := locations["cookie:theme"]; !ok || f.Type != "xss" {
+		t.Errorf("expected XSS in a cookie, got %+v", findings)
+	}
+	if f := locations["body.user.name"]; !f.Time.Equal(testEpoch.Add(time.Nanosecond)) {
+		t.Errorf("expected nanosecond timestamps, got %v", f.Time)
+	}
+}
+
+func TestPcapFormat(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if status := run([]string{"pcap"}, strings.NewReader("not a capture"), &stdout, &stderr); status != 2 {
+		t.Errorf("expected status 2, got %d", status)
+	}
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jptosso/libinjection-go/httpparams"
+	"github.com/jptosso/libinjection-go/internal/strutil"
+)
+
+/* request bodies are only read up to this size */
+const maxBodyBytes = 1 << 20
+
+/*
+ * pcapFinding is one detection in a capture, also the JSON lines format.
+ */
+type pcapFinding struct {
+	Time        time.Time `json:"time"`
+	Proto       string    `json:"proto"`
+	SrcIP       string    `json:"src_ip"`
+	SrcPort     uint16    `json:"src_port"`
+	DstIP       string    `json:"dst_ip"`
+	DstPort     uint16    `json:"dst_port"`
+	Method      string    `json:"method"`
+	URI         string    `json:"uri"`
+	Location    string    `json:"location"`
+	Value       string    `json:"value"`
+	Type        string    `json:"type"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
+}
+
+/*
+ * countingReader tells how far into the stream the HTTP parser is, to
+ * find the time of each request.
+ */
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+type pcapScanner struct {
+	detect httpparams.Detectors
+	report func(pcapFinding) /* called as each stream ends */
+}
+
+/*
+ * parseRequestLine splits an HTTP/1.x request line leniently: the method
+ * ends at the first space and the version starts after the last, so the
+ * target may hold raw spaces and bad escapes, as attacks do.
+ */
+func parseRequestLine(line string) (method, target, proto string, ok bool) {
+	method, rest, found := strutil.Cut(line, " ")
+	i := strings.LastIndexByte(rest, ' ')
+	if !found || i == -1 {
+		return "", "", "", false
+	}
+	target, proto = strings.Trim(rest[:i], " "), rest[i+1:]
+	if method == "" || target == "" || !strings.HasPrefix(proto, "HTTP/") {
+		return "", "", "", false
+	}
+	for i := 0; i < len(method); i++ {
+		if c := method[i]; (c < 'A' || c > 'Z') && c != '-' && c != '_' {
+			return "", "", "", false
+		}
+	}
+	return method, target, proto, true
+}
+
+/*
+ * readBody reads the body of a request with header from r, up to
+ * maxBodyBytes, and skips the rest.
+ */
+func readBody(r *bufio.Reader, header http.Header) ([]byte, error) {
+	var body io.Reader
+	if te := header.Get("Transfer-Encoding"); strings.Contains(strings.ToLower(te), "chunked") {
+		body = httputil.NewChunkedReader(r)
+	} else if n, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64); err == nil && n > 0 {
+		body = io.LimitReader(r, n)
+	} else {
+		return nil, nil
+	}
+	data, err := io.ReadAll(io.LimitReader(body, maxBodyBytes))
+	/* the rest of a large body, to get to the next request */
+	if _, cerr := io.Copy(io.Discard, body); err == nil {
+		err = cerr
+	}
+	if lr, ok := body.(*io.LimitedReader); ok && lr.N > 0 && err == nil {
+		err = io.ErrUnexpectedEOF
+	}
+	return data, err
+}
+
+/*
+ * stream parses the HTTP/1.x requests of a reassembled stream. Streams
+ * that are not HTTP requests, responses included, were emptied by the
+ * assembler after their first line. Parsing is lenient: a line that is
+ * not a request line is skipped, so a malformed request costs only
+ * itself, not the rest of the connection.
+ */
+func (s *pcapScanner) stream(st *tcpStream) {
+	if len(st.data) == 0 {
+		return
+	}
+	cr := &countingReader{r: bytes.NewReader(st.data)}
+	br := bufio.NewReader(cr)
+	tp := textproto.NewReader(br)
+	for {
+		offset := cr.n - br.Buffered()
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		method, target, proto, ok := parseRequestLine(line)
+		if !ok {
+			continue
+		}
+		/* on malformed headers, check those read so far and resync */
+		mime, herr := tp.ReadMIMEHeader()
+		header := http.Header(mime)
+		if header == nil {
+			header = http.Header{}
+		}
+		var body []byte
+		if herr == nil {
+			body, err = readBody(br, header)
+		}
+		req := &http.Request{Method: method, RequestURI: target, Proto: proto, Header: header, Host: header.Get("Host")}
+		header.Del("Host")
+
+		ts := st.timeAt(offset)
+		for _, param := range httpparams.Request(req, body) {
+			typ, fingerprint := httpparams.Detect(param.Value, s.detect)
+			if typ == "" {
+				continue
+			}
+			s.report(pcapFinding{
+				Time:        ts,
+				Proto:       "tcp",
+				SrcIP:       st.key.src.Addr().String(),
+				SrcPort:     st.key.src.Port(),
+				DstIP:       st.key.dst.Addr().String(),
+				DstPort:     st.key.dst.Port(),
+				Method:      method,
+				URI:         target,
+				Location:    param.Location,
+				Value:       param.Value,
+				Type:        typ,
+				Fingerprint: fingerprint,
+			})
+		}
+		if err != nil {
+			/* truncated body, the capture ends mid request */
+			return
+		}
+	}
+}
+
+func (s *pcapScanner) scan(r io.Reader) error {
+	pr, err := newPcapReader(r)
+	if err != nil {
+		return err
+	}
+	a := newAssembler(s.stream)
+	for {
+		p, err := pr.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			a.flushAll()
+			return err
+		}
+		if seg, ok := decodeTCP(p); ok {
+			a.add(seg)
+		}
+	}
+	a.flushAll()
+	return nil
+}
+
+func writePcapFinding(w io.Writer, f pcapFinding) error {
+	fingerprint := f.Fingerprint
+	if fingerprint == "" {
+		fingerprint = "-"
+	}
+	src := fmt.Sprintf("%s:%d", f.SrcIP, f.SrcPort)
+	dst := fmt.Sprintf("%s:%d", f.DstIP, f.DstPort)
+	if strings.Contains(f.SrcIP, ":") {
+		src = fmt.Sprintf("[%s]:%d", f.SrcIP, f.SrcPort)
+		dst = fmt.Sprintf("[%s]:%d", f.DstIP, f.DstPort)
+	}
+	_, err := fmt.Fprintf(w, "%s\t%s %s -> %s\t%s\t%s\t%s %s\t%s\t%q\n",
+		f.Time.Format(time.RFC3339Nano), f.Proto, src, dst, strings.ToUpper(f.Type), fingerprint,
+		f.Method, f.URI, f.Location, f.Value)
+	return err
+}
+
+/*
+ * runPcap checks the HTTP requests of capture files. Same exit status as
+ * scan.
+ */
+func runPcap(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("pcap", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: libinjection pcap [flags] [file ...]")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Reassembles the TCP streams of pcap or pcapng files, or stdin, and checks")
+		fmt.Fprintln(stderr, "every parameter, header and body of the HTTP/1.x requests in them.")
+		fmt.Fprintln(stderr, "Findings are printed as each connection ends, in time order within it.")
+		fmt.Fprintln(stderr)
+		fs.PrintDefaults()
+	}
+	format := fs.String("format", "text", "output `format`: text or json (JSON lines)")
+	detectors := fs.String("detect", "sqli,xss,crlf,xxe", "comma separated `detectors`: sqli, xss, crlf, xxe")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	enc := json.NewEncoder(stdout)
+	enc.SetEscapeHTML(false)
+	findings := 0
+	var werr error
+	s := &pcapScanner{report: func(f pcapFinding) {
+		findings++
+		if werr != nil {
+			return
+		}
+		if *format == "json" {
+			werr = enc.Encode(f)
+		} else {
+			werr = writePcapFinding(stdout, f)
+		}
+	}}
+	for _, name := range strings.Split(*detectors, ",") {
+		switch strings.TrimSpace(name) {
+		case "sqli":
+			s.detect.SQLi = true
+		case "xss":
+			s.detect.XSS = true
+		case "crlf":
+			s.detect.CRLF = true
+		case "xxe":
+			s.detect.XXE = true
+		default:
+			fmt.Fprintf(stderr, "libinjection pcap: unknown detector %q\n", name)
+			return 2
+		}
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "libinjection pcap: unknown format %q\n", *format)
+		return 2
+	}
+
+	status := 0
+	if fs.NArg() == 0 {
+		if err := s.scan(bufio.NewReader(stdin)); err != nil {
+			fmt.Fprintln(stderr, "libinjection pcap:", err)
+			status = 2
+		}
+	}
+	for _, name := range fs.Args() {
+		f, err := os.Open(name)
+		if err == nil {
+			err = s.scan(bufio.NewReader(f))
+			f.Close()
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "libinjection pcap: %s: %v\n", name, err)
+			status = 2
+		}
+	}
+
+	if werr != nil {
+		fmt.Fprintln(stderr, "libinjection pcap:", werr)
+		return 2
+	}
+
+	if status == 0 && findings > 0 {
+		status = 1
+	}
+	return status
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	libinjection "github.com/jptosso/libinjection-go"
+)
+
+var quoteFlags = map[string]int{
+	"none":   libinjection.FLAG_QUOTE_NONE,
+	"single": libinjection.FLAG_QUOTE_SINGLE,
+	"double": libinjection.FLAG_QUOTE_DOUBLE,
+}
+
+var dialectFlags = map[string]int{
+	"ansi":  libinjection.FLAG_SQL_ANSI,
+	"mysql": libinjection.FLAG_SQL_MYSQL,
+}
+
+/*
+ * sqliPasses returns the passes to run for the -quote and -dialect flags,
+ * nil when both are auto, which runs the passes libinjection picks.
+ */
+func sqliPasses(quote, dialect string) ([]int, error) {
+	if quote == "auto" && dialect == "auto" {
+		return nil, nil
+	}
+
+	quotes := []int{libinjection.FLAG_QUOTE_NONE, libinjection.FLAG_QUOTE_SINGLE, libinjection.FLAG_QUOTE_DOUBLE}
+	if quote != "auto" {
+		q, ok := quoteFlags[quote]
+		if !ok {
+			return nil, fmt.Errorf("unknown quote context %q", quote)
+		}
+		quotes = []int{q}
+	}
+	dialects := []int{libinjection.FLAG_SQL_ANSI, libinjection.FLAG_SQL_MYSQL}
+	if dialect != "auto" {
+		d, ok := dialectFlags[dialect]
+		if !ok {
+			return nil, fmt.Errorf("unknown dialect %q", dialect)
+		}
+		dialects = []int{d}
+	}
+
+	var passes []int
+	for _, q := range quotes {
+		for _, d := range dialects {
+			passes = append(passes, q|d)
+		}
+	}
+	return passes, nil
+}
+
+/*
+ * scanResult is one output record, also the JSON lines format.
+ */
+type scanResult struct {
+	Source      string `json:"source"`
+	Line        int    `json:"line"`
+	Input       string `json:"input"`
+	Decoded     string `json:"decoded,omitempty"` /* only when decoding changed the input */
+	Type        string `json:"type"`
+	Injection   bool   `json:"injection"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Pass        string `json:"pass,omitempty"`
+}
+
+type scanner struct {
+	xss      bool
+	passes   []int
+	decoders []decoder
+	hits     bool
+	jsonOut  *json.Encoder
+	out      io.Writer
+	found    int
+}
+
+func (s *scanner) check(input string) scanResult {
+	decoded := decode(input, s.decoders)
+	r := scanResult{Input: input}
+	if decoded != input {
+		r.Decoded = decoded
+	}
+
+	if s.xss {
+		r.Type = "xss"
+		result := libinjection.DetectXSS(decoded)
+		r.Injection = result.Injection
+		if result.Injection {
+			r.Pass = libinjection.XSSFlagsString(result.Flags)
+		}
+		return r
+	}
+
+	r.Type = "sqli"
+	var result libinjection.Result
+	if s.passes == nil {
+		result = libinjection.DetectSQLi(decoded)
+	} else {
+		for _, flags := range s.passes {
+			if result = libinjection.DetectSQLiFlags(decoded, flags); result.Injection {
+				break
+			}
+		}
+	}
+	r.Injection = result.Injection
+	r.Fingerprint = result.Fingerprint
+	if decoded != "" {
+		r.Pass = libinjection.SQLiFlagsString(result.Flags)
+	}
+	return r
+}
+
+func (s *scanner) write(r scanResult) error {
+	if s.hits && !r.Injection {
+		return nil
+	}
+	if s.jsonOut != nil {
+		return s.jsonOut.Encode(r)
+	}
+
+	verdict := "ok"
+	if r.Injection {
+		verdict = strings.ToUpper(r.Type)
+	}
+	fingerprint := r.Fingerprint
+	if fingerprint == "" {
+		fingerprint = "-"
+	}
+	pass := r.Pass
+	if pass == "" {
+		pass = "-"
+	}
+	_, err := fmt.Fprintf(s.out, "%s:%d\t%s\t%s\t%s\t%q\n", r.Source, r.Line, verdict, fingerprint, pass, r.Input)
+	return err
+}
+
+func (s *scanner) scan(name string, r io.Reader) error {
+	br := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		text, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if text == "" && err == io.EOF {
+			return nil
+		}
+		text = strings.TrimSuffix(strings.TrimSuffix(text, "\n"), "\r")
+		if text != "" {
+			result := s.check(text)
+			result.Source = name
+			result.Line = line
+			if result.Injection {
+				s.found++
+			}
+			if werr := s.write(result); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+/*
+ * runScan checks every line of the files, or stdin, for SQLi or XSS. The
+ * exit status is 0 when nothing was found, 1 when something was and 2 on
+ * errors, so scripts can tell a clean list from one with hits.
+ */
+func runScan(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("scan", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: libinjection scan [flags] [file ...]")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Checks every line of the files, or stdin, and prints")
+		fmt.Fprintln(stderr, "source:line, verdict, fingerprint, pass and input.")
+		fmt.Fprintln(stderr)
+		fs.PrintDefaults()
+	}
+	format := fs.String("format", "text", "output `format`: text or json (JSON lines)")
+	dialect := fs.String("dialect", "auto", "SQL `dialect`: auto, ansi or mysql, not with -xss")
+	quote := fs.String("quote", "auto", "quote `context` the input is injected in: auto, none, single or double, not with -xss")
+	decoding := fs.String("decode", "none", "comma separated `decoders` applied in order: url, path, html or none")
+	xss := fs.Bool("xss", false, "detect XSS instead of SQLi")
+	hits := fs.Bool("hits", false, "only print detections")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	if *xss && (*quote != "auto" || *dialect != "auto") {
+		fmt.Fprintln(stderr, "libinjection scan: -quote and -dialect are for SQLi, not -xss")
+		return 2
+	}
+	s := &scanner{xss: *xss, hits: *hits, out: stdout}
+	var err error
+	if s.passes, err = sqliPasses(*quote, *dialect); err != nil {
+		fmt.Fprintln(stderr, "libinjection scan:", err)
+		return 2
+	}
+	if s.decoders, err = parseDecoders(*decoding); err != nil {
+		fmt.Fprintln(stderr, "libinjection scan:", err)
+		return 2
+	}
+	switch *format {
+	case "text":
+	case "json":
+		s.jsonOut = json.NewEncoder(stdout)
+		s.jsonOut.SetEscapeHTML(false)
+	default:
+		fmt.Fprintf(stderr, "libinjection scan: unknown format %q\n", *format)
+		return 2
+	}
+
+	status := 0
+	if fs.NArg() == 0 {
+		if err := s.scan("-", stdin); err != nil {
+			fmt.Fprintln(stderr, "libinjection scan:", err)
+			return 2
+		}
+	}
+	for _, name := range fs.Args() {
+		var err error
+		if name == "-" {
+			err = s.scan(name, stdin)
+		} else {
+			var f *os.File
+			if f, err = os.Open(name); err == nil {
+				err = s.scan(name, f)
+				f.Close()
+			}
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, "libinjection scan:", err)
+			status = 2
+		}
+	}
+
+	if status == 0 && s.found > 0 {
+		status = 1
+	}
+	return status
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestScanText(t *testing.T) {
+	input := "hello\n1 UNION SELECT 1\n\nadmin' OR 1=1--\r\n"
+	var stdout, stderr bytes.Buffer
+	if status := run([]string{"scan"}, strings.NewReader(input), &stdout, &stderr); status != 1 {
+		t.Fatalf("expected status 1, got %d: %s", status, stderr.String())
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	expected := []string{
+		"-:1\tok\t",
+		"-:2\tSQLI\t1UE1\tnone/ansi\t",
+		"-:4\tSQLI\ts&1c\tsingle/ansi\t",
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines, got:\n%s", len(expected), stdout.String())
+	}
+	for i, line := range lines {
+		if !strings.HasPrefix(line, expected[i]) {
+			t.Errorf("line %d: expected prefix %q, got %q", i, expected[i], line)
+		}
+	}
+}
+
+func TestScanJSON(t *testing.T) {
+	input := "%3Cscript%3Ealert(1)%3C/script%3E\nhello+world\n"
+	var stdout, stderr bytes.Buffer
+	status := run([]string{"scan", "-xss", "-decode", "url", "-format", "json", "-hits"}, strings.NewReader(input), &stdout, &stderr)
+	if status != 1 {
+		t.Fatalf("expected status 1, got %d: %s", status, stderr.String())
+	}
+
+	var r scanResult
+	if err := json.Unmarshal(stdout.Bytes(), &r); err != nil {
+		t.Fatalf("%v: %s", err, stdout.String())
+	}
+	if !r.Injection || r.Type != "xss" || r.Pass != "data" || r.Decoded != "<script>alert(1)</script>" || r.Line != 1 {
+		t.Errorf("unexpected result %+v", r)
+	}
+}
+
+func TestScanPasses(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	status := run([]string{"scan", "-quote", "none", "-dialect", "ansi"}, strings.NewReader("admin' OR 1=1--\n"), &stdout, &stderr)
+	if status != 0 {
+		t.Errorf("expected no detection without the single quote pass, got %d: %s", status, stdout.String())
+	}
+
+	stdout.Reset()
+	status = run([]string{"scan", "-quote", "single"}, strings.NewReader("admin' OR 1=1--\n"), &stdout, &stderr)
+	if status != 1 || !strings.Contains(stdout.String(), "single/ansi") {
+		t.Errorf("expected a detection in the single quote pass, got %d: %s", status, stdout.String())
+	}
+
+	if status := run([]string{"scan", "-dialect", "oracle"}, strings.NewReader(""), &stdout, &stderr); status != 2 {
+		t.Errorf("expected status 2 for an unknown dialect, got %d", status)
+	}
+	if status := run([]string{"scan", "-xss", "-quote", "single"}, strings.NewReader(""), &stdout, &stderr); status != 2 {
+		t.Errorf("expected status 2 for -quote with -xss, got %d", status)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	ds, err := parseDecoders("url,html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]string{
+		"a+b%20c":           "a b c",
+		"100%":              "100%",
+		"%zz%4":             "%zz%4",
+		"%26lt%3Bscript%3E": "<script>",
+	}
+	for input, expected := range tests {
+		if actual := decode(input, ds); actual != expected {
+			t.Errorf("%q: expected %q, got %q", input, expected, actual)
+		}
+	}
+	if _, err := parseDecoders("rot13"); err == nil {
+		t.Error("expected an error for an unknown decoder")
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"net"
+	"sort"
+	"time"
+)
+
+const (
+	/* data kept per direction of a connection, the rest is dropped */
+	maxStreamBytes = 16 << 20
+	/* out of order segments kept waiting for a gap to be filled */
+	maxPendingSegments = 1024
+	/* a stream whose first line is longer is not HTTP */
+	maxRequestLine = 8 << 10
+	/* past these, the oldest streams are closed early */
+	maxOpenStreams   = 1 << 16
+	maxBufferedBytes = 512 << 20
+)
+
+/*
+ * endpoint is an address and a port. It is comparable, to key flows, so
+ * IPv4 addresses are kept in their IPv4-mapped IPv6 form.
+ */
+type endpoint struct {
+	ip   [16]byte
+	port uint16
+}
+
+func newEndpoint(ip net.IP, port uint16) endpoint {
+	e := endpoint{port: port}
+	copy(e.ip[:], ip.To16())
+	return e
+}
+
+func (e endpoint) Addr() net.IP {
+	return net.IP(e.ip[:])
+}
+
+func (e endpoint) Port() uint16 {
+	return e.port
+}
+
+/*
+ * flowKey is one direction of a TCP connection.
+ */
+type flowKey struct {
+	src, dst endpoint
+}
+
+/*
+ * streamMark records that the stream data from offset on arrived at ts,
+ * to give each request the time of its first packet.
+ */
+type streamMark struct {
+	offset int
+	ts     time.Time
+}
+
+/*
+ * tcpStream is the reassembled data of one direction of a connection.
+ */
+type tcpStream struct {
+	key     flowKey
+	started bool
+	next    uint32 /* next expected sequence number */
+	data    []byte
+	marks   []streamMark
+	pending []tcpSegment
+	first   time.Time
+	/* the first line was read and is a request line */
+	request bool
+	/* not a stream of HTTP requests, nothing is kept */
+	skipped bool
+	/* payload bytes of the pending segments */
+	pendingBytes int
+	elem         *list.Element
+}
+
+/*
+ * seqDiff is a - b, with sequence number wrap around.
+ */
+func seqDiff(a, b uint32) int32 {
+	return int32(a - b)
+}
+
+func (s *tcpStream) append(ts time.Time, payload []byte) {
+	if len(payload) == 0 || len(s.data) >= maxStreamBytes || s.skipped {
+		return
+	}
+	if room := maxStreamBytes - len(s.data); len(payload) > room {
+		payload = payload[:room]
+	}
+	s.marks = append(s.marks, streamMark{offset: len(s.data), ts: ts})
+	s.data = append(s.data, payload...)
+
+	/* responses and other protocols are dropped as soon as that is known */
+	if !s.request {
+		if end := bytes.IndexByte(s.data, '\n'); end != -1 {
+			s.request = isRequestLine(s.data[:end])
+			s.skipped = !s.request
+		} else {
+			s.skipped = len(s.data) > maxRequestLine
+		}
+		if s.skipped {
+			s.data, s.marks, s.pending, s.pendingBytes = nil, nil, nil, 0
+		}
+	}
+}
+
+/*
+ * isRequestLine tells if line is an HTTP/1.x request line, like
+ * "GET / HTTP/1.1", as parseRequestLine reads it.
+ */
+func isRequestLine(line []byte) bool {
+	_, _, _, ok := parseRequestLine(string(bytes.TrimSuffix(line, []byte("\r"))))
+	return ok
+}
+
+/*
+ * size is the memory the stream's data takes.
+ */
+func (s *tcpStream) size() int {
+	return len(s.data) + s.pendingBytes
+}
+
+/*
+ * timeAt is the time the byte at offset was captured.
+ */
+func (s *tcpStream) timeAt(offset int) time.Time {
+	i := sort.Search(len(s.marks), func(i int) bool { return s.marks[i].offset > offset })
+	if i == 0 {
+		return s.first
+	}
+	return s.marks[i-1].ts
+}
+
+/*
+ * add puts the payload of seg in place: in order data is appended, data
+ * already seen (retransmissions) is trimmed, and data after a gap waits
+ * for the gap to be filled.
+ */
+func (s *tcpStream) add(seg tcpSegment) {
+	if !s.started {
+		s.started = true
+		s.first = seg.ts
+		s.next = seg.seq
+		if seg.syn {
+			s.next++
+		}
+	} else if seg.syn {
+		return
+	}
+	if len(seg.payload) == 0 || s.skipped {
+		return
+	}
+
+	diff := seqDiff(seg.seq, s.next)
+	if diff > 0 {
+		if len(s.pending) < maxPendingSegments {
+			s.pending = append(s.pending, seg)
+			s.pendingBytes += len(seg.payload)
+		}
+		return
+	}
+	s.accept(seg)
+	s.drain()
+}
+
+/*
+ * accept appends the part of seg that starts at or after next.
+ */
+func (s *tcpStream) accept(seg tcpSegment) bool {
+	skip := -seqDiff(seg.seq, s.next)
+	if skip < 0 || int(skip) >= len(seg.payload) {
+		return false
+	}
+	payload := seg.payload[skip:]
+	s.append(seg.ts, payload)
+	s.next += uint32(len(payload))
+	return true
+}
+
+/*
+ * drain appends the pending segments that have become in order.
+ */
+func (s *tcpStream) drain() {
+	for progress := true; progress; {
+		progress = false
+		kept := s.pending[:0]
+		for _, seg := range s.pending {
+			if seqDiff(seg.seq, s.next) > 0 {
+				kept = append(kept, seg)
+				continue
+			}
+			s.pendingBytes -= len(seg.payload)
+			if s.accept(seg) {
+				progress = true
+			}
+			if s.skipped {
+				return
+			}
+		}
+		s.pending = kept
+	}
+}
+
+/*
+ * flush gives up on missing data: packets lost by the sensor never come,
+ * so skip each gap and keep what follows.
+ */
+func (s *tcpStream) flush() {
+	for len(s.pending) > 0 {
+		lowest := 0
+		for i, seg := range s.pending {
+			if seqDiff(seg.seq, s.pending[lowest].seq) < 0 {
+				lowest = i
+			}
+		}
+		s.next = s.pending[lowest].seq
+		s.drain()
+	}
+}
+
+/*
+ * assembler tracks every stream of a capture, calls done for each stream
+ * once it is closed, and for the rest at the end. When too many streams
+ * are open or too much data is buffered, the oldest streams are closed
+ * before their end, as if the capture ended for them.
+ */
+type assembler struct {
+	streams map[flowKey]*tcpStream
+	done    func(*tcpStream)
+	/* the open streams, oldest first */
+	order    *list.List
+	buffered int
+	/* maxOpenStreams and maxBufferedBytes, lowered by tests */
+	maxStreams int
+	maxBytes   int
+}
+
+func newAssembler(done func(*tcpStream)) *assembler {
+	return &assembler{
+		streams:    map[flowKey]*tcpStream{},
+		done:       done,
+		order:      list.New(),
+		maxStreams: maxOpenStreams,
+		maxBytes:   maxBufferedBytes,
+	}
+}
+
+func (a *assembler) add(seg tcpSegment) {
+	key := flowKey{src: seg.src, dst: seg.dst}
+	s := a.streams[key]
+	if s != nil && seg.syn && s.started && len(s.data) > 0 {
+		/* port reuse: a new connection */
+		a.close(s)
+		s = nil
+	}
+	if s == nil {
+		if seg.fin || seg.rst {
+			return
+		}
+		for len(a.streams) >= a.maxStreams {
+			a.close(a.order.Front().Value.(*tcpStream))
+		}
+		s = &tcpStream{key: key}
+		s.elem = a.order.PushBack(s)
+		a.streams[key] = s
+	}
+	before := s.size()
+	s.add(seg)
+	a.buffered += s.size() - before
+	if seg.fin || seg.rst {
+		a.close(s)
+	}
+	for a.buffered > a.maxBytes {
+		a.close(a.order.Front().Value.(*tcpStream))
+	}
+}
+
+func (a *assembler) close(s *tcpStream) {
+	delete(a.streams, s.key)
+	a.order.Remove(s.elem)
+	a.buffered -= s.size()
+	s.flush()
+	a.done(s)
+}
+
+/*
+ * flushAll closes the streams still open at the end of the capture, oldest
+ * first.
+ */
+func (a *assembler) flushAll() {
+	for a.order.Len() > 0 {
+		a.close(a.order.Front().Value.(*tcpStream))
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jptosso/libinjection-go/internal/strutil"
+	"github.com/jptosso/libinjection-go/train"
+)
+
+/*
+ * trainLabels maps the first field of a labeled corpus line to malicious
+ * or benign.
+ */
+var trainLabels = map[string]bool{
+	"1":         true,
+	"malicious": true,
+	"sqli":      true,
+	"0":         false,
+	"benign":    false,
+}
+
+/*
+ * trainReader feeds a trainer the decoded inputs of corpora.
+ */
+type trainReader struct {
+	trainer  *train.Trainer
+	decoders []decoder
+}
+
+/*
+ * read adds every line of r with the given label, or, when label is nil,
+ * every label<TAB>input line.
+ */
+func (t *trainReader) read(name string, r io.Reader, label *bool) error {
+	br := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		text, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		text = strings.TrimSuffix(strings.TrimSuffix(text, "\n"), "\r")
+		if text != "" {
+			malicious := false
+			if label != nil {
+				malicious = *label
+			} else {
+				field, input, found := strutil.Cut(text, "\t")
+				var ok bool
+				if malicious, ok = trainLabels[strings.ToLower(field)]; !ok || !found {
+					return fmt.Errorf("%s:%d: expected label<TAB>input", name, line)
+				}
+				text = input
+			}
+			t.trainer.Add(decode(text, t.decoders), malicious)
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+func (t *trainReader) readFile(name string, label *bool) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return t.read(name, f, label)
+}
+
+/*
+ * trainJSON is the JSON report, with the metrics computed.
+ */
+type trainJSON struct {
+	Samples   int             `json:"samples"`
+	Malicious int             `json:"malicious"`
+	Benign    int             `json:"benign"`
+	Baseline  trainMetrics    `json:"baseline"`
+	Proposals []trainProposal `json:"proposals"`
+	Combined  trainMetrics    `json:"combined"`
+}
+
+type trainMetrics struct {
+	TruePositives  int     `json:"true_positives"`
+	FalsePositives int     `json:"false_positives"`
+	FalseNegatives int     `json:"false_negatives"`
+	TrueNegatives  int     `json:"true_negatives"`
+	Precision      float64 `json:"precision"`
+	Recall         float64 `json:"recall"`
+}
+
+type trainProposal struct {
+	Action            string       `json:"action"`
+	Fingerprint       string       `json:"fingerprint"`
+	Malicious         int          `json:"malicious"`
+	Benign            int          `json:"benign"`
+	Metrics           trainMetrics `json:"metrics"`
+	MaliciousExamples []string     `json:"malicious_examples,omitempty"`
+	BenignExamples    []string     `json:"benign_examples,omitempty"`
+}
+
+func withRates(m train.Metrics) trainMetrics {
+	return trainMetrics{m.TruePositives, m.FalsePositives, m.FalseNegatives, m.TrueNegatives, m.Precision(), m.Recall()}
+}
+
+func writeTrainReport(w io.Writer, report *train.Report) {
+	fmt.Fprintf(w, "%d samples, %d malicious, %d benign\n", report.Samples, report.Malicious, report.Benign)
+	fmt.Fprintf(w, "baseline: precision %.4f, recall %.4f\n", report.Baseline.Precision(), report.Baseline.Recall())
+	for _, p := range report.Proposals {
+		fmt.Fprintf(w, "%-6s %-5s  %d malicious, %d benign  precision %.4f, recall %.4f\n",
+			p.Action, p.Fingerprint, p.Malicious, p.Benign, p.Metrics.Precision(), p.Metrics.Recall())
+		for _, example := range p.MaliciousExamples {
+			fmt.Fprintf(w, "\tmalicious %q\n", example)
+		}
+		for _, example := range p.BenignExamples {
+			fmt.Fprintf(w, "\tbenign    %q\n", example)
+		}
+	}
+	fmt.Fprintf(w, "combined: precision %.4f, recall %.4f\n", report.Combined.Precision(), report.Combined.Recall())
+}
+
+/*
+ * runTrain proposes fingerprints to add to or remove from the database.
+ */
+func runTrain(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("train", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: libinjection train [flags] [corpus ...]")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Fingerprints labeled samples under every flag combination and")
+		fmt.Fprintln(stderr, "proposes fingerprints to add to or remove from the database, with")
+		fmt.Fprintln(stderr, "the precision and recall of each change. Corpus lines, from the files")
+		fmt.Fprintln(stderr, "or stdin, are label<TAB>input with label malicious (or 1) or benign")
+		fmt.Fprintln(stderr, "(or 0). -malicious and -benign read unlabeled files, one input a line.")
+		fmt.Fprintln(stderr)
+		fs.PrintDefaults()
+	}
+	malicious := fs.String("malicious", "", "`file` of malicious inputs, one per line")
+	benign := fs.String("benign", "", "`file` of benign inputs, one per line")
+	decoding := fs.String("decode", "none", "comma separated `decoders` applied in order: url, path, html or none")
+	minSupport := fs.Int("min-support", 2, "the least `number` of samples a change must fix")
+	minPrecision := fs.Float64("min-precision", 0.95, "the least `share` of the samples a change flips that it must fix")
+	format := fs.String("format", "text", "output `format`: text or json")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "libinjection train: unknown format %q\n", *format)
+		return 2
+	}
+
+	t := &trainReader{trainer: &train.Trainer{}}
+	var err error
+	if t.decoders, err = parseDecoders(*decoding); err != nil {
+		fmt.Fprintln(stderr, "libinjection train:", err)
+		return 2
+	}
+	yes, no := true, false
+	if *malicious != "" {
+		err = t.readFile(*malicious, &yes)
+	}
+	if err == nil && *benign != "" {
+		err = t.readFile(*benign, &no)
+	}
+	if err == nil && fs.NArg() == 0 && *malicious == "" && *benign == "" {
+		err = t.read("-", stdin, nil)
+	}
+	for _, name := range fs.Args() {
+		if err != nil {
+			break
+		}
+		if name == "-" {
+			err = t.read(name, stdin, nil)
+		} else {
+			err = t.readFile(name, nil)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "libinjection train:", err)
+		return 2
+	}
+
+	report := t.trainer.Report(train.Options{MinSupport: *minSupport, MinPrecision: *minPrecision})
+	if *format == "text" {
+		writeTrainReport(stdout, &report)
+		return 0
+	}
+	out := trainJSON{
+		Samples:   report.Samples,
+		Malicious: report.Malicious,
+		Benign:    report.Benign,
+		Baseline:  withRates(report.Baseline),
+		Proposals: []trainProposal{},
+		Combined:  withRates(report.Combined),
+	}
+	for _, p := range report.Proposals {
+		out.Proposals = append(out.Proposals, trainProposal{
+			p.Action, p.Fingerprint, p.Malicious, p.Benign, withRates(p.Metrics), p.MaliciousExamples, p.BenignExamples,
+		})
+	}
+	enc := json.NewEncoder(stdout)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(&out); err != nil {
+		fmt.Fprintln(stderr, "libinjection train:", err)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTrain(t *testing.T) {
+	dir := t.TempDir()
+	malicious := filepath.Join(dir, "malicious.txt")
+	os.WriteFile(malicious, []byte("select%20name%20from%20users\nselect%20id%20from%20sessions\n"), 0o644)
+	corpus := "malicious\t1 UNION SELECT 1\n" +
+		"benign\tbob' --\n" +
+		"0\talice' --\n" +
+		"1\tselect pass from accounts\n"
+
+	var stdout, stderr bytes.Buffer
+	status := run([]string{"train", "-malicious", malicious, "-decode", "url", "-format", "json", "-"}, strings.NewReader(corpus), &stdout, &stderr)
+	if status != 0 {
+		t.Fatalf("expected status 0, got %d: %s", status, stderr.String())
+	}
+	var report trainJSON
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+		t.Fatalf("%v: %s", err, stdout.String())
+	}
+	if report.Samples != 6 || report.Malicious != 4 || report.Benign != 2 {
+		t.Errorf("unexpected totals %+v", report)
+	}
+	var proposals []string
+	for _, p := range report.Proposals {
+		proposals = append(proposals, p.Action+" "+p.Fingerprint)
+	}
+	if strings.Join(proposals, ",") != "add Enkn,remove sc" {
+		t.Errorf("unexpected proposals %v", proposals)
+	}
+	if report.Combined.Precision != 1 || report.Combined.Recall != 1 {
+		t.Errorf("unexpected combined metrics %+v", report.Combined)
+	}
+
+	stdout.Reset()
+	if status := run([]string{"train"}, strings.NewReader("maybe\tx\n"), &stdout, &stderr); status != 2 {
+		t.Errorf("expected status 2 for an unknown label, got %d", status)
+	}
+}
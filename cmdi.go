@@ -0,0 +1,631 @@
+package libinjection
+
+import (
+	"strings"
+)
+
+/*
+ * OS command injection, in the footsteps of the SQLi detector: the input
+ * is tokenized as POSIX sh or Windows cmd syntax, tokens are folded into
+ * a fingerprint of at most CMDI_MAX_TOKENS and the fingerprint is looked
+ * up in a table of command injection fingerprints, then checked against a
+ * whitelist of natural language look-alikes.
+ *
+ * The input is assumed to be an argument of a command, so it starts in
+ * argument position and only becomes dangerous when it ends the command
+ * and starts another one. Like for SQLi, the quote context is either
+ * known, FLAG_QUOTE_NONE, FLAG_QUOTE_SINGLE or FLAG_QUOTE_DOUBLE, or is
+ * guessed from the quotes in the input.
+ */
+
+const (
+	FLAG_SHELL_POSIX = 32 /* 1 << 5 */
+	FLAG_SHELL_CMD   = 64 /* 1 << 6, Windows cmd.exe */
+
+	CMDI_MAX_TOKENS = 5
+
+	//cmdi token types
+	CMDI_TYPE_WORD     = 'w' /* argument */
+	CMDI_TYPE_COMMAND  = 'c' /* known command, or path to one */
+	CMDI_TYPE_UNKNOWN  = 'u' /* other word in command position */
+	CMDI_TYPE_VARIABLE = 'v' /* variable in command position */
+	CMDI_TYPE_KEYWORD  = 'k' /* if, then, for, do, ... */
+	CMDI_TYPE_SEQUENCE = ';' /* ; & new line */
+	CMDI_TYPE_LOGIC    = '&' /* && || */
+	CMDI_TYPE_PIPE     = '|'
+	CMDI_TYPE_SUBST    = 'E' /* $( ` <( >( */
+	CMDI_TYPE_GROUP    = '(' /* subshell or group */
+	CMDI_TYPE_CLOSE    = ')' /* end of a substitution or group */
+	CMDI_TYPE_REDIRECT = '>' /* > >> < 2>&1 ... */
+)
+
+type cmdiToken struct {
+	typ byte
+	val string /* words without quotes and escapes */
+	raw string /* as in the input */
+}
+
+type cmdiState struct {
+	s     string
+	pos   int
+	flags int
+	/* open double quotes, substitutions and groups, innermost last */
+	stack  []byte
+	cmdpos bool /* the next word is a command */
+	tokens []cmdiToken
+	/* set when the current word contains a quoted part */
+	quoted bool
+}
+
+func newCmdiState(s string, flags int) *cmdiState {
+	if flags&(FLAG_SHELL_POSIX|FLAG_SHELL_CMD) == 0 {
+		flags |= FLAG_SHELL_POSIX
+	}
+	if flags&(FLAG_QUOTE_NONE|FLAG_QUOTE_SINGLE|FLAG_QUOTE_DOUBLE) == 0 {
+		flags |= FLAG_QUOTE_NONE
+	}
+	return &cmdiState{s: s, flags: flags}
+}
+
+func (st *cmdiState) posix() bool {
+	return st.flags&FLAG_SHELL_POSIX != 0
+}
+
+func (st *cmdiState) top() byte {
+	if len(st.stack) == 0 {
+		return CHAR_NULL
+	}
+	return st.stack[len(st.stack)-1]
+}
+
+func (st *cmdiState) pop() {
+	st.stack = st.stack[:len(st.stack)-1]
+}
+
+/*
+ * operator emits an operator token of n bytes. Operators after which a
+ * command is expected set cmdpos.
+ */
+func (st *cmdiState) operator(typ byte, n int) {
+	st.tokens = append(st.tokens, cmdiToken{typ: typ, val: st.s[st.pos : st.pos+n], raw: st.s[st.pos : st.pos+n]})
+	st.pos += n
+	switch typ {
+	case CMDI_TYPE_SEQUENCE, CMDI_TYPE_LOGIC, CMDI_TYPE_PIPE, CMDI_TYPE_SUBST, CMDI_TYPE_GROUP:
+		st.cmdpos = true
+	default:
+		st.cmdpos = false
+	}
+}
+
+/*
+ * ifsLen returns the length of $IFS or ${IFS...} at pos, 0 if there is
+ * none. Unquoted, it expands to white space.
+ */
+func (st *cmdiState) ifsLen(pos int) int {
+	s := st.s[pos:]
+	switch {
+	case strings.HasPrefix(s, "${IFS"):
+		if end := strings.IndexByte(s, '}'); end != -1 {
+			return end + 1
+		}
+	case strings.HasPrefix(s, "$IFS"):
+		return 4
+	}
+	return 0
+}
+
+/*
+ * word scans a word, the concatenation of unquoted, quoted and escaped
+ * parts, up to white space or an operator. It returns false when it
+ * stopped at a substitution inside double quotes, which the caller
+ * tokenizes before the word goes on.
+ */
+func (st *cmdiState) word(b *strings.Builder) bool {
+	s := st.s
+	for st.pos < len(s) {
+		ch := s[st.pos]
+		if st.top() == CHAR_DOUBLE {
+			switch {
+			case ch == CHAR_DOUBLE:
+				st.pop()
+				st.pos++
+			case st.posix() && ch == '\\' && st.pos+1 < len(s):
+				if strings.IndexByte("$`\"\\\n", s[st.pos+1]) == -1 {
+					b.WriteByte(ch)
+				}
+				b.WriteByte(s[st.pos+1])
+				st.pos += 2
+			case st.posix() && (ch == CHAR_TICK || strings.HasPrefix(s[st.pos:], "$(") && !strings.HasPrefix(s[st.pos:], "$((")):
+				return false
+			default:
+				b.WriteByte(ch)
+				st.pos++
+			}
+			continue
+		}
+
+		if st.posix() {
+			if n := st.ifsLen(st.pos); n > 0 {
+				return true
+			}
+			switch {
+			case ch == CHAR_SINGLE:
+				st.quoted = true
+				end := strings.IndexByte(s[st.pos+1:], CHAR_SINGLE)
+				if end == -1 {
+					b.WriteString(s[st.pos+1:])
+					st.pos = len(s)
+				} else {
+					b.WriteString(s[st.pos+1 : st.pos+1+end])
+					st.pos += end + 2
+				}
+				continue
+			case ch == '\\':
+				if st.pos+1 < len(s) && s[st.pos+1] != '\n' {
+					b.WriteByte(s[st.pos+1])
+				}
+				st.pos = imin(st.pos+2, len(s))
+				continue
+			case ch == '$' && st.pos+1 < len(s) && (s[st.pos+1] == '@' || s[st.pos+1] == '*'):
+				/* expand to nothing without arguments: who$@ami */
+				st.pos += 2
+				continue
+			case strings.HasPrefix(s[st.pos:], "$(("):
+				end := strings.Index(s[st.pos:], "))")
+				if end == -1 {
+					end = len(s) - st.pos - 2
+				}
+				b.WriteString(s[st.pos : st.pos+end+2])
+				st.pos += end + 2
+				continue
+			case ch == '$' && st.pos+1 < len(s) && s[st.pos+1] == '(':
+				return true
+			case strings.IndexByte(";&|()<>` \t\r\n", ch) != -1:
+				return true
+			}
+		} else {
+			switch {
+			case ch == '^':
+				if st.pos+1 < len(s) && s[st.pos+1] != '\n' {
+					b.WriteByte(s[st.pos+1])
+				}
+				st.pos = imin(st.pos+2, len(s))
+				continue
+			case strings.IndexByte("&|()<> \t\r\n;,", ch) != -1:
+				return true
+			}
+		}
+
+		if ch == CHAR_DOUBLE {
+			st.quoted = true
+			st.stack = append(st.stack, CHAR_DOUBLE)
+			st.pos++
+			continue
+		}
+		b.WriteByte(ch)
+		st.pos++
+	}
+	return true
+}
+
+/*
+ * isVariable tells if raw is nothing but a variable: $NAME, ${...},
+ * %NAME% or !NAME!.
+ */
+func isVariable(raw string, posix bool) bool {
+	if posix {
+		if strings.HasPrefix(raw, "${") {
+			return strings.IndexByte(raw, '}') == len(raw)-1
+		}
+		if len(raw) < 2 || raw[0] != '$' {
+			return false
+		}
+		return strlenspn(raw[1:], "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789_") == len(raw)-1
+	}
+	if len(raw) < 3 || (raw[0] != '%' && raw[0] != '!') || raw[len(raw)-1] != raw[0] {
+		return false
+	}
+	return strings.IndexByte(raw[1:len(raw)-1], raw[0]) == -1
+}
+
+/*
+ * commandName is the name of the program val runs: the base name, lower
+ * cased, without a Windows extension. Brace expansion, {cat,/etc/passwd},
+ * runs the first element.
+ */
+func commandName(val string) string {
+	if strings.HasPrefix(val, "{") && strings.IndexByte(val, ',') != -1 {
+		val = val[1:strings.IndexByte(val, ',')]
+	}
+	if i := strings.LastIndexAny(val, "/\\"); i != -1 {
+		val = val[i+1:]
+	}
+	val = strings.ToLower(val)
+	for _, ext := range []string{".exe", ".com", ".bat", ".cmd"} {
+		val = strings.TrimSuffix(val, ext)
+	}
+	return val
+}
+
+/*
+ * isPath tells if val is a path to a program: absolute, relative to the
+ * current or home directory, or a Windows drive or share.
+ */
+func isPath(val string) bool {
+	for _, prefix := range []string{"/", "./", "../", "~/", "\\", ".\\", "..\\"} {
+		if strings.HasPrefix(val, prefix) {
+			return true
+		}
+	}
+	return len(val) > 2 && val[1] == ':' && (val[2] == '\\' || val[2] == '/')
+}
+
+/*
+ * emitWord types the word just scanned: in command position it is a
+ * keyword, variable, command or unknown, else an argument.
+ */
+func (st *cmdiState) emitWord(val, raw string) {
+	if val == "" && !st.quoted {
+		return
+	}
+	typ := byte(CMDI_TYPE_WORD)
+	if st.cmdpos {
+		name := commandName(val)
+		switch {
+		case st.posix() && !st.quoted && cmdiKeywords[val] != 0:
+			typ = CMDI_TYPE_KEYWORD
+			/* fi, done, esac and } end a command, the others start one */
+			st.cmdpos = cmdiKeywords[val] == 'k'
+			st.tokens = append(st.tokens, cmdiToken{typ: typ, val: val, raw: raw})
+			return
+		case st.posix() && strings.IndexByte(val, '=') > 0 && strlenspn(val, "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789_") == strings.IndexByte(val, '='):
+			/* FOO=bar cmd: an assignment, the command comes next */
+			return
+		case isVariable(raw, st.posix()):
+			typ = CMDI_TYPE_VARIABLE
+		case cmdiCommands[name] || isPath(val) && name != "":
+			typ = CMDI_TYPE_COMMAND
+		default:
+			typ = CMDI_TYPE_UNKNOWN
+		}
+		st.cmdpos = false
+	}
+	st.tokens = append(st.tokens, cmdiToken{typ: typ, val: val, raw: raw})
+}
+
+/*
+ * tokenize splits the whole input into tokens.
+ */
+func (st *cmdiState) tokenize() {
+	s := st.s
+	if st.flags&FLAG_QUOTE_DOUBLE != 0 {
+		st.stack = append(st.stack, CHAR_DOUBLE)
+	}
+
+	var b strings.Builder
+	start := 0
+	inWord := false
+	endWord := func() {
+		if inWord {
+			st.emitWord(b.String(), s[start:st.pos])
+		}
+		b.Reset()
+		inWord = false
+		st.quoted = false
+	}
+
+	if st.flags&FLAG_QUOTE_SINGLE != 0 && st.posix() {
+		/* the input starts inside '...' */
+		inWord = true
+		st.quoted = true
+		if end := strings.IndexByte(s, CHAR_SINGLE); end == -1 {
+			b.WriteString(s)
+			st.pos = len(s)
+		} else {
+			b.WriteString(s[:end])
+			st.pos = end + 1
+		}
+	}
+
+	for st.pos < len(s) {
+		if st.top() == CHAR_DOUBLE || inWord {
+			if !inWord {
+				start = st.pos
+				inWord = true
+			}
+			if st.word(&b) {
+				if st.top() == CHAR_DOUBLE && st.pos >= len(s) {
+					break
+				}
+				if st.top() != CHAR_DOUBLE {
+					endWord()
+				}
+				continue
+			}
+			/* a substitution inside double quotes */
+			endWord()
+			if s[st.pos] == CHAR_TICK {
+				st.stack = append(st.stack, CHAR_TICK)
+				st.operator(CMDI_TYPE_SUBST, 1)
+			} else {
+				st.stack = append(st.stack, '(')
+				st.operator(CMDI_TYPE_SUBST, 2)
+			}
+			continue
+		}
+
+		ch := s[st.pos]
+		next := char_at(s, st.pos+1)
+		if st.posix() {
+			if n := st.ifsLen(st.pos); n > 0 {
+				st.pos += n
+				continue
+			}
+		}
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\r' || (!st.posix() && (ch == ';' || ch == ',')):
+			st.pos++
+		case ch == '\n':
+			st.operator(CMDI_TYPE_SEQUENCE, 1)
+		case ch == ';':
+			st.operator(CMDI_TYPE_SEQUENCE, 1)
+		case ch == '#' && st.posix():
+			/* comment to the end of the line */
+			if end := strings.IndexByte(s[st.pos:], '\n'); end != -1 {
+				st.pos += end
+			} else {
+				st.pos = len(s)
+			}
+		case ch == '&' && next == '&', ch == '|' && next == '|':
+			st.operator(CMDI_TYPE_LOGIC, 2)
+		case ch == '&' && next == '>':
+			st.operator(CMDI_TYPE_REDIRECT, 2)
+		case ch == '&':
+			st.operator(CMDI_TYPE_SEQUENCE, 1)
+		case ch == '|' && next == '&' && st.posix():
+			st.operator(CMDI_TYPE_PIPE, 2)
+		case ch == '|':
+			st.operator(CMDI_TYPE_PIPE, 1)
+		case (ch == '<' || ch == '>') && next == '(' && st.posix():
+			st.stack = append(st.stack, '(')
+			st.operator(CMDI_TYPE_SUBST, 2)
+		case ch == '<' || ch == '>':
+			/* at most 3 bytes, a long run of '<' would be scanned once per token */
+			st.operator(CMDI_TYPE_REDIRECT, strlenspn(s[st.pos:imin(st.pos+3, len(s))], "<>|&"))
+			/* 2>&1 */
+			if st.pos < len(s) && s[st.pos-1] == '&' && s[st.pos] >= '0' && s[st.pos] <= '9' {
+				st.pos++
+			}
+		case ch >= '0' && ch <= '9' && (next == '>' || next == '<'):
+			st.pos++
+			st.operator(CMDI_TYPE_REDIRECT, strlenspn(s[st.pos:], "<>&"))
+			if st.pos < len(s) && s[st.pos-1] == '&' && s[st.pos] >= '0' && s[st.pos] <= '9' {
+				st.pos++
+			}
+		case ch == '$' && next == '(' && char_at(s, st.pos+2) != '(' && st.posix():
+			st.stack = append(st.stack, '(')
+			st.operator(CMDI_TYPE_SUBST, 2)
+		case ch == CHAR_TICK && st.posix():
+			if st.top() == CHAR_TICK {
+				st.pop()
+				st.operator(CMDI_TYPE_CLOSE, 1)
+			} else {
+				st.stack = append(st.stack, CHAR_TICK)
+				st.operator(CMDI_TYPE_SUBST, 1)
+			}
+		case ch == '(':
+			st.stack = append(st.stack, '(')
+			st.operator(CMDI_TYPE_GROUP, 1)
+		case ch == ')':
+			if st.top() == '(' {
+				st.pop()
+			}
+			st.operator(CMDI_TYPE_CLOSE, 1)
+		case ch == '{' && st.cmdpos && st.posix() && (next == ' ' || next == '\t' || next == '\n'):
+			st.operator(CMDI_TYPE_GROUP, 1)
+		default:
+			start = st.pos
+			inWord = true
+		}
+	}
+	endWord()
+}
+
+/*
+ * cmdiDanger tells if typ, after prev, runs something: a command or
+ * variable where a command is expected, or anything substituted.
+ */
+func cmdiDanger(prev, typ byte) bool {
+	switch typ {
+	case CMDI_TYPE_COMMAND, CMDI_TYPE_VARIABLE:
+		return strings.IndexByte(";&|E(k", prev) != -1
+	case CMDI_TYPE_UNKNOWN:
+		return prev == CMDI_TYPE_SUBST
+	}
+	return false
+}
+
+/*
+ * cmdiFold keeps the structure of the tokens. Arguments before the first
+ * operator are the benign part of the input and are dropped, so are the
+ * arguments of commands and the targets of redirections. Runs of
+ * operators are folded into the first one, an operator before a
+ * substitution or group into the substitution or group. The fingerprint
+ * ends one token after the first command run.
+ */
+func cmdiFold(tokens []cmdiToken) []cmdiToken {
+	var folded []cmdiToken
+	ran := false
+	for _, token := range tokens {
+		last := byte(CHAR_NULL)
+		if len(folded) > 0 {
+			last = folded[len(folded)-1].typ
+		}
+		switch {
+		case token.typ == CMDI_TYPE_WORD && strings.IndexByte("\x00wcuvk)>", last) != -1:
+			continue
+		case strings.IndexByte(";&|", token.typ) != -1 && strings.IndexByte(";&|", last) != -1 && last != CHAR_NULL:
+			continue
+		case token.typ == last && strings.IndexByte(")(Ek>", token.typ) != -1:
+			continue
+		case (token.typ == CMDI_TYPE_SUBST || token.typ == CMDI_TYPE_GROUP) && strings.IndexByte(";&|", last) != -1 && last != CHAR_NULL:
+			folded = folded[:len(folded)-1]
+		}
+		folded = append(folded, token)
+		if ran || len(folded) == CMDI_MAX_TOKENS {
+			break
+		}
+		ran = len(folded) > 1 && cmdiDanger(last, token.typ)
+	}
+	return folded
+}
+
+/*
+ * cmdiFingerprintTable spells out every fingerprint cmdiFollows allows,
+ * up to the token after the first command run.
+ */
+func cmdiFingerprintTable() map[string]bool {
+	table := map[string]bool{}
+	var walk func(fingerprint string, last byte)
+	walk = func(fingerprint string, last byte) {
+		for _, typ := range []byte(cmdiFollows[last]) {
+			next := fingerprint + string(typ)
+			switch {
+			case cmdiDanger(last, typ):
+				table[next] = true
+				for _, after := range []byte(cmdiFollows[typ]) {
+					table[next+string(after)] = true
+				}
+			case len(next) < CMDI_MAX_TOKENS-1:
+				walk(next, typ)
+			}
+		}
+	}
+	walk("", CMDI_TYPE_WORD)
+	return table
+}
+
+/*
+ * cmdiNotWhitelist returns false for inputs that look like a command
+ * injection only because they are text: some words come before the first
+ * operator, every command is one of the commands that are also English
+ * words, like "cat" or "type", run with plain word arguments, e.g.
+ * "dogs; cat lovers", and nothing is substituted or redirected. Text
+ * doesn't start with an operator, "; echo hi" or "& dir" are commands.
+ */
+func cmdiNotWhitelist(fingerprint string, tokens []cmdiToken) bool {
+	if strings.ContainsAny(fingerprint, "Ev>(") {
+		return true
+	}
+	if len(tokens) > 0 && strings.IndexByte(";&|", tokens[0].typ) != -1 {
+		return true
+	}
+	commands := 0
+	for i, token := range tokens {
+		if token.typ != CMDI_TYPE_COMMAND {
+			continue
+		}
+		commands++
+		if !cmdiWords[commandName(token.val)] || isPath(token.val) || strings.ContainsAny(token.raw, "{,$\"'\\^") {
+			return true
+		}
+		for _, arg := range tokens[i+1:] {
+			if arg.typ != CMDI_TYPE_WORD {
+				break
+			}
+			if strings.ContainsAny(arg.raw, "/\\-.$%=*~") {
+				return true
+			}
+		}
+	}
+	return commands == 0
+}
+
+/*
+ * cmdiFingerprint tokenizes and folds input under flags.
+ */
+func cmdiFingerprint(input string, flags int) (string, []cmdiToken) {
+	st := newCmdiState(input, flags)
+	st.tokenize()
+	folded := cmdiFold(st.tokens)
+	b := make([]byte, len(folded))
+	for i := range folded {
+		b[i] = folded[i].typ
+	}
+	return string(b), st.tokens
+}
+
+/*
+ * cmdiCheck runs one pass.
+ */
+func cmdiCheck(input string, flags int) Result {
+	fingerprint, tokens := cmdiFingerprint(input, flags)
+	injection := cmdiFingerprints[fingerprint] && cmdiNotWhitelist(fingerprint, tokens)
+	return Result{Injection: injection, Fingerprint: fingerprint, Flags: newCmdiState(input, flags).flags}
+}
+
+/*
+ * DetectCmdi checks input, an argument of a shell command, for command
+ * injection. Like libinjection_is_sqli, the input is tried as is, then as
+ * if it were inside single or double quotes when it has some, first as
+ * POSIX sh, then as Windows cmd.
+ */
+func DetectCmdi(input string) Result {
+	if input == "" {
+		return Result{Flags: FLAG_QUOTE_NONE | FLAG_SHELL_POSIX}
+	}
+	var result Result
+	for _, flags := range []int{
+		FLAG_QUOTE_NONE | FLAG_SHELL_POSIX,
+		FLAG_QUOTE_SINGLE | FLAG_SHELL_POSIX,
+		FLAG_QUOTE_DOUBLE | FLAG_SHELL_POSIX,
+		FLAG_QUOTE_NONE | FLAG_SHELL_CMD,
+		FLAG_QUOTE_DOUBLE | FLAG_SHELL_CMD,
+	} {
+		if flags&FLAG_QUOTE_SINGLE != 0 && strings.IndexByte(input, CHAR_SINGLE) == -1 ||
+			flags&FLAG_QUOTE_DOUBLE != 0 && strings.IndexByte(input, CHAR_DOUBLE) == -1 {
+			continue
+		}
+		if result = cmdiCheck(input, flags); result.Injection {
+			return result
+		}
+	}
+	return result
+}
+
+/*
+ * DetectCmdiFlags runs a single pass, for input known to be injected in
+ * the context given by flags: FLAG_QUOTE_NONE, FLAG_QUOTE_SINGLE or
+ * FLAG_QUOTE_DOUBLE, or'ed with FLAG_SHELL_POSIX or FLAG_SHELL_CMD.
+ */
+func DetectCmdiFlags(input string, flags int) Result {
+	return cmdiCheck(input, flags)
+}
+
+/*
+ * IsCmdi tells if input is a command injection, with its fingerprint.
+ */
+func IsCmdi(input string) (bool, string) {
+	result := DetectCmdi(input)
+	if !result.Injection {
+		return false, ""
+	}
+	return true, result.Fingerprint
+}
+
+/*
+ * CmdiFlagsString names a command injection pass, e.g. "single/sh".
+ */
+func CmdiFlagsString(flags int) string {
+	quote := "none"
+	if flags&FLAG_QUOTE_SINGLE != 0 {
+		quote = "single"
+	} else if flags&FLAG_QUOTE_DOUBLE != 0 {
+		quote = "double"
+	}
+	shell := "sh"
+	if flags&FLAG_SHELL_CMD != 0 {
+		shell = "cmd"
+	}
+	return quote + "/" + shell
+}
@@ -0,0 +1,265 @@
+package libinjection
+
+/*
+ * Programs whose name in command position makes a word a
+ * CMDI_TYPE_COMMAND: POSIX utilities, interpreters, network tools and
+ * Windows built-ins.
+ */
+var cmdiCommands = map[string]bool{
+	"ash":         true,
+	"at":          true,
+	"attrib":      true,
+	"awk":         true,
+	"base32":      true,
+	"base64":      true,
+	"bash":        true,
+	"bitsadmin":   true,
+	"busybox":     true,
+	"cat":         true,
+	"certutil":    true,
+	"chgrp":       true,
+	"chmod":       true,
+	"chown":       true,
+	"chpasswd":    true,
+	"cmd":         true,
+	"cmdkey":      true,
+	"copy":        true,
+	"cp":          true,
+	"crontab":     true,
+	"cscript":     true,
+	"csh":         true,
+	"curl":        true,
+	"dash":        true,
+	"dd":          true,
+	"del":         true,
+	"dig":         true,
+	"dir":         true,
+	"echo":        true,
+	"egrep":       true,
+	"env":         true,
+	"erase":       true,
+	"eval":        true,
+	"exec":        true,
+	"file":        true,
+	"find":        true,
+	"findstr":     true,
+	"forfiles":    true,
+	"ftp":         true,
+	"gawk":        true,
+	"gpg":         true,
+	"grep":        true,
+	"groups":      true,
+	"gzip":        true,
+	"head":        true,
+	"hexdump":     true,
+	"history":     true,
+	"host":        true,
+	"hostname":    true,
+	"hostnamectl": true,
+	"icacls":      true,
+	"id":          true,
+	"ifconfig":    true,
+	"ip":          true,
+	"ipconfig":    true,
+	"kill":        true,
+	"killall":     true,
+	"ksh":         true,
+	"last":        true,
+	"less":        true,
+	"ln":          true,
+	"locate":      true,
+	"ls":          true,
+	"lsof":        true,
+	"lua":         true,
+	"mawk":        true,
+	"mkdir":       true,
+	"mkfifo":      true,
+	"more":        true,
+	"move":        true,
+	"mshta":       true,
+	"msiexec":     true,
+	"mv":          true,
+	"nc":          true,
+	"ncat":        true,
+	"net":         true,
+	"net1":        true,
+	"netcat":      true,
+	"netsh":       true,
+	"netstat":     true,
+	"nl":          true,
+	"nltest":      true,
+	"node":        true,
+	"nodejs":      true,
+	"nohup":       true,
+	"nslookup":    true,
+	"od":          true,
+	"openssl":     true,
+	"passwd":      true,
+	"perl":        true,
+	"php":         true,
+	"ping":        true,
+	"ping6":       true,
+	"pkill":       true,
+	"powershell":  true,
+	"printenv":    true,
+	"printf":      true,
+	"ps":          true,
+	"pwsh":        true,
+	"python":      true,
+	"python2":     true,
+	"python3":     true,
+	"quser":       true,
+	"qwinsta":     true,
+	"rbash":       true,
+	"reboot":      true,
+	"reg":         true,
+	"regsvr32":    true,
+	"rm":          true,
+	"rmdir":       true,
+	"robocopy":    true,
+	"rsync":       true,
+	"ruby":        true,
+	"rundll32":    true,
+	"sc":          true,
+	"schtasks":    true,
+	"scp":         true,
+	"sed":         true,
+	"service":     true,
+	"set":         true,
+	"sftp":        true,
+	"sh":          true,
+	"shutdown":    true,
+	"sleep":       true,
+	"socat":       true,
+	"source":      true,
+	"ss":          true,
+	"ssh":         true,
+	"start":       true,
+	"stat":        true,
+	"strings":     true,
+	"su":          true,
+	"sudo":        true,
+	"systemctl":   true,
+	"systeminfo":  true,
+	"tac":         true,
+	"tail":        true,
+	"tar":         true,
+	"taskkill":    true,
+	"tasklist":    true,
+	"tclsh":       true,
+	"tcsh":        true,
+	"tee":         true,
+	"telnet":      true,
+	"tftp":        true,
+	"timeout":     true,
+	"top":         true,
+	"touch":       true,
+	"traceroute":  true,
+	"type":        true,
+	"uname":       true,
+	"unzip":       true,
+	"uptime":      true,
+	"useradd":     true,
+	"usermod":     true,
+	"ver":         true,
+	"vssadmin":    true,
+	"w":           true,
+	"wevtutil":    true,
+	"wget":        true,
+	"whereis":     true,
+	"which":       true,
+	"who":         true,
+	"whoami":      true,
+	"wmic":        true,
+	"wscript":     true,
+	"xargs":       true,
+	"xcopy":       true,
+	"xxd":         true,
+	"zip":         true,
+	"zsh":         true,
+}
+
+/*
+ * Commands that are also English words, see cmdiNotWhitelist.
+ */
+var cmdiWords = map[string]bool{
+	"at":      true,
+	"cat":     true,
+	"copy":    true,
+	"dir":     true,
+	"echo":    true,
+	"erase":   true,
+	"file":    true,
+	"find":    true,
+	"groups":  true,
+	"head":    true,
+	"history": true,
+	"host":    true,
+	"kill":    true,
+	"last":    true,
+	"less":    true,
+	"more":    true,
+	"move":    true,
+	"service": true,
+	"set":     true,
+	"source":  true,
+	"start":   true,
+	"strings": true,
+	"tail":    true,
+	"top":     true,
+	"type":    true,
+	"ver":     true,
+	"w":       true,
+	"which":   true,
+	"who":     true,
+}
+
+/*
+ * Shell keywords in command position: 'k' if a command follows, 'e' if
+ * the keyword ends one.
+ */
+var cmdiKeywords = map[string]byte{
+	"!":     'k',
+	"do":    'k',
+	"done":  'e',
+	"elif":  'k',
+	"else":  'k',
+	"esac":  'e',
+	"fi":    'e',
+	"if":    'k',
+	"then":  'k',
+	"time":  'k',
+	"until": 'k',
+	"while": 'k',
+	"{":     'k',
+	"}":     'e',
+}
+
+/*
+ * The token types that can follow each token type in a fingerprint.
+ * Arguments are folded away, so are runs of operators and repeated
+ * substitutions, groups, keywords and redirections. The input starts as
+ * an argument, CMDI_TYPE_WORD, so a fingerprint starts with what can
+ * follow one.
+ */
+var cmdiFollows = map[byte]string{
+	CMDI_TYPE_WORD:     ";&|E()>",
+	CMDI_TYPE_COMMAND:  ";&|E()>",
+	CMDI_TYPE_UNKNOWN:  ";&|E()>",
+	CMDI_TYPE_VARIABLE: ";&|E()>",
+	CMDI_TYPE_KEYWORD:  "cv;&|E(",
+	CMDI_TYPE_SEQUENCE: "cuvk)>",
+	CMDI_TYPE_LOGIC:    "cuvk)>",
+	CMDI_TYPE_PIPE:     "cuvk)>",
+	CMDI_TYPE_SUBST:    "cuvk;&|()>",
+	CMDI_TYPE_GROUP:    "cuvk;&|E)>",
+	CMDI_TYPE_CLOSE:    ";&|E(>",
+	CMDI_TYPE_REDIRECT: ";&|E()",
+}
+
+/*
+ * Fingerprints of command injections: one or more operators that end the
+ * command the input is an argument of, then a command run within the
+ * first four tokens, and the token after it, if any.
+ */
+var cmdiFingerprints = cmdiFingerprintTable()
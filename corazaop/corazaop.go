@@ -0,0 +1,77 @@
+/*
+ * Package corazaop provides the @detectSQLi and @detectXSS operators for
+ * the Coraza WAF on top of this package, so ModSecurity Core Rule Set
+ * rules run on it without cgo.
+ *
+ * Captures follow the C libinjection binding of ModSecurity: when the rule
+ * has the capture action, @detectSQLi stores the fingerprint in TX:0 and
+ * @detectXSS stores the matched input.
+ *
+ *	corazaop.Register()
+ *	waf, err := coraza.NewWAF(coraza.NewWAFConfig().WithDirectives(`
+ *		SecRule ARGS "@detectSQLi" "id:1,phase:2,capture,deny,logdata:'%{tx.0}'"
+ *	`))
+ */
+package corazaop
+
+import (
+	"github.com/corazawaf/coraza/v3/experimental/plugins"
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+
+	libinjection "github.com/jptosso/libinjection-go"
+)
+
+type detectSQLi struct{}
+
+type detectXSS struct{}
+
+var (
+	_ plugintypes.Operator = detectSQLi{}
+	_ plugintypes.Operator = detectXSS{}
+)
+
+/*
+ * Register replaces Coraza's built in @detectSQLi and @detectXSS with the
+ * operators of this package. Call it before creating the WAF.
+ */
+func Register() {
+	plugins.RegisterOperator("detectSQLi", NewDetectSQLi)
+	plugins.RegisterOperator("detectXSS", NewDetectXSS)
+}
+
+/*
+ * NewDetectSQLi is the OperatorFactory of @detectSQLi. It takes no
+ * arguments.
+ */
+func NewDetectSQLi(plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+	return detectSQLi{}, nil
+}
+
+func (detectSQLi) Evaluate(tx plugintypes.TransactionState, value string) bool {
+	issqli, fingerprint := libinjection.IsSQLi(value)
+	if !issqli {
+		return false
+	}
+	if tx.Capturing() {
+		tx.CaptureField(0, fingerprint)
+	}
+	return true
+}
+
+/*
+ * NewDetectXSS is the OperatorFactory of @detectXSS. It takes no
+ * arguments.
+ */
+func NewDetectXSS(plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+	return detectXSS{}, nil
+}
+
+func (detectXSS) Evaluate(tx plugintypes.TransactionState, value string) bool {
+	if !libinjection.IsXSS(value) {
+		return false
+	}
+	if tx.Capturing() {
+		tx.CaptureField(0, value)
+	}
+	return true
+}
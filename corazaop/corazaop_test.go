@@ -0,0 +1,76 @@
+package corazaop
+
+import (
+	"testing"
+
+	"github.com/corazawaf/coraza/v3"
+)
+
+func newWAF(t *testing.T, directives string) coraza.WAF {
+	t.Helper()
+	Register()
+	waf, err := coraza.NewWAF(coraza.NewWAFConfig().WithDirectives(directives))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return waf
+}
+
+/*
+ * evaluate runs the phase 1 rules on a request with the query argument q,
+ * returning the logdata of the rule that matched, if any.
+ */
+func evaluate(t *testing.T, waf coraza.WAF, q string) (bool, string) {
+	t.Helper()
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	tx.AddGetRequestArgument("q", q)
+	tx.ProcessURI("/", "GET", "HTTP/1.1")
+	tx.ProcessRequestHeaders()
+	for _, m := range tx.MatchedRules() {
+		if m.Rule().ID() == 1 && len(m.MatchedDatas()) != 0 {
+			return true, m.MatchedDatas()[0].Data()
+		}
+	}
+	return false, ""
+}
+
+func TestDetectSQLi(t *testing.T) {
+	waf := newWAF(t, `
+		SecRuleEngine On
+		SecRule ARGS "@detectSQLi" "id:1,phase:1,capture,log,pass,logdata:'%{tx.0}'"
+	`)
+	matched, data := evaluate(t, waf, "1' or '1'='1")
+	if !matched || data != "s&sos" {
+		t.Errorf("expected a match capturing s&sos, got %v %q", matched, data)
+	}
+	if matched, _ := evaluate(t, waf, "O'Reilly"); matched {
+		t.Error("benign input matched")
+	}
+}
+
+func TestDetectXSS(t *testing.T) {
+	const payload = "<script>alert(1)</script>"
+	waf := newWAF(t, `
+		SecRuleEngine On
+		SecRule ARGS "@detectXSS" "id:1,phase:1,capture,log,pass,logdata:'%{tx.0}'"
+	`)
+	matched, data := evaluate(t, waf, payload)
+	if !matched || data != payload {
+		t.Errorf("expected a match capturing the input, got %v %q", matched, data)
+	}
+	if matched, _ := evaluate(t, waf, "<b>bold</b>"); matched {
+		t.Error("benign input matched")
+	}
+}
+
+func TestNoCapture(t *testing.T) {
+	waf := newWAF(t, `
+		SecRuleEngine On
+		SecRule ARGS "@detectSQLi" "id:1,phase:1,log,pass,logdata:'[%{tx.0}]'"
+	`)
+	matched, data := evaluate(t, waf, "1' or '1'='1")
+	if !matched || data != "[]" {
+		t.Errorf("expected a match without capture, got %v %q", matched, data)
+	}
+}
@@ -0,0 +1,22 @@
+module github.com/jptosso/libinjection-go/corazaop
+
+go 1.24.0
+
+require (
+	github.com/corazawaf/coraza/v3 v3.3.3
+	github.com/jptosso/libinjection-go v0.1.0
+)
+
+require (
+	github.com/corazawaf/libinjection-go v0.2.2 // indirect
+	github.com/magefile/mage v1.15.1-0.20241126214340-bdc92f694516 // indirect
+	github.com/petar-dambovaliev/aho-corasick v0.0.0-20240411101913-e07a1f0e8eb4 // indirect
+	github.com/tidwall/gjson v1.18.0 // indirect
+	github.com/tidwall/match v1.1.1 // indirect
+	github.com/tidwall/pretty v1.2.1 // indirect
+	github.com/valllabh/ocsf-schema-golang v1.0.3 // indirect
+	golang.org/x/net v0.37.0 // indirect
+	golang.org/x/sync v0.12.0 // indirect
+	google.golang.org/protobuf v1.35.1 // indirect
+	rsc.io/binaryregexp v0.2.0 // indirect
+)
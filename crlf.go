@@ -0,0 +1,169 @@
+package libinjection
+
+import (
+	"strings"
+
+	"github.com/jptosso/libinjection-go/internal/strutil"
+)
+
+/*
+ * CRLF injection: a value that ends up in a response header, such as a
+ * redirect target, and adds header lines of its own with CR and LF, or
+ * ends the headers and starts a body or a second response, HTTP response
+ * splitting.
+ *
+ * Newlines are decoded from percent encoding, up to three times, from
+ * \u000d and %u000d escapes, and from U+560A and U+560D, which some
+ * servers, Node.js among them, truncate to LF and CR when they write a
+ * header. The fingerprint is the lower case name of the injected header,
+ * or CRLF_SPLIT.
+ */
+
+const (
+	FLAG_CRLF_ENCODED = 8192  /* 1 << 13, percent encoded newlines */
+	FLAG_CRLF_UNICODE = 16384 /* 1 << 14, \u000d, %u000d, U+560A or U+560D */
+
+	/* the fingerprint of a second message, or of a body */
+	CRLF_SPLIT = "split"
+)
+
+var crlfUnicode = strings.NewReplacer(
+	"嘊", "\n", "嘍", "\r",
+	`\u000a`, "\n", `\u000A`, "\n", `\u000d`, "\r", `\u000D`, "\r",
+	"%u000a", "\n", "%u000A", "\n", "%u000d", "\r", "%u000D", "\r",
+	"%U000a", "\n", "%U000A", "\n", "%U000d", "\r", "%U000D", "\r",
+)
+
+/*
+ * Headers that are dangerous to inject whatever the encoding, so a value
+ * with plain newlines, e.g. from a text area, is flagged only for these.
+ */
+var crlfHeaders = map[string]bool{
+	"access-control-allow-credentials": true,
+	"access-control-allow-origin":      true,
+	"content-length":                   true,
+	"content-security-policy":          true,
+	"content-type":                     true,
+	"location":                         true,
+	"refresh":                          true,
+	"set-cookie":                       true,
+	"transfer-encoding":                true,
+	"x-xss-protection":                 true,
+}
+
+/*
+ * a lone CR ends a line too, for some servers, and LF CR is one line
+ * break, as in the U+560A U+560D payloads
+ */
+var crlfLines = strings.NewReplacer("\r\n", "\n", "\n\r", "\n", "\r", "\n")
+
+func crlfNewlines(s string) int {
+	return strings.Count(s, "\r") + strings.Count(s, "\n")
+}
+
+/*
+ * crlfDecode returns input with its encoded newlines decoded, and how
+ * they were encoded.
+ */
+func crlfDecode(input string) (string, int) {
+	s, flags := input, 0
+	for round := 0; round < 3; round++ {
+		if u := crlfUnicode.Replace(s); crlfNewlines(u) > crlfNewlines(s) {
+			s, flags = u, flags|FLAG_CRLF_UNICODE
+		}
+		decoded, _ := traversalUnescape(s)
+		if decoded == s {
+			break
+		}
+		if crlfNewlines(decoded) > crlfNewlines(s) {
+			flags |= FLAG_CRLF_ENCODED
+		}
+		s = decoded
+	}
+	if u := crlfUnicode.Replace(s); crlfNewlines(u) > crlfNewlines(s) {
+		s, flags = u, flags|FLAG_CRLF_UNICODE
+	}
+	return s, flags
+}
+
+/*
+ * crlfHeaderName returns the name of a header line, "" if line isn't
+ * one.
+ */
+func crlfHeaderName(line string) string {
+	name, _, ok := strutil.Cut(line, ":")
+	if !ok || name == "" {
+		return ""
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			strings.IndexByte("!#$%&'*+-.^_`|~", c) != -1) {
+			return ""
+		}
+	}
+	return strings.ToLower(name)
+}
+
+/*
+ * crlfMessage tells if line starts an HTTP message, a status line or a
+ * request line.
+ */
+func crlfMessage(line string) bool {
+	if strings.HasPrefix(line, "HTTP/") {
+		version, status, _ := strutil.Cut(line[len("HTTP/"):], " ")
+		return version != "" && version[0] >= '0' && version[0] <= '9' &&
+			len(status) >= 3 && status[0] >= '1' && status[0] <= '5'
+	}
+	fields := strings.Fields(line)
+	return len(fields) == 3 && strings.HasPrefix(fields[2], "HTTP/") &&
+		strings.ToUpper(fields[0]) == fields[0] && fields[0] != ""
+}
+
+/*
+ * DetectCRLF checks input, a value that may be written into a response
+ * header, for header injection and response splitting.
+ */
+func DetectCRLF(input string) Result {
+	decoded, flags := crlfDecode(input)
+	if strings.IndexAny(decoded, "\r\n") == -1 {
+		return Result{}
+	}
+	lines := strings.Split(crlfLines.Replace(decoded), "\n")
+
+	/* the first line is the value the header was meant to have */
+	header := ""
+	for i, line := range lines[1:] {
+		if crlfMessage(line) {
+			return Result{Injection: true, Fingerprint: CRLF_SPLIT, Flags: flags}
+		}
+		if strings.TrimSpace(line) == "" {
+			/*
+			 * the end of the headers, then a body. Text has blank lines
+			 * too, so only after a header or when the newlines were
+			 * encoded.
+			 */
+			if (header != "" || flags != 0) && strings.TrimSpace(strings.Join(lines[i+2:], "")) != "" {
+				return Result{Injection: true, Fingerprint: CRLF_SPLIT, Flags: flags}
+			}
+			continue
+		}
+		/* some servers trim the line, or unfold it into a header of its own */
+		if name := crlfHeaderName(strings.TrimLeft(line, " \t")); name != "" && header == "" && (flags != 0 || crlfHeaders[name]) {
+			header = name
+		}
+	}
+	if header == "" {
+		return Result{Flags: flags}
+	}
+	return Result{Injection: true, Fingerprint: header, Flags: flags}
+}
+
+/*
+ * IsCRLF tells if input is a header injection, with the injected header
+ * or CRLF_SPLIT.
+ */
+func IsCRLF(input string) (bool, string) {
+	result := DetectCRLF(input)
+	return result.Injection, result.Fingerprint
+}
@@ -0,0 +1,44 @@
+package libinjection
+
+import "testing"
+
+func TestDetectCRLF(t *testing.T) {
+	tests := []struct {
+		input       string
+		fingerprint string
+		flags       int
+	}{
+		{"/home\r\nSet-Cookie: session=evil", "set-cookie", 0},
+		{"/home\nLocation: http://evil.example", "location", 0},
+		{"%0d%0aSet-Cookie:%20a=1", "set-cookie", FLAG_CRLF_ENCODED},
+		{"%250d%250aX-Forwarded-For: 127.0.0.1", "x-forwarded-for", FLAG_CRLF_ENCODED},
+		{"/%E5%98%8A%E5%98%8Dset-cookie:whoami=1", "set-cookie", FLAG_CRLF_UNICODE},
+		{"x\\u000d\\u000aX-Injected: 1", "x-injected", FLAG_CRLF_UNICODE},
+		{"x%u000d%u000aX-Injected: 1", "x-injected", FLAG_CRLF_UNICODE},
+		{"en%0d%0aContent-Length:%200%0d%0a%0d%0aHTTP/1.1%20200%20OK", CRLF_SPLIT, FLAG_CRLF_ENCODED},
+		{"x%0d%0aContent-Type: text/html%0d%0a%0d%0a<script>alert(1)</script>", CRLF_SPLIT, FLAG_CRLF_ENCODED},
+		{"x\r\n\r\nGET /admin HTTP/1.1", CRLF_SPLIT, 0},
+		{"%0d%0a%0d%0a<script>alert(1)</script>", CRLF_SPLIT, FLAG_CRLF_ENCODED},
+		{"%0a%0a<script>alert(1)</script>", CRLF_SPLIT, FLAG_CRLF_ENCODED},
+		{"x%0d%0a%20%0d%0a<html>", CRLF_SPLIT, FLAG_CRLF_ENCODED},
+		{"foo%0d%0a%20Set-Cookie:a", "set-cookie", FLAG_CRLF_ENCODED},
+		{"foo\r\n\tLocation: http://evil.example", "location", 0},
+		/* benign */
+		{"/home", "", 0},
+		{"line one\r\nline two", "", 0},
+		{"Dear team,\r\nNote: the meeting moved.", "", 0},
+		{"para one\r\n\r\npara two", "", 0},
+		{"Dear team,\r\n  note: indented", "", 0},
+		{"100%", "", 0},
+		{"%0d%0a", "", FLAG_CRLF_ENCODED},
+	}
+	for _, test := range tests {
+		result := DetectCRLF(test.input)
+		if result.Injection != (test.fingerprint != "") || result.Fingerprint != test.fingerprint || result.Flags != test.flags {
+			t.Errorf("%q: expected %q %d, got %+v", test.input, test.fingerprint, test.flags, result)
+		}
+		if is, fingerprint := IsCRLF(test.input); is != result.Injection || fingerprint != test.fingerprint {
+			t.Errorf("%q: IsCRLF gives %v %q", test.input, is, fingerprint)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package libinjection
+
+/*
+ * Detector is one kind of injection check, so callers can run several
+ * with a single policy. Every detector reports through Result.
+ */
+type Detector interface {
+	Name() string /* short, lower case, e.g. "sqli" */
+	Detect(input string) Result
+}
+
+type detectorFunc struct {
+	name   string
+	detect func(string) Result
+}
+
+func (d detectorFunc) Name() string {
+	return d.name
+}
+
+func (d detectorFunc) Detect(input string) Result {
+	return d.detect(input)
+}
+
+/*
+ * The detectors of this package.
+ */
+var (
+	SQLiDetector      Detector = detectorFunc{"sqli", DetectSQLi}
+	XSSDetector       Detector = detectorFunc{"xss", DetectXSS}
+	CmdiDetector      Detector = detectorFunc{"cmdi", DetectCmdi}
+	NoSQLDetector     Detector = detectorFunc{"nosql", DetectNoSQL}
+	LDAPDetector      Detector = detectorFunc{"ldap", DetectLDAP}
+	TraversalDetector Detector = detectorFunc{"traversal", DetectTraversal}
+	SSTIDetector      Detector = detectorFunc{"ssti", DetectSSTI}
+	XPathDetector     Detector = detectorFunc{"xpath", DetectXPath}
+	CRLFDetector      Detector = detectorFunc{"crlf", DetectCRLF}
+	SSRFDetector      Detector = detectorFunc{"ssrf", DetectSSRF}
+	XXEDetector       Detector = detectorFunc{"xxe", DetectXXE}
+	JSXSSDetector     Detector = detectorFunc{"jsxss", DetectJSXSS}
+)
@@ -0,0 +1,223 @@
+/*
+ * Package differential compares this port with output recorded from the C
+ * libinjection, so divergences show up without the C library at test time.
+ *
+ * A corpus is a text file, one record per line, fields separated by tabs:
+ *
+ *	flags  input  tokens  fold  fingerprint  verdict
+ *
+ * flags is the pass, FLAG_QUOTE_* | FLAG_SQL_* in decimal, or "*" for
+ * libinjection_sqli, which runs every pass it needs. input is hex encoded.
+ * tokens are the types of every token libinjection_sqli_tokenize returns,
+ * fold the types of the tokens libinjection_sqli_fold leaves, fingerprint
+ * what libinjection_sqli_fingerprint returns and verdict 1 if the
+ * fingerprint is SQLi, else 0. For "*" only fingerprint and verdict are
+ * recorded. Fields not recorded are "-", lines starting with '#' are
+ * comments.
+ *
+ * Divergences are put in the category of the first stage that differs:
+ * tokenizer, fold (folding or fingerprint) or blacklist.
+ */
+package differential
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	libinjection "github.com/jptosso/libinjection-go"
+)
+
+/* the value of fields that were not recorded */
+const NotRecorded = "-"
+
+/*
+ * AllPasses is the flags of records made with libinjection_sqli.
+ */
+const AllPasses = 0
+
+/*
+ * Categories of divergences, in pipeline order.
+ */
+const (
+	Tokenizer = "tokenizer"
+	Fold      = "fold"
+	Blacklist = "blacklist"
+)
+
+/*
+ * Record is one line of a corpus.
+ */
+type Record struct {
+	Line        int
+	Flags       int /* AllPasses for libinjection_sqli */
+	Input       string
+	Tokens      string
+	Fold        string
+	Fingerprint string
+	Verdict     string /* "1", "0" or NotRecorded */
+}
+
+/*
+ * Divergence is a record this port doesn't reproduce.
+ */
+type Divergence struct {
+	Record
+	Category string
+	Expected string /* the recorded field */
+	Actual   string
+}
+
+/*
+ * Stats counts the records of a corpus and their divergences.
+ */
+type Stats struct {
+	Records     int
+	Divergences map[string]int /* by category */
+}
+
+/*
+ * Total is the number of divergences.
+ */
+func (s *Stats) Total() int {
+	n := 0
+	for _, c := range s.Divergences {
+		n += c
+	}
+	return n
+}
+
+/*
+ * ParseRecord parses one line of a corpus.
+ */
+func ParseRecord(line string) (Record, error) {
+	fields := strings.Split(line, "\t")
+	if len(fields) != 6 {
+		return Record{}, fmt.Errorf("expected 6 fields, got %d", len(fields))
+	}
+	var r Record
+	if fields[0] != "*" {
+		flags, err := strconv.Atoi(fields[0])
+		if err != nil || flags <= 0 {
+			return Record{}, fmt.Errorf("invalid flags %q", fields[0])
+		}
+		r.Flags = flags
+	}
+	input, err := hex.DecodeString(fields[1])
+	if err != nil {
+		return Record{}, fmt.Errorf("invalid input: %v", err)
+	}
+	r.Input = string(input)
+	r.Tokens, r.Fold, r.Fingerprint, r.Verdict = fields[2], fields[3], fields[4], fields[5]
+	switch r.Verdict {
+	case "0", "1", NotRecorded:
+	default:
+		return Record{}, fmt.Errorf("invalid verdict %q", r.Verdict)
+	}
+	return r, nil
+}
+
+/*
+ * String formats r as a line of a corpus.
+ */
+func (r Record) String() string {
+	flags := "*"
+	if r.Flags != AllPasses {
+		flags = strconv.Itoa(r.Flags)
+	}
+	return strings.Join([]string{flags, hex.EncodeToString([]byte(r.Input)), r.Tokens, r.Fold, r.Fingerprint, r.Verdict}, "\t")
+}
+
+func tokenTypes(tokens []libinjection.Token) string {
+	b := make([]byte, len(tokens))
+	for i := range tokens {
+		b[i] = tokens[i].Type
+	}
+	return string(b)
+}
+
+func verdict(injection bool) string {
+	if injection {
+		return "1"
+	}
+	return "0"
+}
+
+/*
+ * Compare runs this port on r. It returns nil if every recorded field is
+ * reproduced, else the divergence of the first stage that differs.
+ */
+func Compare(r Record) *Divergence {
+	diverge := func(category, expected, actual string) *Divergence {
+		return &Divergence{Record: r, Category: category, Expected: expected, Actual: actual}
+	}
+
+	if r.Flags == AllPasses {
+		issqli, fingerprint := libinjection.IsSQLi(r.Input)
+		if r.Fingerprint != NotRecorded && fingerprint != r.Fingerprint {
+			return diverge(Fold, r.Fingerprint, fingerprint)
+		}
+		if r.Verdict != NotRecorded && verdict(issqli) != r.Verdict {
+			return diverge(Blacklist, r.Verdict, verdict(issqli))
+		}
+		return nil
+	}
+
+	if r.Tokens != NotRecorded {
+		if tokens := tokenTypes(libinjection.Tokenize(r.Input, r.Flags)); tokens != r.Tokens {
+			return diverge(Tokenizer, r.Tokens, tokens)
+		}
+	}
+	if r.Fold != NotRecorded {
+		tokens, err := libinjection.Fold(r.Input, r.Flags)
+		fold := tokenTypes(tokens)
+		if err != nil {
+			fold = "error: " + err.Error()
+		}
+		if fold != r.Fold {
+			return diverge(Fold, r.Fold, fold)
+		}
+	}
+	result := libinjection.DetectSQLiFlags(r.Input, r.Flags)
+	if r.Fingerprint != NotRecorded && result.Fingerprint != r.Fingerprint {
+		return diverge(Fold, r.Fingerprint, result.Fingerprint)
+	}
+	if r.Verdict != NotRecorded && verdict(result.Injection) != r.Verdict {
+		return diverge(Blacklist, r.Verdict, verdict(result.Injection))
+	}
+	return nil
+}
+
+/*
+ * Run compares every record of a corpus, calling report for each
+ * divergence. report may be nil.
+ */
+func Run(corpus io.Reader, report func(Divergence)) (Stats, error) {
+	stats := Stats{Divergences: map[string]int{}}
+	scanner := bufio.NewScanner(corpus)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	lineno := 0
+	for scanner.Scan() {
+		lineno++
+		line := scanner.Text()
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		r, err := ParseRecord(line)
+		if err != nil {
+			return stats, fmt.Errorf("line %d: %v", lineno, err)
+		}
+		r.Line = lineno
+		stats.Records++
+		if d := Compare(r); d != nil {
+			stats.Divergences[d.Category]++
+			if report != nil {
+				report(*d)
+			}
+		}
+	}
+	return stats, scanner.Err()
+}
@@ -0,0 +1,114 @@
+package differential
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+/*
+ * TestCorpus runs every corpus in testdata: the seed corpus converted from
+ * tests/, and those record/record.sh recorded from the C library, named
+ * recorded-<version>.tsv.gz. Divergences are listed by category, the first
+ * few of each in full.
+ */
+func TestCorpus(t *testing.T) {
+	files, _ := filepath.Glob(filepath.Join("testdata", "*.tsv"))
+	gzipped, _ := filepath.Glob(filepath.Join("testdata", "*.tsv.gz"))
+	files = append(files, gzipped...)
+	if len(files) == 0 {
+		t.Fatal("no corpus in testdata")
+	}
+	if recorded, _ := filepath.Glob(filepath.Join("testdata", "recorded-*.tsv.gz")); len(recorded) == 0 {
+		t.Log("no corpus recorded from the C library in testdata, see record/record.sh")
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			f, err := os.Open(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			var r io.Reader = f
+			if strings.HasSuffix(file, ".gz") {
+				gz, err := gzip.NewReader(f)
+				if err != nil {
+					t.Fatal(err)
+				}
+				r = gz
+			}
+
+			shown := map[string]int{}
+			stats, err := Run(r, func(d Divergence) {
+				if shown[d.Category]++; shown[d.Category] <= 10 {
+					t.Errorf("line %d, %s, flags %d, input %q: expected %q, got %q",
+						d.Line, d.Category, d.Flags, d.Input, d.Expected, d.Actual)
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats.Records == 0 {
+				t.Fatal("empty corpus")
+			}
+			if stats.Total() != 0 {
+				t.Errorf("%d of %d records diverge: %v", stats.Total(), stats.Records, stats.Divergences)
+			}
+			t.Logf("%d records", stats.Records)
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		line     string
+		category string
+	}{
+		/* 1 union select 1 */
+		{"9\t3120756e696f6e2073656c6563742031\t1UE1\t-\t-\t-", ""},
+		{"9\t3120756e696f6e2073656c6563742031\t1UEn\t-\t-\t-", Tokenizer},
+		{"9\t3120756e696f6e2073656c6563742031\t1UE1\t1UE1\t1UE1\t1", ""},
+		{"9\t3120756e696f6e2073656c6563742031\t1UE1\t1UE\t-\t-", Fold},
+		{"9\t3120756e696f6e2073656c6563742031\t-\t-\t1UE\t-", Fold},
+		{"9\t3120756e696f6e2073656c6563742031\t-\t-\t1UE1\t0", Blacklist},
+		/* 1 or 1 */
+		{"9\t31206f722031\t1&1\t1&1\t1&1\t0", ""},
+		{"*\t31206f722031\t-\t-\t\t0", ""},
+		{"*\t31206f722031\t-\t-\t-\t1", Blacklist},
+		/* 1' or '1'='1 */
+		{"*\t3127206f72202731273d2731\t-\t-\ts&sos\t1", ""},
+		{"*\t3127206f72202731273d2731\t-\t-\t1s1s1\t1", Fold},
+	}
+	for _, tt := range tests {
+		r, err := ParseRecord(tt.line)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.line, err)
+		}
+		if r.String() != tt.line {
+			t.Errorf("%q formatted back as %q", tt.line, r.String())
+		}
+		d := Compare(r)
+		category := ""
+		if d != nil {
+			category = d.Category
+		}
+		if category != tt.category {
+			t.Errorf("%q: expected %q, got %q", tt.line, tt.category, category)
+		}
+	}
+
+	for _, line := range []string{
+		"9\t31\t-\t-\t-",
+		"0\t31\t-\t-\t-\t-",
+		"9\tzz\t-\t-\t-\t-",
+		"9\t31\t-\t-\t-\tyes",
+	} {
+		if _, err := ParseRecord(line); err == nil {
+			t.Errorf("%q: expected an error", line)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+/*
+ * Command inputs writes the inputs differential/record runs the C
+ * libinjection on, hex encoded, one per line, for record -x:
+ *
+ *	go run ./differential/inputs -tests tests -n 100000 > inputs.hex
+ *
+ * The inputs are those of the SQLi, folding and token tests in the tests
+ * directory, their variants from package mutate, and n random strings
+ * built from SQL fragments. The same seed gives the same inputs.
+ */
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jptosso/libinjection-go/mutate"
+)
+
+/* what random inputs are made of */
+var fragments = []string{
+	" ", "  ", "\t", "\n", "\r", "\v", "\f", "\x00", "\xa0",
+	"'", "\"", "`", "\\", "(", ")", "{", "}", "[", "]", ",", ";", ".", ":",
+	"-", "--", "#", "/*", "*/", "/*!", "/*!50000", "+", "*", "/", "%", "=",
+	"<", ">", "<>", "!=", "<=>", "||", "&&", "!", "~", "^", "|", "&", "@", "@@", "$", "?",
+	"0", "1", "1.5", ".5", "1e3", "1e", "0x1f", "0x", "x'1f'", "0b01", "b'01'", "N'a'", "$1", "$$a$$", "q'[a]'",
+	"a", "id", "admin", "null", "true", "version()",
+	"select", "union", "all", "from", "where", "and", "or", "not", "like",
+	"is", "in", "between", "order by", "group by", "having", "limit",
+	"insert", "into", "values", "update", "set", "delete", "drop", "table",
+	"exec", "declare", "waitfor delay", "sleep", "benchmark", "char",
+	"concat", "if", "case", "when", "then", "else", "end", "cast", "as",
+	"UNION", "SeLeCt", "OR", "AnD", "xor", "div", "collate", "binary",
+}
+
+func main() {
+	tests := flag.String("tests", "tests", "`directory` of the upstream test files")
+	n := flag.Int("n", 100000, "`number` of random inputs")
+	seed := flag.Int64("seed", 1, "random seed")
+	flag.Parse()
+
+	w := bufio.NewWriter(os.Stdout)
+	seen := map[string]bool{}
+	emit := func(input string) {
+		if seen[input] {
+			return
+		}
+		seen[input] = true
+		fmt.Fprintln(w, hex.EncodeToString([]byte(input)))
+	}
+
+	for _, pattern := range []string{"test-sqli-*.txt", "test-folding-*.txt", "test-tokens-*.txt"} {
+		files, err := filepath.Glob(filepath.Join(*tests, pattern))
+		if err != nil {
+			fatal(err)
+		}
+		for _, file := range files {
+			input, err := readInput(file)
+			if err != nil {
+				fatal(err)
+			}
+			emit(input)
+			for _, v := range mutate.Variants(input) {
+				emit(v.Payload)
+			}
+		}
+	}
+
+	rng := rand.New(rand.NewSource(*seed))
+	for i := 0; i < *n; i++ {
+		var b strings.Builder
+		for k := 1 + rng.Intn(12); k > 0; k-- {
+			b.WriteString(fragments[rng.Intn(len(fragments))])
+		}
+		emit(b.String())
+	}
+
+	if err := w.Flush(); err != nil {
+		fatal(err)
+	}
+}
+
+/*
+ * readInput returns the --INPUT-- section of a test file, as the tests of
+ * package libinjection read it.
+ */
+func readInput(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var input strings.Builder
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch line {
+		case "--TEST--", "--INPUT--", "--EXPECTED--":
+			section = line
+		default:
+			if section == "--INPUT--" {
+				input.WriteString(line + "\n")
+			}
+		}
+	}
+	return strings.TrimSpace(input.String()), scanner.Err()
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "inputs:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,129 @@
+/*
+ * record runs the C libinjection on inputs read from stdin, one per line,
+ * and writes a corpus for package differential to stdout: for each input a
+ * "*" record of libinjection_sqli, then one record per pass with tokens,
+ * fold, fingerprint and verdict.
+ *
+ * Build it against the libinjection 3.9.2 sources:
+ *
+ *	cc -O2 -I libinjection/src -o record record.c \
+ *	    libinjection/src/libinjection_sqli.c \
+ *	    libinjection/src/libinjection_html5.c \
+ *	    libinjection/src/libinjection_xss.c
+ *	./record < inputs.txt | gzip > ../testdata/recorded.tsv.gz
+ *
+ * record.sh does all of this, with inputs from differential/inputs.
+ *
+ * With -x input lines are hex encoded, for inputs with new lines or NUL
+ * bytes. Otherwise the new line ending each line is not part of the input.
+ */
+#define _POSIX_C_SOURCE 200809L
+
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+
+#include "libinjection.h"
+#include "libinjection_sqli.h"
+
+/* not in every version of libinjection_sqli.h */
+int libinjection_sqli_fold(struct libinjection_sqli_state *sf);
+
+static const int passes[] = {
+    FLAG_QUOTE_NONE | FLAG_SQL_ANSI,
+    FLAG_QUOTE_NONE | FLAG_SQL_MYSQL,
+    FLAG_QUOTE_SINGLE | FLAG_SQL_ANSI,
+    FLAG_QUOTE_SINGLE | FLAG_SQL_MYSQL,
+    FLAG_QUOTE_DOUBLE | FLAG_SQL_ANSI,
+    FLAG_QUOTE_DOUBLE | FLAG_SQL_MYSQL,
+};
+
+static void print_hex(const char *s, size_t len)
+{
+    size_t i;
+    for (i = 0; i < len; i++) {
+        printf("%02x", (unsigned char)s[i]);
+    }
+}
+
+static int unhex(int c)
+{
+    if (c >= '0' && c <= '9') return c - '0';
+    if (c >= 'a' && c <= 'f') return c - 'a' + 10;
+    if (c >= 'A' && c <= 'F') return c - 'A' + 10;
+    return -1;
+}
+
+/* decodes in place, returns the length or -1 */
+static long hex_decode(char *s, size_t len)
+{
+    size_t i;
+    if (len % 2 != 0) return -1;
+    for (i = 0; i < len; i += 2) {
+        int hi = unhex(s[i]), lo = unhex(s[i + 1]);
+        if (hi < 0 || lo < 0) return -1;
+        s[i / 2] = (char)(hi << 4 | lo);
+    }
+    return (long)(len / 2);
+}
+
+static void record(const char *s, size_t len)
+{
+    struct libinjection_sqli_state state;
+    char fingerprint[8];
+    size_t p;
+    int issqli, fplen, i;
+
+    issqli = libinjection_sqli(s, len, fingerprint);
+    printf("*\t");
+    print_hex(s, len);
+    printf("\t-\t-\t%s\t%d\n", fingerprint, issqli ? 1 : 0);
+
+    for (p = 0; p < sizeof(passes) / sizeof(passes[0]); p++) {
+        printf("%d\t", passes[p]);
+        print_hex(s, len);
+        putchar('\t');
+
+        libinjection_sqli_init(&state, s, len, passes[p]);
+        while (libinjection_sqli_tokenize(&state)) {
+            putchar(state.current->type);
+        }
+        putchar('\t');
+
+        libinjection_sqli_init(&state, s, len, passes[p]);
+        fplen = libinjection_sqli_fold(&state);
+        for (i = 0; i < fplen; i++) {
+            putchar(state.tokenvec[i].type);
+        }
+        putchar('\t');
+
+        libinjection_sqli_init(&state, s, len, passes[p]);
+        libinjection_sqli_fingerprint(&state, passes[p]);
+        issqli = libinjection_sqli_blacklist(&state) && libinjection_sqli_not_whitelist(&state);
+        printf("%s\t%d\n", state.fingerprint, issqli ? 1 : 0);
+    }
+}
+
+int main(int argc, char *argv[])
+{
+    char *line = NULL;
+    size_t cap = 0;
+    ssize_t n;
+    long len;
+    int hex = argc > 1 && strcmp(argv[1], "-x") == 0;
+
+    printf("# recorded with libinjection %s\n", libinjection_version());
+    while ((n = getline(&line, &cap, stdin)) != -1) {
+        if (n > 0 && line[n - 1] == '\n') {
+            n--;
+        }
+        len = n;
+        if (hex && (len = hex_decode(line, (size_t)n)) < 0) {
+            fprintf(stderr, "invalid hex line: %.*s\n", (int)n, line);
+            return 2;
+        }
+        record(line, (size_t)len);
+    }
+    free(line);
+    return 0;
+}
@@ -0,0 +1,33 @@
+#!/bin/bash
+#
+# record.sh builds record.c against the C libinjection and records a corpus
+# of the inputs written by differential/inputs, for TestCorpus and
+# 'libinjection diff'. Run it from the root of the repository:
+#
+#	differential/record/record.sh [version [count]]
+#
+# version is a tag of https://github.com/libinjection/libinjection, v3.9.2
+# by default, count the number of random inputs. With LIBINJECTION_SRC set
+# to a checkout of that tag, nothing is cloned. The corpus is written to
+# differential/testdata/recorded-<version>.tsv.gz, to be checked in: the
+# tests replay it without the C library.
+set -euo pipefail
+
+version=${1:-v3.9.2}
+count=${2:-300000}
+work=$(mktemp -d)
+trap 'rm -rf "$work"' EXIT
+
+src=${LIBINJECTION_SRC:-}
+if [ -z "$src" ]; then
+	git clone --quiet --depth 1 --branch "$version" \
+		https://github.com/libinjection/libinjection "$work/libinjection"
+	src=$work/libinjection
+fi
+src=$src/src
+${CC:-cc} -O2 -I "$src" -o "$work/record" differential/record/record.c \
+	"$src/libinjection_sqli.c" "$src/libinjection_html5.c" "$src/libinjection_xss.c"
+
+go run ./differential/inputs -tests tests -n "$count" |
+	"$work/record" -x |
+	gzip > "differential/testdata/recorded-$version.tsv.gz"
@@ -0,0 +1,128 @@
+package libinjection
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+/*
+ * Runs the upstream test files in tests/. Each file has a --TEST--,
+ * --INPUT-- and --EXPECTED-- section, the kind of test is given by the file
+ * name: test-tokens-*, test-folding-*, test-sqli-* or test-html5-*.
+ */
+func readTestFile(t testing.TB, path string) (string, string) {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	sections := map[string]string{}
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch line {
+		case "--TEST--", "--INPUT--", "--EXPECTED--":
+			section = line
+		default:
+			sections[section] += line + "\n"
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(sections["--INPUT--"]), strings.TrimSpace(sections["--EXPECTED--"])
+}
+
+func printToken(token *Token) string {
+	var b strings.Builder
+	b.WriteByte(token.Type)
+	b.WriteByte(' ')
+	if token.Type == TYPE_VARIABLE {
+		b.WriteString(strings.Repeat("@", token.count))
+	}
+	if token.Type == TYPE_STRING || token.Type == TYPE_VARIABLE {
+		if token.str_open != CHAR_NULL {
+			b.WriteByte(token.str_open)
+		}
+		b.WriteString(token.val)
+		if token.str_close != CHAR_NULL {
+			b.WriteByte(token.str_close)
+		}
+	} else {
+		b.WriteString(token.val)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func printH5Token(hs *h5_state) string {
+	types := []string{
+		"DATA_TEXT", "TAG_NAME_OPEN", "TAG_NAME_CLOSE", "TAG_NAME_SELFCLOSE", "TAG_DATA",
+		"TAG_CLOSE", "ATTR_NAME", "ATTR_VALUE", "TAG_COMMENT", "DOCTYPE",
+	}
+	return strings.TrimSpace(fmt.Sprintf("%s,%d,%s", types[hs.token_type], hs.token_len, hs.token()))
+}
+
+func TestDriver(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("tests", "test-*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range files {
+		name := filepath.Base(file)
+		var run func(input string) string
+		switch {
+		case strings.HasPrefix(name, "test-tokens-"):
+			run = func(input string) string {
+				sqli := &Sqli{state: newState(input, len(input), FLAG_QUOTE_NONE|FLAG_SQL_ANSI)}
+				out := []string{}
+				for sqli.libinjection_sqli_tokenize() {
+					out = append(out, printToken(&sqli.state.tokenvec[sqli.state.current]))
+				}
+				return strings.Join(out, "\n")
+			}
+		case strings.HasPrefix(name, "test-folding-"):
+			run = func(input string) string {
+				sqli := &Sqli{state: newState(input, len(input), FLAG_QUOTE_NONE|FLAG_SQL_ANSI)}
+				fplen, err := sqli.libinjection_sqli_fold()
+				if err != nil {
+					t.Fatal(err)
+				}
+				out := []string{}
+				for i := 0; i < fplen; i++ {
+					out = append(out, printToken(&sqli.state.tokenvec[i]))
+				}
+				return strings.Join(out, "\n")
+			}
+		case strings.HasPrefix(name, "test-sqli-"):
+			run = func(input string) string {
+				_, fingerprint := IsSQLi(input)
+				return fingerprint
+			}
+		case strings.HasPrefix(name, "test-html5-"):
+			run = func(input string) string {
+				hs := newH5State(input, len(input), DATA_STATE)
+				out := []string{}
+				for hs.libinjection_h5_next() {
+					out = append(out, printH5Token(hs))
+				}
+				return strings.Join(out, "\n")
+			}
+		default:
+			continue
+		}
+
+		t.Run(name, func(t *testing.T) {
+			input, expected := readTestFile(t, file)
+			if actual := run(input); actual != expected {
+				t.Errorf("input %q\nexpected:\n%s\ngot:\n%s", input, expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+/*
+ * Package extauthz runs the SQLi and XSS detectors on the requests Envoy
+ * forwards to an external service, so the library can be deployed as a
+ * sidecar WAF. Server implements both Envoy APIs:
+ *
+ *	envoy.service.auth.v3.Authorization        (ext_authz filter)
+ *	envoy.service.ext_proc.v3.ExternalProcessor (ext_proc filter)
+ *
+ * Path segments, query parameters, headers, cookies and the body are
+ * checked. A flagged request is denied with 403, and the deny response
+ * carries the detector in the x-libinjection-type header and the SQLi
+ * fingerprint in x-libinjection-fingerprint. In ModeLog the request goes
+ * through with those headers added for the upstream.
+ *
+ * ext_authz only sees the body when the filter is configured with
+ * with_request_body. ext_proc sees it with request_body_mode BUFFERED or
+ * STREAMED.
+ */
+package extauthz
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+
+	"github.com/jptosso/libinjection-go/httpparams"
+)
+
+/*
+ * Headers set on denied requests, and on flagged requests sent upstream in
+ * ModeLog. Requests from clients never reach upstream with their own.
+ */
+const (
+	TypeHeader        = "x-libinjection-type"
+	FingerprintHeader = "x-libinjection-fingerprint"
+)
+
+/*
+ * Mode tells the server what to do with a flagged request.
+ */
+type Mode int
+
+const (
+	/* deny the request with 403 */
+	ModeReject Mode = iota
+	/* only report the finding, the request goes through */
+	ModeLog
+)
+
+/*
+ * Finding describes a flagged value of a request.
+ */
+type Finding struct {
+	Method      string /* HTTP method */
+	Path        string /* request path, with the query */
+	Location    string /* e.g. query:<name>, header:<name>, body.user.id */
+	Value       string
+	Type        string /* "sqli", "xss", "crlf" or "xxe" */
+	Fingerprint string /* SQLi, the header CRLF injects, or what XXE found */
+}
+
+/*
+ * Logger is called once for every flagged value, in both modes.
+ */
+type Logger func(ctx context.Context, finding Finding)
+
+/*
+ * Server implements the ext_authz and ext_proc gRPC services.
+ */
+type Server struct {
+	authv3.UnimplementedAuthorizationServer
+	extprocv3.UnimplementedExternalProcessorServer
+
+	mode Mode
+	log  Logger
+	/* request bodies streamed through ext_proc are only checked up to this size */
+	maxBody int
+}
+
+/*
+ * NewServer returns a Server running the SQLi, XSS, CRLF and XXE detectors.
+ * log may be nil.
+ */
+func NewServer(mode Mode, log Logger) *Server {
+	return &Server{mode: mode, log: log, maxBody: 1 << 20}
+}
+
+/*
+ * SetMaxBody changes how much of a request body ext_proc buffers, 1MB by
+ * default. ext_authz gets the body Envoy was configured to send.
+ */
+func (s *Server) SetMaxBody(n int) {
+	s.maxBody = n
+}
+
+/*
+ * inspect checks params and reports every finding. It returns the first,
+ * which decides the response, or nil.
+ */
+func (s *Server) inspect(ctx context.Context, method, path string, params []httpparams.Param) *Finding {
+	var first *Finding
+	for _, param := range params {
+		typ, fingerprint := httpparams.Detect(param.Value, httpparams.Detectors{SQLi: true, XSS: true, CRLF: true, XXE: true})
+		if typ == "" {
+			continue
+		}
+		f := Finding{
+			Method:      method,
+			Path:        path,
+			Location:    param.Location,
+			Value:       param.Value,
+			Type:        typ,
+			Fingerprint: fingerprint,
+		}
+		if s.log != nil {
+			s.log(ctx, f)
+		}
+		if first == nil {
+			first = &f
+		}
+	}
+	return first
+}
+
+/*
+ * headerParams collects the values of Envoy's header list, with
+ * :authority checked as Host. Pseudo headers are skipped, :path is
+ * returned for the caller to split, with the content type.
+ */
+func headerParams(headers func(func(name, value string))) (params []httpparams.Param, path, contentType string) {
+	header := http.Header{}
+	var host string
+	headers(func(name, value string) {
+		switch {
+		case name == ":path":
+			path = value
+		case name == ":authority":
+			host = value
+		case strings.HasPrefix(name, ":"):
+		case strings.EqualFold(name, "host"):
+			host = value
+		default:
+			header.Add(name, value)
+		}
+	})
+	params = httpparams.Headers(header)
+	if host != "" {
+		params = append(params, httpparams.Param{Location: "header:Host", Value: host})
+	}
+	return params, path, header.Get("Content-Type")
+}
+
+/*
+ * Check is the ext_authz call, made once per request with its attributes.
+ */
+func (s *Server) Check(ctx context.Context, req *authv3.CheckRequest) (*authv3.CheckResponse, error) {
+	attrs := req.GetAttributes().GetRequest().GetHttp()
+
+	params, _, contentType := headerParams(func(f func(name, value string)) {
+		if hm := attrs.GetHeaderMap(); hm != nil {
+			for _, h := range hm.GetHeaders() {
+				f(h.GetKey(), headerValue(h.GetValue(), h.GetRawValue()))
+			}
+			return
+		}
+		for name, value := range attrs.GetHeaders() {
+			f(name, value)
+		}
+	})
+	/* Envoy sends the query in path, and again in query */
+	path := attrs.GetPath()
+	if attrs.GetQuery() != "" && !strings.Contains(path, "?") {
+		path += "?" + attrs.GetQuery()
+	}
+	params = append(httpparams.Target(path), params...)
+
+	body := attrs.GetRawBody()
+	if len(body) == 0 {
+		body = []byte(attrs.GetBody())
+	}
+	params = append(params, httpparams.Body(contentType, body)...)
+
+	finding := s.inspect(ctx, attrs.GetMethod(), path, params)
+	if finding != nil && s.mode == ModeReject {
+		return &authv3.CheckResponse{
+			Status: &rpcstatus.Status{Code: int32(codes.PermissionDenied), Message: denyMessage(finding)},
+			HttpResponse: &authv3.CheckResponse_DeniedResponse{
+				DeniedResponse: &authv3.DeniedHttpResponse{
+					Status:  &typev3.HttpStatus{Code: typev3.StatusCode_Forbidden},
+					Headers: headerOptions(finding, false),
+					Body:    denyMessage(finding) + "\n",
+				},
+			},
+		}, nil
+	}
+	return &authv3.CheckResponse{
+		Status: &rpcstatus.Status{Code: int32(codes.OK)},
+		HttpResponse: &authv3.CheckResponse_OkResponse{
+			OkResponse: &authv3.OkHttpResponse{
+				Headers:         headerOptions(finding, false),
+				HeadersToRemove: staleHeaders(finding),
+			},
+		},
+	}, nil
+}
+
+func denyMessage(f *Finding) string {
+	what := "SQL injection"
+	switch f.Type {
+	case "xss":
+		what = "XSS"
+	case "crlf":
+		what = "CRLF injection"
+	case "xxe":
+		what = "XXE"
+	}
+	return what + " detected in " + f.Location
+}
+
+/*
+ * headerOptions are the headers telling what f is, none when f is nil.
+ * ext_proc wants raw values.
+ */
+func headerOptions(f *Finding, raw bool) []*corev3.HeaderValueOption {
+	if f == nil {
+		return nil
+	}
+	set := func(key, value string) *corev3.HeaderValueOption {
+		h := &corev3.HeaderValue{Key: key, Value: value}
+		if raw {
+			h = &corev3.HeaderValue{Key: key, RawValue: []byte(value)}
+		}
+		return &corev3.HeaderValueOption{
+			Header:       h,
+			AppendAction: corev3.HeaderValueOption_OVERWRITE_IF_EXISTS_OR_ADD,
+		}
+	}
+	options := []*corev3.HeaderValueOption{set(TypeHeader, f.Type)}
+	if f.Fingerprint != "" {
+		options = append(options, set(FingerprintHeader, f.Fingerprint))
+	}
+	return options
+}
+
+/*
+ * staleHeaders are the x-libinjection headers a client may have sent that
+ * headerOptions doesn't overwrite. Envoy applies removals after additions.
+ */
+func staleHeaders(f *Finding) []string {
+	switch {
+	case f == nil:
+		return []string{TypeHeader, FingerprintHeader}
+	case f.Fingerprint == "":
+		return []string{FingerprintHeader}
+	default:
+		return nil
+	}
+}
+
+func headerValue(value string, raw []byte) string {
+	if value == "" && len(raw) != 0 {
+		return string(raw)
+	}
+	return value
+}
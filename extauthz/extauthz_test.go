@@ -0,0 +1,252 @@
+package extauthz
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+/*
+ * envoy dials a Server over an in-memory listener, the way Envoy would
+ * over the network.
+ */
+func envoy(t *testing.T, s *Server) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	authv3.RegisterAuthorizationServer(srv, s)
+	extprocv3.RegisterExternalProcessorServer(srv, s)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+type recorder struct {
+	mu       sync.Mutex
+	findings []Finding
+}
+
+func (r *recorder) log(ctx context.Context, f Finding) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.findings = append(r.findings, f)
+}
+
+func checkRequest(method, path string, headers map[string]string, body string) *authv3.CheckRequest {
+	return &authv3.CheckRequest{Attributes: &authv3.AttributeContext{
+		Request: &authv3.AttributeContext_Request{
+			Http: &authv3.AttributeContext_HttpRequest{
+				Method:  method,
+				Path:    path,
+				Host:    "example.com",
+				Headers: headers,
+				Body:    body,
+			},
+		},
+	}}
+}
+
+func headerMap(opts []*corev3.HeaderValueOption) map[string]string {
+	m := map[string]string{}
+	for _, o := range opts {
+		m[o.GetHeader().GetKey()] = headerValue(o.GetHeader().GetValue(), o.GetHeader().GetRawValue())
+	}
+	return m
+}
+
+func TestCheck(t *testing.T) {
+	rec := &recorder{}
+	client := authv3.NewAuthorizationClient(envoy(t, NewServer(ModeReject, rec.log)))
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		req         *authv3.CheckRequest
+		code        codes.Code
+		typ         string
+		fingerprint string
+	}{
+		{
+			name: "benign",
+			req:  checkRequest("GET", "/books/o'reilly?q=hello+world", map[string]string{":path": "/books", "user-agent": "curl/8.0"}, ""),
+			code: codes.OK,
+		},
+		{
+			name:        "query",
+			req:         checkRequest("GET", "/search?id=1%27+or+%271%27%3D%271", nil, ""),
+			code:        codes.PermissionDenied,
+			typ:         "sqli",
+			fingerprint: "s&sos",
+		},
+		{
+			name: "cookie",
+			req:  checkRequest("GET", "/", map[string]string{"cookie": "session=abc; pref=%3Cscript%3Ealert(1)%3C/script%3E"}, ""),
+			code: codes.PermissionDenied,
+			typ:  "xss",
+		},
+		{
+			name:        "json body",
+			req:         checkRequest("POST", "/api", map[string]string{"content-type": "application/json"}, `{"user": {"id": "1 union select password from users"}}`),
+			code:        codes.PermissionDenied,
+			typ:         "sqli",
+			fingerprint: "1UEnk",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.Check(ctx, tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if code := codes.Code(resp.GetStatus().GetCode()); code != tt.code {
+				t.Fatalf("expected %v, got %v", tt.code, code)
+			}
+			if tt.code == codes.OK {
+				ok := resp.GetOkResponse()
+				if ok == nil || len(ok.GetHeaders()) != 0 || len(ok.GetHeadersToRemove()) != 2 {
+					t.Errorf("unexpected ok response %v", resp)
+				}
+				return
+			}
+			denied := resp.GetDeniedResponse()
+			if denied.GetStatus().GetCode() != typev3.StatusCode_Forbidden {
+				t.Errorf("expected 403, got %v", denied.GetStatus())
+			}
+			headers := headerMap(denied.GetHeaders())
+			if headers[TypeHeader] != tt.typ || headers[FingerprintHeader] != tt.fingerprint {
+				t.Errorf("expected %s %q, got headers %v", tt.typ, tt.fingerprint, headers)
+			}
+		})
+	}
+
+	if len(rec.findings) != 3 {
+		t.Fatalf("expected 3 findings, got %v", rec.findings)
+	}
+	if f := rec.findings[2]; f.Method != "POST" || f.Path != "/api" || f.Location != "body.user.id" {
+		t.Errorf("unexpected finding %+v", f)
+	}
+}
+
+func TestCheckModeLog(t *testing.T) {
+	client := authv3.NewAuthorizationClient(envoy(t, NewServer(ModeLog, nil)))
+	resp, err := client.Check(context.Background(), checkRequest("GET", "/?id=1%27+or+%271%27%3D%271", nil, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if codes.Code(resp.GetStatus().GetCode()) != codes.OK {
+		t.Fatalf("expected OK in ModeLog, got %v", resp.GetStatus())
+	}
+	headers := headerMap(resp.GetOkResponse().GetHeaders())
+	if headers[TypeHeader] != "sqli" || headers[FingerprintHeader] != "s&sos" {
+		t.Errorf("expected headers for upstream, got %v", headers)
+	}
+}
+
+func processHeaders(path, contentType string, endOfStream bool) *extprocv3.ProcessingRequest {
+	headers := []*corev3.HeaderValue{
+		{Key: ":method", RawValue: []byte("POST")},
+		{Key: ":path", RawValue: []byte(path)},
+		{Key: ":authority", RawValue: []byte("example.com")},
+	}
+	if contentType != "" {
+		headers = append(headers, &corev3.HeaderValue{Key: "content-type", RawValue: []byte(contentType)})
+	}
+	return &extprocv3.ProcessingRequest{Request: &extprocv3.ProcessingRequest_RequestHeaders{
+		RequestHeaders: &extprocv3.HttpHeaders{Headers: &corev3.HeaderMap{Headers: headers}, EndOfStream: endOfStream},
+	}}
+}
+
+func processBody(body string, endOfStream bool) *extprocv3.ProcessingRequest {
+	return &extprocv3.ProcessingRequest{Request: &extprocv3.ProcessingRequest_RequestBody{
+		RequestBody: &extprocv3.HttpBody{Body: []byte(body), EndOfStream: endOfStream},
+	}}
+}
+
+func TestProcess(t *testing.T) {
+	client := extprocv3.NewExternalProcessorClient(envoy(t, NewServer(ModeReject, nil)))
+	ctx := context.Background()
+
+	/* a clean request, then its response */
+	stream, err := client.Process(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, req := range []*extprocv3.ProcessingRequest{
+		processHeaders("/search?q=hello", "application/x-www-form-urlencoded", false),
+		processBody("name=o%27reilly", true),
+		{Request: &extprocv3.ProcessingRequest_ResponseHeaders{ResponseHeaders: &extprocv3.HttpHeaders{}}},
+	} {
+		if err := stream.Send(req); err != nil {
+			t.Fatal(err)
+		}
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.GetImmediateResponse() != nil {
+			t.Fatalf("clean request denied: %v", resp)
+		}
+	}
+	stream.CloseSend()
+
+	/* an attack in a body streamed in two chunks */
+	stream, err = client.Process(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, req := range []*extprocv3.ProcessingRequest{
+		processHeaders("/login", "application/x-www-form-urlencoded", false),
+		processBody("user=admin%27+or+%271", false),
+	} {
+		if err := stream.Send(req); err != nil {
+			t.Fatal(err)
+		}
+		if resp, err := stream.Recv(); err != nil || resp.GetImmediateResponse() != nil {
+			t.Fatalf("expected the request to continue, got %v, %v", resp, err)
+		}
+	}
+	if err := stream.Send(processBody("%27%3D%271", true)); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	immediate := resp.GetImmediateResponse()
+	if immediate.GetStatus().GetCode() != typev3.StatusCode_Forbidden {
+		t.Fatalf("expected 403, got %v", resp)
+	}
+	if headers := headerMap(immediate.GetHeaders().GetSetHeaders()); headers[TypeHeader] != "sqli" || headers[FingerprintHeader] == "" {
+		t.Errorf("missing fingerprint headers: %v", headers)
+	}
+
+	/* an attack in the path is denied on the headers */
+	stream, err = client.Process(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(processHeaders("/item?q=%3Cscript%3Ealert(1)%3C/script%3E", "", true)); err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := stream.Recv(); err != nil || resp.GetImmediateResponse() == nil {
+		t.Fatalf("expected an immediate response, got %v, %v", resp, err)
+	}
+}
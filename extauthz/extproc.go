@@ -0,0 +1,147 @@
+package extauthz
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jptosso/libinjection-go/httpparams"
+)
+
+/*
+ * exchange is what ext_proc remembers of one request between messages.
+ */
+type exchange struct {
+	method, path, contentType string
+	body                      []byte
+	finding                   *Finding /* set in ModeLog only */
+}
+
+/*
+ * Process is the ext_proc stream, one per request. Headers are checked
+ * as soon as they arrive, the body once it is complete. Response messages
+ * are let through untouched.
+ */
+func (s *Server) Process(stream extprocv3.ExternalProcessor_ProcessServer) error {
+	ctx := stream.Context()
+	var ex exchange
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var resp *extprocv3.ProcessingResponse
+		switch r := req.GetRequest().(type) {
+		case *extprocv3.ProcessingRequest_RequestHeaders:
+			resp = s.requestHeaders(ctx, &ex, r.RequestHeaders)
+		case *extprocv3.ProcessingRequest_RequestBody:
+			resp = s.requestBody(ctx, &ex, r.RequestBody)
+		case *extprocv3.ProcessingRequest_RequestTrailers:
+			resp = &extprocv3.ProcessingResponse{Response: &extprocv3.ProcessingResponse_RequestTrailers{
+				RequestTrailers: &extprocv3.TrailersResponse{},
+			}}
+		case *extprocv3.ProcessingRequest_ResponseHeaders:
+			resp = &extprocv3.ProcessingResponse{Response: &extprocv3.ProcessingResponse_ResponseHeaders{
+				ResponseHeaders: &extprocv3.HeadersResponse{},
+			}}
+		case *extprocv3.ProcessingRequest_ResponseBody:
+			resp = &extprocv3.ProcessingResponse{Response: &extprocv3.ProcessingResponse_ResponseBody{
+				ResponseBody: &extprocv3.BodyResponse{},
+			}}
+		case *extprocv3.ProcessingRequest_ResponseTrailers:
+			resp = &extprocv3.ProcessingResponse{Response: &extprocv3.ProcessingResponse_ResponseTrailers{
+				ResponseTrailers: &extprocv3.TrailersResponse{},
+			}}
+		default:
+			return status.Errorf(codes.InvalidArgument, "unexpected processing request %T", r)
+		}
+
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+		if resp.GetImmediateResponse() != nil {
+			/* Envoy answered the client, nothing more will come */
+			return nil
+		}
+	}
+}
+
+func (s *Server) requestHeaders(ctx context.Context, ex *exchange, h *extprocv3.HttpHeaders) *extprocv3.ProcessingResponse {
+	params, path, contentType := headerParams(func(f func(name, value string)) {
+		for _, hv := range h.GetHeaders().GetHeaders() {
+			if hv.GetKey() == ":method" {
+				ex.method = headerValue(hv.GetValue(), hv.GetRawValue())
+			}
+			f(hv.GetKey(), headerValue(hv.GetValue(), hv.GetRawValue()))
+		}
+	})
+	ex.path, ex.contentType = path, contentType
+	params = append(httpparams.Target(path), params...)
+
+	finding := s.inspect(ctx, ex.method, ex.path, params)
+	if finding != nil && s.mode == ModeReject {
+		return deny(finding)
+	}
+	ex.finding = finding
+	return &extprocv3.ProcessingResponse{Response: &extprocv3.ProcessingResponse_RequestHeaders{
+		RequestHeaders: &extprocv3.HeadersResponse{Response: &extprocv3.CommonResponse{
+			HeaderMutation: &extprocv3.HeaderMutation{
+				SetHeaders:    headerOptions(finding, true),
+				RemoveHeaders: staleHeaders(finding),
+			},
+		}},
+	}}
+}
+
+func (s *Server) requestBody(ctx context.Context, ex *exchange, b *extprocv3.HttpBody) *extprocv3.ProcessingResponse {
+	/* streamed bodies come in chunks, they are checked as a whole */
+	if room := s.maxBody - len(ex.body); room > 0 {
+		chunk := b.GetBody()
+		if len(chunk) > room {
+			chunk = chunk[:room]
+		}
+		ex.body = append(ex.body, chunk...)
+	}
+
+	if !b.GetEndOfStream() {
+		return &extprocv3.ProcessingResponse{Response: &extprocv3.ProcessingResponse_RequestBody{
+			RequestBody: &extprocv3.BodyResponse{},
+		}}
+	}
+
+	finding := s.inspect(ctx, ex.method, ex.path, httpparams.Body(ex.contentType, ex.body))
+	if finding != nil && s.mode == ModeReject {
+		return deny(finding)
+	}
+	body := &extprocv3.BodyResponse{}
+	if ex.finding == nil && finding != nil {
+		/* headers can only be changed here when the body is buffered */
+		ex.finding = finding
+		body.Response = &extprocv3.CommonResponse{
+			HeaderMutation: &extprocv3.HeaderMutation{SetHeaders: headerOptions(finding, true)},
+		}
+	}
+	return &extprocv3.ProcessingResponse{Response: &extprocv3.ProcessingResponse_RequestBody{
+		RequestBody: body,
+	}}
+}
+
+func deny(f *Finding) *extprocv3.ProcessingResponse {
+	return &extprocv3.ProcessingResponse{Response: &extprocv3.ProcessingResponse_ImmediateResponse{
+		ImmediateResponse: &extprocv3.ImmediateResponse{
+			Status:  &typev3.HttpStatus{Code: typev3.StatusCode_Forbidden},
+			Headers: &extprocv3.HeaderMutation{SetHeaders: headerOptions(f, true)},
+			Body:    []byte(denyMessage(f) + "\n"),
+			Details: "libinjection_" + f.Type,
+		},
+	}}
+}
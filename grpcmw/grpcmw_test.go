@@ -0,0 +1,111 @@
+package grpcmw
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const attack = "1' or '1'='1"
+
+func TestInspect(t *testing.T) {
+	msg, err := structpb.NewStruct(map[string]interface{}{
+		"name": "O'Reilly",
+		"tags": []interface{}{"books", attack},
+		"user": map[string]interface{}{"id": attack},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	findings := Inspect(msg)
+	fields := map[string]bool{}
+	for _, f := range findings {
+		fields[f.Field] = true
+	}
+	for _, want := range []string{
+		"fields[tags].list_value.values[1].string_value",
+		"fields[user].struct_value.fields[id].string_value",
+	} {
+		if !fields[want] {
+			t.Errorf("missing finding for %s, got %v", want, findings)
+		}
+	}
+	if len(findings) != 2 {
+		t.Errorf("expected 2 findings, got %v", findings)
+	}
+
+	if findings := Inspect(wrapperspb.Bytes([]byte(attack))); len(findings) != 1 || findings[0].Field != "value" {
+		t.Errorf("bytes field not checked: %v", findings)
+	}
+	if findings := Inspect(wrapperspb.String("hello world")); len(findings) != 0 {
+		t.Errorf("unexpected findings: %v", findings)
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Call"}
+	called := false
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		called = true
+		return req, nil
+	}
+
+	_, err := UnaryServerInterceptor(ModeReject, nil)(context.Background(), wrapperspb.String(attack), info, handler)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument, got %v", err)
+	}
+	if called {
+		t.Error("handler called on rejected request")
+	}
+
+	var logged []Finding
+	log := func(ctx context.Context, f Finding) {
+		logged = append(logged, f)
+	}
+	if _, err := UnaryServerInterceptor(ModeLog, log)(context.Background(), wrapperspb.String(attack), info, handler); err != nil {
+		t.Fatal(err)
+	}
+	if !called {
+		t.Error("handler not called in log mode")
+	}
+	if len(logged) != 1 || logged[0].Method != info.FullMethod || logged[0].Fingerprint == "" {
+		t.Errorf("unexpected findings: %v", logged)
+	}
+}
+
+type fakeStream struct {
+	grpc.ServerStream
+	msgs []proto.Message
+}
+
+func (fs *fakeStream) Context() context.Context {
+	return context.Background()
+}
+
+func (fs *fakeStream) RecvMsg(m interface{}) error {
+	proto.Merge(m.(proto.Message), fs.msgs[0])
+	fs.msgs = fs.msgs[1:]
+	return nil
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	info := &grpc.StreamServerInfo{FullMethod: "/test.Service/Stream"}
+	stream := &fakeStream{msgs: []proto.Message{wrapperspb.String("hello"), wrapperspb.String(attack)}}
+
+	err := StreamServerInterceptor(ModeReject, nil)(nil, stream, info, func(srv interface{}, ss grpc.ServerStream) error {
+		if err := ss.RecvMsg(&wrapperspb.StringValue{}); err != nil {
+			t.Errorf("first message rejected: %v", err)
+		}
+		return ss.RecvMsg(&wrapperspb.StringValue{})
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument, got %v", err)
+	}
+}
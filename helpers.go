@@ -2,26 +2,56 @@ package libinjection
 
 import "strings"
 
+/*
+ * The C implementation uses strchr() on the set, which always matches the
+ * terminating null. So a null byte is never accepted by strlencspn and
+ * always accepted by strlenspn.
+ */
 func strlencspn(s string, unaccepted string) int {
 	l := len(s)
 	for i := 0; i < l; i++ {
-		if strings.IndexByte(unaccepted, s[1]) != -1 {
+		if s[i] == CHAR_NULL || strings.IndexByte(unaccepted, s[i]) != -1 {
 			return i
 		}
 	}
-	return len(s)
+	return l
 }
 
 func strlenspn(s string, accept string) int {
 	l := len(s)
 	for i := 0; i < l; i++ {
-		if strings.IndexByte(accept, s[i]) == -1 {
+		if s[i] != CHAR_NULL && strings.IndexByte(accept, s[i]) == -1 {
 			return i
 		}
 	}
 	return l
 }
 
+/*
+ * Same as strings.IndexByte, but starts at from and returns an index into
+ * the whole string.
+ */
+func index_byte_from(s string, from int, c byte) int {
+	if from >= len(s) {
+		return -1
+	}
+	i := strings.IndexByte(s[from:], c)
+	if i == -1 {
+		return -1
+	}
+	return from + i
+}
+
+/*
+ * s[i], or CHAR_NULL when reading past the end like the C version would
+ */
+func char_at(s string, i int) byte {
+	if i < 0 || i >= len(s) {
+		return CHAR_NULL
+	}
+	return s[i]
+}
+
 func flag2delim(flag int) byte {
 	if (flag & FLAG_QUOTE_SINGLE) != 0 {
 		return CHAR_SINGLE
@@ -115,3 +145,20 @@ func char_is_white(ch byte) bool {
 		return false
 	}
 }
+
+/*
+ * The min and max built-ins need go1.21, this module builds with go1.16.
+ */
+func imin(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func imax(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,680 @@
+package libinjection
+
+import "strings"
+
+const (
+	//html5 token types
+	DATA_TEXT          = 0
+	TAG_NAME_OPEN      = 1
+	TAG_NAME_CLOSE     = 2
+	TAG_NAME_SELFCLOSE = 3
+	TAG_DATA           = 4
+	TAG_CLOSE          = 5
+	ATTR_NAME          = 6
+	ATTR_VALUE         = 7
+	TAG_COMMENT        = 8
+	DOCTYPE            = 9
+
+	//html5 flags, the context the input is injected into
+	DATA_STATE         = 0
+	VALUE_NO_QUOTE     = 1
+	VALUE_SINGLE_QUOTE = 2
+	VALUE_DOUBLE_QUOTE = 3
+	VALUE_BACK_QUOTE   = 4
+
+	CHAR_EOF      = -1
+	CHAR_BANG     = '!'
+	CHAR_PERCENT  = '%'
+	CHAR_DASH     = '-'
+	CHAR_SLASH    = '/'
+	CHAR_LT       = '<'
+	CHAR_EQUALS   = '='
+	CHAR_GT       = '>'
+	CHAR_QUESTION = '?'
+	CHAR_RIGHTB   = ']'
+)
+
+/*
+ * A tiny html5 tokenizer, just enough to find tags, attributes and comments
+ * for XSS detection. Each state function returns true if a token was found,
+ * and sets state to the function to use for the next one.
+ */
+type h5_state struct {
+	s           string
+	len         int
+	pos         int
+	is_close    bool
+	state       func() bool
+	token_start int
+	token_len   int
+	token_type  int
+}
+
+func newH5State(s string, l int, flags int) *h5_state {
+	hs := &h5_state{
+		s:   s,
+		len: l,
+	}
+
+	switch flags {
+	case DATA_STATE:
+		hs.state = hs.h5_state_data
+	case VALUE_NO_QUOTE:
+		hs.state = hs.h5_state_before_attribute_name
+	case VALUE_SINGLE_QUOTE:
+		hs.state = hs.h5_state_attribute_value_single_quote
+	case VALUE_DOUBLE_QUOTE:
+		hs.state = hs.h5_state_attribute_value_double_quote
+	case VALUE_BACK_QUOTE:
+		hs.state = hs.h5_state_attribute_value_back_quote
+	default:
+		hs.state = hs.h5_state_eof
+	}
+	return hs
+}
+
+/*
+ * Gets the next token, false at the end of input
+ */
+func (hs *h5_state) libinjection_h5_next() bool {
+	return hs.state()
+}
+
+/*
+ * The token value
+ */
+func (hs *h5_state) token() string {
+	return hs.s[hs.token_start : hs.token_start+hs.token_len]
+}
+
+/*
+ * Same as the C version, which uses strchr(): a null byte is white.
+ */
+func h5_is_white(ch byte) bool {
+	return ch == CHAR_NULL || strings.IndexByte(" \t\n\v\f\r", ch) != -1
+}
+
+func (hs *h5_state) h5_skip_white() int {
+	for hs.pos < hs.len {
+		ch := hs.s[hs.pos]
+		switch ch {
+		case 0x00, /* IE only */
+			0x20, 0x09, 0x0A, 0x0B, 0x0C,
+			0x0D: /* IE only */
+			hs.pos += 1
+		default:
+			return int(ch)
+		}
+	}
+	return CHAR_EOF
+}
+
+func (hs *h5_state) h5_state_eof() bool {
+	return false
+}
+
+func (hs *h5_state) h5_state_data() bool {
+	idx := index_byte_from(hs.s[:hs.len], hs.pos, CHAR_LT)
+	if idx == -1 {
+		hs.token_start = hs.pos
+		hs.token_len = hs.len - hs.pos
+		hs.token_type = DATA_TEXT
+		hs.state = hs.h5_state_eof
+		if hs.token_len == 0 {
+			return false
+		}
+	} else {
+		hs.token_start = hs.pos
+		hs.token_type = DATA_TEXT
+		hs.token_len = idx - hs.pos
+		hs.pos = idx + 1
+		hs.state = hs.h5_state_tag_open
+		if hs.token_len == 0 {
+			return hs.h5_state_tag_open()
+		}
+	}
+	return true
+}
+
+/*
+ * 12 2.4.8
+ */
+func (hs *h5_state) h5_state_tag_open() bool {
+	if hs.pos >= hs.len {
+		return false
+	}
+	ch := hs.s[hs.pos]
+	if ch == CHAR_BANG {
+		hs.pos += 1
+		return hs.h5_state_markup_declaration_open()
+	} else if ch == CHAR_SLASH {
+		hs.pos += 1
+		hs.is_close = true
+		return hs.h5_state_end_tag_open()
+	} else if ch == CHAR_QUESTION {
+		hs.pos += 1
+		return hs.h5_state_bogus_comment()
+	} else if ch == CHAR_PERCENT {
+		/* this is not in spec.. alternative comment format used
+		   by IE <= 9 and Safari < 4.0.3 */
+		hs.pos += 1
+		return hs.h5_state_bogus_comment2()
+	} else if (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') {
+		return hs.h5_state_tag_name()
+	} else if ch == CHAR_NULL {
+		/* IE-ism  NULL characters are ignored */
+		return hs.h5_state_tag_name()
+	} else {
+		/* user input mistake in configuring state */
+		if hs.pos == 0 {
+			return hs.h5_state_data()
+		}
+		hs.token_start = hs.pos - 1
+		hs.token_len = 1
+		hs.token_type = DATA_TEXT
+		hs.state = hs.h5_state_data
+		return true
+	}
+}
+
+/*
+ * 12.2.4.9
+ */
+func (hs *h5_state) h5_state_end_tag_open() bool {
+	if hs.pos >= hs.len {
+		return false
+	}
+	ch := hs.s[hs.pos]
+	if ch == CHAR_GT {
+		return hs.h5_state_data()
+	} else if (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') {
+		return hs.h5_state_tag_name()
+	}
+
+	hs.is_close = false
+	return hs.h5_state_bogus_comment()
+}
+
+func (hs *h5_state) h5_state_tag_name_close() bool {
+	hs.is_close = false
+	hs.token_start = hs.pos
+	hs.token_len = 1
+	hs.token_type = TAG_NAME_CLOSE
+	hs.pos += 1
+	if hs.pos < hs.len {
+		hs.state = hs.h5_state_data
+	} else {
+		hs.state = hs.h5_state_eof
+	}
+	return true
+}
+
+/*
+ * 12.2.4.10
+ */
+func (hs *h5_state) h5_state_tag_name() bool {
+	pos := hs.pos
+	for pos < hs.len {
+		ch := hs.s[pos]
+		if ch == CHAR_NULL {
+			/* special non-standard case */
+			/* allow nulls in tag name   */
+			/* some old browsers apparently allow and ignore them */
+			pos += 1
+		} else if h5_is_white(ch) {
+			hs.token_start = hs.pos
+			hs.token_len = pos - hs.pos
+			hs.token_type = TAG_NAME_OPEN
+			hs.pos = pos + 1
+			hs.state = hs.h5_state_before_attribute_name
+			return true
+		} else if ch == CHAR_SLASH {
+			hs.token_start = hs.pos
+			hs.token_len = pos - hs.pos
+			hs.token_type = TAG_NAME_OPEN
+			hs.pos = pos + 1
+			hs.state = hs.h5_state_self_closing_start_tag
+			return true
+		} else if ch == CHAR_GT {
+			hs.token_start = hs.pos
+			hs.token_len = pos - hs.pos
+			if hs.is_close {
+				hs.pos = pos + 1
+				hs.is_close = false
+				hs.token_type = TAG_CLOSE
+				hs.state = hs.h5_state_data
+			} else {
+				hs.pos = pos
+				hs.token_type = TAG_NAME_OPEN
+				hs.state = hs.h5_state_tag_name_close
+			}
+			return true
+		} else {
+			pos += 1
+		}
+	}
+
+	hs.token_start = hs.pos
+	hs.token_len = hs.len - hs.pos
+	hs.token_type = TAG_NAME_OPEN
+	hs.state = hs.h5_state_eof
+	return true
+}
+
+/*
+ * 12.2.4.34
+ */
+func (hs *h5_state) h5_state_before_attribute_name() bool {
+	/*
+	 * The C version calls h5_state_self_closing_start_tag() for every "/",
+	 * which calls back here. Loop instead so "/////..." doesn't recurse.
+	 */
+	for {
+		ch := hs.h5_skip_white()
+		switch ch {
+		case CHAR_EOF:
+			return false
+		case CHAR_SLASH:
+			hs.pos += 1
+			if hs.pos < hs.len && hs.s[hs.pos] != CHAR_GT {
+				continue
+			}
+			return hs.h5_state_self_closing_start_tag()
+		case CHAR_GT:
+			hs.state = hs.h5_state_data
+			hs.token_start = hs.pos
+			hs.token_len = 1
+			hs.token_type = TAG_NAME_CLOSE
+			hs.pos += 1
+			return true
+		default:
+			return hs.h5_state_attribute_name()
+		}
+	}
+}
+
+func (hs *h5_state) h5_state_attribute_name() bool {
+	pos := hs.pos + 1
+	for pos < hs.len {
+		ch := hs.s[pos]
+		if h5_is_white(ch) {
+			hs.token_start = hs.pos
+			hs.token_len = pos - hs.pos
+			hs.token_type = ATTR_NAME
+			hs.state = hs.h5_state_after_attribute_name
+			hs.pos = pos + 1
+			return true
+		} else if ch == CHAR_SLASH {
+			hs.token_start = hs.pos
+			hs.token_len = pos - hs.pos
+			hs.token_type = ATTR_NAME
+			hs.state = hs.h5_state_self_closing_start_tag
+			hs.pos = pos + 1
+			return true
+		} else if ch == CHAR_EQUALS {
+			hs.token_start = hs.pos
+			hs.token_len = pos - hs.pos
+			hs.token_type = ATTR_NAME
+			hs.state = hs.h5_state_before_attribute_value
+			hs.pos = pos + 1
+			return true
+		} else if ch == CHAR_GT {
+			hs.token_start = hs.pos
+			hs.token_len = pos - hs.pos
+			hs.token_type = ATTR_NAME
+			hs.state = hs.h5_state_tag_name_close
+			hs.pos = pos
+			return true
+		} else {
+			pos += 1
+		}
+	}
+	/* EOF */
+	hs.token_start = hs.pos
+	hs.token_len = hs.len - hs.pos
+	hs.token_type = ATTR_NAME
+	hs.state = hs.h5_state_eof
+	hs.pos = hs.len
+	return true
+}
+
+/*
+ * 12.2.4.36
+ */
+func (hs *h5_state) h5_state_after_attribute_name() bool {
+	ch := hs.h5_skip_white()
+	switch ch {
+	case CHAR_EOF:
+		return false
+	case CHAR_SLASH:
+		hs.pos += 1
+		return hs.h5_state_self_closing_start_tag()
+	case CHAR_EQUALS:
+		hs.pos += 1
+		return hs.h5_state_before_attribute_value()
+	case CHAR_GT:
+		return hs.h5_state_tag_name_close()
+	default:
+		return hs.h5_state_attribute_name()
+	}
+}
+
+/*
+ * 12.2.4.37
+ */
+func (hs *h5_state) h5_state_before_attribute_value() bool {
+	ch := hs.h5_skip_white()
+
+	if ch == CHAR_EOF {
+		hs.state = hs.h5_state_eof
+		return false
+	}
+
+	if ch == CHAR_DOUBLE {
+		return hs.h5_state_attribute_value_double_quote()
+	} else if ch == CHAR_SINGLE {
+		return hs.h5_state_attribute_value_single_quote()
+	} else if ch == CHAR_TICK {
+		/* NO ONE IS HOME? */
+		return hs.h5_state_attribute_value_back_quote()
+	} else {
+		return hs.h5_state_attribute_value_no_quote()
+	}
+}
+
+func (hs *h5_state) h5_state_attribute_value_quote(qchar byte) bool {
+	/* skip initial quote in normal case.
+	 * don't do this "if (pos == 0)" since it means we have started
+	 * in a non-data state.  given an input of '><foo
+	 * we want to make 0-length attribute name
+	 */
+	if hs.pos > 0 {
+		hs.pos += 1
+	}
+
+	idx := index_byte_from(hs.s[:hs.len], hs.pos, qchar)
+	if idx == -1 {
+		hs.token_start = hs.pos
+		hs.token_len = hs.len - hs.pos
+		hs.token_type = ATTR_VALUE
+		hs.state = hs.h5_state_eof
+	} else {
+		hs.token_start = hs.pos
+		hs.token_len = idx - hs.pos
+		hs.token_type = ATTR_VALUE
+		hs.state = hs.h5_state_after_attribute_value_quoted_state
+		hs.pos += hs.token_len + 1
+	}
+	return true
+}
+
+func (hs *h5_state) h5_state_attribute_value_double_quote() bool {
+	return hs.h5_state_attribute_value_quote(CHAR_DOUBLE)
+}
+
+func (hs *h5_state) h5_state_attribute_value_single_quote() bool {
+	return hs.h5_state_attribute_value_quote(CHAR_SINGLE)
+}
+
+func (hs *h5_state) h5_state_attribute_value_back_quote() bool {
+	return hs.h5_state_attribute_value_quote(CHAR_TICK)
+}
+
+func (hs *h5_state) h5_state_attribute_value_no_quote() bool {
+	pos := hs.pos
+	for pos < hs.len {
+		ch := hs.s[pos]
+		if h5_is_white(ch) {
+			hs.token_type = ATTR_VALUE
+			hs.token_start = hs.pos
+			hs.token_len = pos - hs.pos
+			hs.pos = pos + 1
+			hs.state = hs.h5_state_before_attribute_name
+			return true
+		} else if ch == CHAR_GT {
+			hs.token_type = ATTR_VALUE
+			hs.token_start = hs.pos
+			hs.token_len = pos - hs.pos
+			hs.pos = pos
+			hs.state = hs.h5_state_tag_name_close
+			return true
+		}
+		pos += 1
+	}
+	/* EOF */
+	hs.state = hs.h5_state_eof
+	hs.token_start = hs.pos
+	hs.token_len = hs.len - hs.pos
+	hs.token_type = ATTR_VALUE
+	return true
+}
+
+/*
+ * 12.2.4.41
+ */
+func (hs *h5_state) h5_state_after_attribute_value_quoted_state() bool {
+	if hs.pos >= hs.len {
+		return false
+	}
+	ch := hs.s[hs.pos]
+	if h5_is_white(ch) {
+		hs.pos += 1
+		return hs.h5_state_before_attribute_name()
+	} else if ch == CHAR_SLASH {
+		hs.pos += 1
+		return hs.h5_state_self_closing_start_tag()
+	} else if ch == CHAR_GT {
+		hs.token_start = hs.pos
+		hs.token_len = 1
+		hs.token_type = TAG_NAME_CLOSE
+		hs.pos += 1
+		hs.state = hs.h5_state_data
+		return true
+	} else {
+		return hs.h5_state_before_attribute_name()
+	}
+}
+
+/*
+ * 12.2.4.43
+ */
+func (hs *h5_state) h5_state_self_closing_start_tag() bool {
+	if hs.pos >= hs.len {
+		return false
+	}
+	ch := hs.s[hs.pos]
+	if ch == CHAR_GT {
+		hs.token_start = hs.pos - 1
+		hs.token_len = 2
+		hs.token_type = TAG_NAME_SELFCLOSE
+		hs.state = hs.h5_state_data
+		hs.pos += 1
+		return true
+	}
+	return hs.h5_state_before_attribute_name()
+}
+
+/*
+ * 12.2.4.44
+ */
+func (hs *h5_state) h5_state_bogus_comment() bool {
+	idx := index_byte_from(hs.s[:hs.len], hs.pos, CHAR_GT)
+	if idx == -1 {
+		hs.token_start = hs.pos
+		hs.token_len = hs.len - hs.pos
+		hs.pos = hs.len
+		hs.state = hs.h5_state_eof
+	} else {
+		hs.token_start = hs.pos
+		hs.token_len = idx - hs.pos
+		hs.pos = idx + 1
+		hs.state = hs.h5_state_data
+	}
+
+	hs.token_type = TAG_COMMENT
+	return true
+}
+
+/*
+ * 12.2.4.44 ALT
+ */
+func (hs *h5_state) h5_state_bogus_comment2() bool {
+	pos := hs.pos
+	for {
+		idx := index_byte_from(hs.s[:hs.len], pos, CHAR_PERCENT)
+		if idx == -1 || idx+1 >= hs.len {
+			hs.token_start = hs.pos
+			hs.token_len = hs.len - hs.pos
+			hs.pos = hs.len
+			hs.token_type = TAG_COMMENT
+			hs.state = hs.h5_state_eof
+			return true
+		}
+
+		if hs.s[idx+1] != CHAR_GT {
+			pos = idx + 1
+			continue
+		}
+
+		/* ends in %> */
+		hs.token_start = hs.pos
+		hs.token_len = idx - hs.pos
+		hs.pos = idx + 2
+		hs.state = hs.h5_state_data
+		hs.token_type = TAG_COMMENT
+		return true
+	}
+}
+
+/*
+ * 8.2.4.45
+ */
+func (hs *h5_state) h5_state_markup_declaration_open() bool {
+	remaining := hs.len - hs.pos
+	if remaining >= 7 && strings.EqualFold(hs.s[hs.pos:hs.pos+7], "DOCTYPE") {
+		return hs.h5_state_doctype()
+	} else if remaining >= 7 && hs.s[hs.pos:hs.pos+7] == "[CDATA[" {
+		hs.pos += 7
+		return hs.h5_state_cdata()
+	} else if remaining >= 2 && hs.s[hs.pos:hs.pos+2] == "--" {
+		hs.pos += 2
+		return hs.h5_state_comment()
+	}
+
+	return hs.h5_state_bogus_comment()
+}
+
+/*
+ * 12.2.4.48
+ * 12.2.4.49
+ * 12.2.4.50
+ * 12.2.4.51
+ *   state machine spec is confusing since it can only look
+ *   at one character at a time but simply it's comments end by:
+ *   1) EOF
+ *   2) ending in -->
+ *   3) ending in -!>
+ */
+func (hs *h5_state) h5_state_comment() bool {
+	pos := hs.pos
+	for {
+		idx := index_byte_from(hs.s[:hs.len], pos, CHAR_DASH)
+
+		/* did not find anything or has less than 3 chars left */
+		if idx == -1 || idx > hs.len-3 {
+			hs.state = hs.h5_state_eof
+			hs.token_start = hs.pos
+			hs.token_len = hs.len - hs.pos
+			hs.token_type = TAG_COMMENT
+			return true
+		}
+		offset := 1
+
+		/* skip all nulls */
+		for idx+offset < hs.len && hs.s[idx+offset] == CHAR_NULL {
+			offset += 1
+		}
+		if idx+offset == hs.len {
+			hs.state = hs.h5_state_eof
+			hs.token_start = hs.pos
+			hs.token_len = hs.len - hs.pos
+			hs.token_type = TAG_COMMENT
+			return true
+		}
+
+		ch := hs.s[idx+offset]
+		if ch != CHAR_DASH && ch != CHAR_BANG {
+			pos = idx + 1
+			continue
+		}
+
+		/* need to test */
+		offset += 1
+		if idx+offset == hs.len {
+			hs.state = hs.h5_state_eof
+			hs.token_start = hs.pos
+			hs.token_len = hs.len - hs.pos
+			hs.token_type = TAG_COMMENT
+			return true
+		}
+
+		if hs.s[idx+offset] != CHAR_GT {
+			pos = idx + 1
+			continue
+		}
+		offset += 1
+
+		/* ends in --> or -!> */
+		hs.token_start = hs.pos
+		hs.token_len = idx - hs.pos
+		hs.pos = idx + offset
+		hs.state = hs.h5_state_data
+		hs.token_type = TAG_COMMENT
+		return true
+	}
+}
+
+func (hs *h5_state) h5_state_cdata() bool {
+	pos := hs.pos
+	for {
+		idx := index_byte_from(hs.s[:hs.len], pos, CHAR_RIGHTB)
+
+		/* did not find anything or has less than 3 chars left */
+		if idx == -1 || idx > hs.len-3 {
+			hs.state = hs.h5_state_eof
+			hs.token_start = hs.pos
+			hs.token_len = hs.len - hs.pos
+			hs.token_type = DATA_TEXT
+			return true
+		} else if hs.s[idx+1] == CHAR_RIGHTB && hs.s[idx+2] == CHAR_GT {
+			hs.state = hs.h5_state_data
+			hs.token_start = hs.pos
+			hs.token_len = idx - hs.pos
+			hs.pos = idx + 3
+			hs.token_type = DATA_TEXT
+			return true
+		} else {
+			pos = idx + 1
+		}
+	}
+}
+
+/*
+ * 8.2.4.52
+ * http://www.w3.org/html/wg/drafts/html/master/syntax.html#doctype-state
+ */
+func (hs *h5_state) h5_state_doctype() bool {
+	hs.token_start = hs.pos
+	hs.token_type = DOCTYPE
+
+	idx := index_byte_from(hs.s[:hs.len], hs.pos, CHAR_GT)
+	if idx == -1 {
+		hs.state = hs.h5_state_eof
+		hs.token_len = hs.len - hs.pos
+	} else {
+		hs.state = hs.h5_state_data
+		hs.token_len = idx - hs.pos
+		hs.pos = idx + 1
+	}
+	return true
+}
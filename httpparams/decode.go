@@ -0,0 +1,53 @@
+package httpparams
+
+import "strings"
+
+/*
+ * URLDecode decodes a query string value: %XX escapes and '+' for space.
+ * Broken escapes are kept as is, since attackers send them on purpose.
+ */
+func URLDecode(s string) string {
+	return percentDecode(s, true)
+}
+
+/*
+ * PathDecode decodes a path segment, where '+' is a literal plus.
+ */
+func PathDecode(s string) string {
+	return percentDecode(s, false)
+}
+
+func percentDecode(s string, plus bool) string {
+	if !strings.ContainsAny(s, "%+") {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]):
+			b.WriteByte(unhex(s[i+1])<<4 | unhex(s[i+2]))
+			i += 2
+		case c == '+' && plus:
+			b.WriteByte(' ')
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func unhex(c byte) byte {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0'
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
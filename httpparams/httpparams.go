@@ -0,0 +1,243 @@
+/*
+ * Package httpparams splits an HTTP request into the values worth running
+ * the detectors on: path segments, query and form parameters, headers,
+ * cookies and body fields, each with its location in the request.
+ *
+ * It is shared by the commands that see HTTP traffic, logscan and pcap,
+ * and by the Envoy authorization server of module extauthz.
+ */
+package httpparams
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	libinjection "github.com/jptosso/libinjection-go"
+	"github.com/jptosso/libinjection-go/internal/strutil"
+)
+
+/* multipart fields are only read up to this size */
+const maxPartBytes = 1 << 20
+
+/*
+ * Param is one value taken from a request, decoded.
+ */
+type Param struct {
+	Location string /* e.g. "query:<name>", "query-name", "path:<index>", "header:<name>" */
+	Value    string
+}
+
+/*
+ * Target URL-decodes each path segment and query parameter of a request
+ * target.
+ */
+func Target(target string) []Param {
+	var params []Param
+
+	path, query, _ := strutil.Cut(target, "?")
+	/* fragments are not sent by browsers, but tools do */
+	path, _, _ = strutil.Cut(path, "#")
+	query, _, _ = strutil.Cut(query, "#")
+
+	for i, segment := range strings.Split(strings.TrimPrefix(path, "/"), "/") {
+		if segment == "" {
+			continue
+		}
+		params = append(params, Param{Location: fmt.Sprintf("path:%d", i), Value: PathDecode(segment)})
+	}
+	return append(params, Form(query, "query")...)
+}
+
+/*
+ * PathTarget strips the scheme and host of absolute-form request targets,
+ * as sent to proxies.
+ */
+func PathTarget(uri string) string {
+	if i := strings.Index(uri, "://"); i != -1 && !strings.HasPrefix(uri, "/") {
+		rest := uri[i+3:]
+		if j := strings.IndexByte(rest, '/'); j != -1 {
+			return rest[j:]
+		}
+		return "/"
+	}
+	return uri
+}
+
+/*
+ * Form URL-decodes the names and values of an urlencoded form, such as a
+ * query string. Names are checked too, payloads end up there when an
+ * attacker writes ?<payload> without an "=".
+ */
+func Form(form string, location string) []Param {
+	var params []Param
+	for _, pair := range strings.Split(form, "&") {
+		if pair == "" {
+			continue
+		}
+		name, value, _ := strutil.Cut(pair, "=")
+		name = URLDecode(name)
+		params = append(params, Param{Location: location + "-name", Value: name})
+		if value != "" {
+			params = append(params, Param{Location: location + ":" + name, Value: URLDecode(value)})
+		}
+	}
+	return params
+}
+
+/*
+ * jsonParams collects the keys and string values of a JSON document, with
+ * their path as location.
+ */
+func jsonParams(v interface{}, path string, params []Param) []Param {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			params = append(params, Param{Location: path + "-name", Value: k})
+			params = jsonParams(v[k], path+"."+k, params)
+		}
+	case []interface{}:
+		for i, e := range v {
+			params = jsonParams(e, path+"["+strconv.Itoa(i)+"]", params)
+		}
+	case string:
+		params = append(params, Param{Location: path, Value: v})
+	}
+	return params
+}
+
+/*
+ * Body splits a request body by content type: urlencoded and multipart
+ * forms, JSON, or the whole body when it is text.
+ */
+func Body(contentType string, body []byte) []Param {
+	if len(body) == 0 {
+		return nil
+	}
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		return Form(string(body), "body")
+	case strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "":
+		var out []Param
+		mr := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err != nil {
+				break
+			}
+			name := part.FormName()
+			out = append(out, Param{Location: "body-name", Value: name})
+			if filename := part.FileName(); filename != "" {
+				/* file contents are not checked, their name is */
+				out = append(out, Param{Location: "body-filename:" + name, Value: filename})
+				continue
+			}
+			value, _ := io.ReadAll(io.LimitReader(part, maxPartBytes))
+			out = append(out, Param{Location: "body:" + name, Value: string(value)})
+		}
+		return out
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		var v interface{}
+		if err := json.Unmarshal(body, &v); err == nil {
+			return jsonParams(v, "body", nil)
+		}
+	}
+	if utf8.Valid(body) {
+		return []Param{{Location: "body", Value: string(body)}}
+	}
+	return nil
+}
+
+/*
+ * Headers collects header values, in name order. Cookie headers are split
+ * into their names and URL-decoded values.
+ */
+func Headers(header http.Header) []Param {
+	var params []Param
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range header[name] {
+			if name == "Cookie" {
+				for _, pair := range strings.Split(value, ";") {
+					k, v, _ := strutil.Cut(strings.TrimSpace(pair), "=")
+					params = append(params, Param{Location: "cookie-name", Value: k})
+					params = append(params, Param{Location: "cookie:" + k, Value: URLDecode(v)})
+				}
+				continue
+			}
+			params = append(params, Param{Location: "header:" + name, Value: value})
+		}
+	}
+	return params
+}
+
+/*
+ * Request collects everything to check in a request: path segments, query
+ * parameters, headers, cookies and the body.
+ */
+func Request(req *http.Request, body []byte) []Param {
+	params := Target(PathTarget(req.RequestURI))
+	params = append(params, Headers(req.Header)...)
+	/* net/http moves the Host header out of the map */
+	if req.Host != "" {
+		params = append(params, Param{Location: "header:Host", Value: req.Host})
+	}
+	return append(params, Body(req.Header.Get("Content-Type"), body)...)
+}
+
+/*
+ * Detectors selects the detectors Detect runs.
+ */
+type Detectors struct {
+	SQLi bool
+	XSS  bool
+	CRLF bool /* header injection and response splitting */
+	XXE  bool /* external entities in XML bodies */
+}
+
+/*
+ * Detect runs the enabled detectors on value, SQLi first. It returns the
+ * type of the first hit, "" if none, and its fingerprint: the SQLi
+ * fingerprint, the header CRLF injects or what XXE found, none for XSS.
+ */
+func Detect(value string, detectors Detectors) (string, string) {
+	if detectors.SQLi {
+		if result := libinjection.DetectSQLi(value); result.Injection {
+			return "sqli", result.Fingerprint
+		}
+	}
+	/* before XSS, which flags most XML documents too */
+	if detectors.XXE {
+		if result := libinjection.DetectXXE(value); result.Injection {
+			return "xxe", result.Fingerprint
+		}
+	}
+	if detectors.XSS && libinjection.IsXSS(value) {
+		return "xss", ""
+	}
+	if detectors.CRLF {
+		if result := libinjection.DetectCRLF(value); result.Injection {
+			return "crlf", result.Fingerprint
+		}
+	}
+	return "", ""
+}
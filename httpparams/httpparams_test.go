@@ -0,0 +1,81 @@
+package httpparams
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestTarget(t *testing.T) {
+	params := Target("/a/b%2Fc/?x=1+2&flag&y=%3C")
+	expected := []Param{
+		{"path:0", "a"},
+		{"path:1", "b/c"},
+		{"query-name", "x"},
+		{"query:x", "1 2"},
+		{"query-name", "flag"},
+		{"query-name", "y"},
+		{"query:y", "<"},
+	}
+	if !reflect.DeepEqual(params, expected) {
+		t.Errorf("expected %v, got %v", expected, params)
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	header := http.Header{
+		"User-Agent": {"curl"},
+		"Cookie":     {"a=1%27; b=x"},
+	}
+	expected := []Param{
+		{"cookie-name", "a"},
+		{"cookie:a", "1'"},
+		{"cookie-name", "b"},
+		{"cookie:b", "x"},
+		{"header:User-Agent", "curl"},
+	}
+	if params := Headers(header); !reflect.DeepEqual(params, expected) {
+		t.Errorf("expected %v, got %v", expected, params)
+	}
+}
+
+func TestBody(t *testing.T) {
+	params := Body("application/json", []byte(`{"q": ["x", {"id": "1' or '1'='1"}]}`))
+	expected := []Param{
+		{"body-name", "q"},
+		{"body.q[0]", "x"},
+		{"body.q[1]-name", "id"},
+		{"body.q[1].id", "1' or '1'='1"},
+	}
+	if !reflect.DeepEqual(params, expected) {
+		t.Errorf("expected %v, got %v", expected, params)
+	}
+	if params := Body("application/octet-stream", []byte{0xff, 0xfe}); params != nil {
+		t.Errorf("binary body: expected nothing, got %v", params)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	all := Detectors{SQLi: true, XSS: true, CRLF: true, XXE: true}
+	tests := []struct {
+		value       string
+		detectors   Detectors
+		typ         string
+		fingerprint string
+	}{
+		{"1' or '1'='1", all, "sqli", "s&sos"},
+		{"<script>alert(1)</script>", all, "xss", ""},
+		{"/\r\nLocation: http://evil", all, "crlf", "location"},
+		{"/\r\nLocation: http://evil", Detectors{SQLi: true, XSS: true}, "", ""},
+		{`<!DOCTYPE a [<!ENTITY b SYSTEM "file:///etc/passwd">]><a>&b;</a>`, all, "xxe", "entity"},
+		{`<!DOCTYPE a [<!ENTITY b SYSTEM "file:///etc/passwd">]><a>&b;</a>`, Detectors{SQLi: true, CRLF: true}, "", ""},
+		{"1' or '1'='1", Detectors{XSS: true}, "", ""},
+		{"shoes", all, "", ""},
+	}
+	for _, test := range tests {
+		typ, fingerprint := Detect(test.value, test.detectors)
+		if typ != test.typ || fingerprint != test.fingerprint {
+			t.Errorf("%q: expected %q %q, got %q %q", test.value, test.typ, test.fingerprint, typ, fingerprint)
+		}
+	}
+}
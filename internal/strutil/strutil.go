@@ -0,0 +1,18 @@
+/*
+ * Package strutil has the string helpers of newer Go versions the module
+ * needs, as it targets go1.16.
+ */
+package strutil
+
+import "strings"
+
+/*
+ * Cut is strings.Cut, which needs go1.18: it splits s around the first
+ * sep.
+ */
+func Cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
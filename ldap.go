@@ -0,0 +1,201 @@
+package libinjection
+
+import (
+	"strings"
+)
+
+/*
+ * LDAP filter injection, RFC 4515. The input is assumed to be an
+ * assertion value, e.g. the name in (&(uid=NAME)(userPassword=...)). It is
+ * an injection when it closes that item and adds filters of its own, as
+ * in "*)(uid=*))(|(uid=*", which turns the filter into one that matches
+ * every user.
+ *
+ * Like SQLi, the input is tokenized, the tokens are folded into a
+ * fingerprint of at most LDAP_MAX_TOKENS and the fingerprint is looked up
+ * in a table. Values have no quotes, so there is a single pass.
+ */
+
+const (
+	LDAP_MAX_TOKENS = 5
+
+	//ldap token types
+	LDAP_TYPE_OPEN      = '('
+	LDAP_TYPE_CLOSE     = ')'
+	LDAP_TYPE_AND       = '&'
+	LDAP_TYPE_OR        = '|'
+	LDAP_TYPE_NOT       = '!'
+	LDAP_TYPE_ITEM      = 'i' /* (attr=value), (attr>=value), (attr:dn:=value), ... */
+	LDAP_TYPE_NUL       = 'n' /* NUL byte, ends the filter in C libraries */
+	LDAP_TYPE_MALFORMED = 'x' /* the server rejects the filter */
+)
+
+type ldapState struct {
+	s      string
+	pos    int
+	tokens []byte
+}
+
+/*
+ * isLDAPAttr tells if c may be in an attribute description: a name or an
+ * OID, with options, e.g. "cn;lang-en" or "2.5.4.3".
+ */
+func isLDAPAttr(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '-' || c == '.' || c == ';'
+}
+
+func isHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+/*
+ * value skips an assertion value, up to the ')' that ends it. It returns
+ * false if the value has an unescaped '(' or a bad escape, which the
+ * server rejects. '*' is fine, it makes a presence or substring filter.
+ */
+func (st *ldapState) value() bool {
+	for st.pos < len(st.s) {
+		switch st.s[st.pos] {
+		case ')', CHAR_NULL:
+			return true
+		case '(':
+			return false
+		case '\\':
+			if st.pos+2 >= len(st.s) || !isHex(st.s[st.pos+1]) || !isHex(st.s[st.pos+2]) {
+				return false
+			}
+			st.pos += 3
+		default:
+			st.pos++
+		}
+	}
+	return true
+}
+
+/*
+ * filterType skips the filter type of an item: "=", "~=", ">=", "<=", or
+ * the ":dn:rule:=" of an extensible match. It returns false if there is
+ * none.
+ */
+func (st *ldapState) filterType(attr bool) bool {
+	s := st.s[st.pos:]
+	switch {
+	case strings.HasPrefix(s, "="):
+		st.pos++
+		return attr
+	case strings.HasPrefix(s, "~="), strings.HasPrefix(s, ">="), strings.HasPrefix(s, "<="):
+		st.pos += 2
+		return attr
+	case strings.HasPrefix(s, ":"):
+		/* extensible match, the attribute is optional */
+		for st.pos < len(st.s) && st.s[st.pos] == ':' {
+			if st.pos+1 < len(st.s) && st.s[st.pos+1] == '=' {
+				st.pos += 2
+				return true
+			}
+			st.pos++
+			start := st.pos
+			for st.pos < len(st.s) && isLDAPAttr(st.s[st.pos]) {
+				st.pos++
+			}
+			if st.pos == start {
+				return false
+			}
+		}
+	}
+	return false
+}
+
+/*
+ * item reads a simple, presence, substring or extensible filter after
+ * its '('. The ')' is part of the item, an item cut short by the end of
+ * the input is closed by the rest of the filter.
+ */
+func (st *ldapState) item() byte {
+	start := st.pos
+	for st.pos < len(st.s) && isLDAPAttr(st.s[st.pos]) {
+		st.pos++
+	}
+	if !st.filterType(st.pos > start) || !st.value() {
+		return LDAP_TYPE_MALFORMED
+	}
+	if st.pos < len(st.s) && st.s[st.pos] == ')' {
+		st.pos++
+	}
+	return LDAP_TYPE_ITEM
+}
+
+/*
+ * tokenize reads the value the input starts in, then the filter
+ * structure after it. It stops at the first malformed token or NUL byte.
+ */
+func (st *ldapState) tokenize() {
+	if !st.value() {
+		st.tokens = append(st.tokens, LDAP_TYPE_MALFORMED)
+		return
+	}
+	/* the input was a plain value */
+	for st.pos < len(st.s) {
+		c := st.s[st.pos]
+		switch {
+		case c == CHAR_NULL:
+			st.tokens = append(st.tokens, LDAP_TYPE_NUL)
+			return
+		case c == ')':
+			st.tokens = append(st.tokens, LDAP_TYPE_CLOSE)
+			st.pos++
+		case c == '(':
+			st.pos++
+			if st.pos < len(st.s) && strings.IndexByte("&|!", st.s[st.pos]) != -1 {
+				st.tokens = append(st.tokens, LDAP_TYPE_OPEN, st.s[st.pos])
+				st.pos++
+				continue
+			}
+			typ := st.item()
+			st.tokens = append(st.tokens, typ)
+			if typ == LDAP_TYPE_MALFORMED {
+				return
+			}
+		default:
+			/* text after a ')', or a filter of "(&" not in parentheses */
+			st.tokens = append(st.tokens, LDAP_TYPE_MALFORMED)
+			return
+		}
+	}
+}
+
+/*
+ * ldapFingerprint returns the fingerprint of input as an assertion value:
+ * the structure it adds, e.g. ")i)(|" for "*)(uid=*))(|(uid=*", cut at
+ * LDAP_MAX_TOKENS. A plain value has an empty fingerprint.
+ */
+func ldapFingerprint(input string) string {
+	st := &ldapState{s: input}
+	st.tokenize()
+	if len(st.tokens) > LDAP_MAX_TOKENS {
+		st.tokens = st.tokens[:LDAP_MAX_TOKENS]
+	}
+	return string(st.tokens)
+}
+
+/*
+ * DetectLDAP checks input, an assertion value of an LDAP search filter,
+ * for filter injection.
+ */
+func DetectLDAP(input string) Result {
+	fingerprint := ldapFingerprint(input)
+	return Result{Injection: ldapFingerprints[fingerprint], Fingerprint: fingerprint}
+}
+
+/*
+ * IsLDAP tells if input is an LDAP filter injection, with its
+ * fingerprint.
+ */
+func IsLDAP(input string) (bool, string) {
+	result := DetectLDAP(input)
+	if !result.Injection {
+		return false, ""
+	}
+	return true, result.Fingerprint
+}
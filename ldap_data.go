@@ -0,0 +1,139 @@
+package libinjection
+
+/*
+ * Fingerprints of LDAP filter injections: the value is closed, then
+ * followed by filters, composite filters or a NUL byte, in an order the
+ * filter grammar allows.
+ */
+var ldapFingerprints = map[string]bool{
+	")(":    true,
+	")(!":   true,
+	")(!(":  true,
+	")(!(!": true,
+	")(!(&": true,
+	")(!(|": true,
+	")(!i":  true,
+	")(!i(": true,
+	")(!i)": true,
+	")(!ii": true,
+	")(!in": true,
+	")(&":   true,
+	")(&(":  true,
+	")(&(!": true,
+	")(&(&": true,
+	")(&(|": true,
+	")(&)":  true,
+	")(&)(": true,
+	")(&))": true,
+	")(&)i": true,
+	")(&)n": true,
+	")(&i":  true,
+	")(&i(": true,
+	")(&i)": true,
+	")(&ii": true,
+	")(&in": true,
+	")(|":   true,
+	")(|(":  true,
+	")(|(!": true,
+	")(|(&": true,
+	")(|(|": true,
+	")(|)":  true,
+	")(|)(": true,
+	")(|))": true,
+	")(|)i": true,
+	")(|)n": true,
+	")(|i":  true,
+	")(|i(": true,
+	")(|i)": true,
+	")(|ii": true,
+	")(|in": true,
+	"))(":   true,
+	"))(!":  true,
+	"))(!(": true,
+	"))(!i": true,
+	"))(&":  true,
+	"))(&(": true,
+	"))(&)": true,
+	"))(&i": true,
+	"))(|":  true,
+	"))(|(": true,
+	"))(|)": true,
+	"))(|i": true,
+	")))(":  true,
+	")))(!": true,
+	")))(&": true,
+	")))(|": true,
+	"))))(": true,
+	"))))i": true,
+	"))))n": true,
+	")))i":  true,
+	")))i(": true,
+	")))i)": true,
+	")))ii": true,
+	")))in": true,
+	")))n":  true,
+	"))i":   true,
+	"))i(":  true,
+	"))i(!": true,
+	"))i(&": true,
+	"))i(|": true,
+	"))i)":  true,
+	"))i)(": true,
+	"))i))": true,
+	"))i)i": true,
+	"))i)n": true,
+	"))ii":  true,
+	"))ii(": true,
+	"))ii)": true,
+	"))iii": true,
+	"))iin": true,
+	"))in":  true,
+	"))n":   true,
+	")i":    true,
+	")i(":   true,
+	")i(!":  true,
+	")i(!(": true,
+	")i(!i": true,
+	")i(&":  true,
+	")i(&(": true,
+	")i(&)": true,
+	")i(&i": true,
+	")i(|":  true,
+	")i(|(": true,
+	")i(|)": true,
+	")i(|i": true,
+	")i)":   true,
+	")i)(":  true,
+	")i)(!": true,
+	")i)(&": true,
+	")i)(|": true,
+	")i))":  true,
+	")i))(": true,
+	")i)))": true,
+	")i))i": true,
+	")i))n": true,
+	")i)i":  true,
+	")i)i(": true,
+	")i)i)": true,
+	")i)ii": true,
+	")i)in": true,
+	")i)n":  true,
+	")ii":   true,
+	")ii(":  true,
+	")ii(!": true,
+	")ii(&": true,
+	")ii(|": true,
+	")ii)":  true,
+	")ii)(": true,
+	")ii))": true,
+	")ii)i": true,
+	")ii)n": true,
+	")iii":  true,
+	")iii(": true,
+	")iii)": true,
+	")iiii": true,
+	")iiin": true,
+	")iin":  true,
+	")in":   true,
+	")n":    true,
+}
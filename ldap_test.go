@@ -0,0 +1,43 @@
+package libinjection
+
+import "testing"
+
+func TestDetectLDAP(t *testing.T) {
+	tests := []struct {
+		input       string
+		injection   bool
+		fingerprint string
+	}{
+		{"*)(uid=*))(|(uid=*", true, ")i)(|"},
+		{"*)(objectClass=*", true, ")i"},
+		{"admin)(&)", true, ")(&)"},
+		{"admin)(|(password=*)", true, ")(|i"},
+		{"admin))(|(cn=*", true, "))(|i"},
+		{"x)(!(cn=a)", true, ")(!i"},
+		{"x)(cn>=a)(sn~=b\\2a", true, ")ii"},
+		{"x)(:dn:2.5.13.5:=y", true, ")i"},
+		{"admin)\x00", true, ")n"},
+		/* values */
+		{"", false, ""},
+		{"*", false, ""},
+		{"John Smith", false, ""},
+		{"Sm\\2ath", false, ""},
+		{"smith*", false, ""},
+		{"John (Jack) Smith", false, "x"},
+		{"Smith)", false, ")"},
+		{"Smith) and more", false, ")x"},
+		{"a)(b)", false, ")x"},
+		{"a)(=b", false, ")x"},
+		{"bad\\zz", false, "x"},
+	}
+	for _, test := range tests {
+		result := DetectLDAP(test.input)
+		if result.Injection != test.injection || result.Fingerprint != test.fingerprint {
+			t.Errorf("%q: expected %v %q, got %+v", test.input, test.injection, test.fingerprint, result)
+		}
+		is, fingerprint := IsLDAP(test.input)
+		if is != test.injection || is && fingerprint != test.fingerprint {
+			t.Errorf("%q: IsLDAP gives %v %q", test.input, is, fingerprint)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package mutate
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+/*
+ * WriteBaseline writes the variant and miss counts of s, the totals then
+ * one line per transform, tab separated, for ReadBaseline:
+ *
+ *	# transform	variants	missed
+ *	total	2144	148
+ *	case:flip	310	20
+ *
+ * Checked in next to the corpus it was computed on, a baseline records
+ * the bypass rate of a release, and Regressions tells what got worse.
+ */
+func (s *Summary) WriteBaseline(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# transform\tvariants\tmissed\n")
+	fmt.Fprintf(bw, "total\t%d\t%d\n", s.Variants, s.Missed)
+	for _, st := range s.ByTransform {
+		fmt.Fprintf(bw, "%s\t%d\t%d\n", st.Transform, st.Variants, st.Missed)
+	}
+	return bw.Flush()
+}
+
+/*
+ * ReadBaseline reads what WriteBaseline wrote. Payloads and Undetected
+ * are not part of a baseline and are left empty.
+ */
+func ReadBaseline(r io.Reader) (Summary, error) {
+	s := Summary{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if text == "" || text[0] == '#' {
+			continue
+		}
+		fields := strings.Split(text, "\t")
+		if len(fields) != 3 {
+			return s, fmt.Errorf("baseline:%d: expected transform<TAB>variants<TAB>missed", line)
+		}
+		variants, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return s, fmt.Errorf("baseline:%d: %v", line, err)
+		}
+		missed, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return s, fmt.Errorf("baseline:%d: %v", line, err)
+		}
+		if fields[0] == "total" {
+			s.Variants, s.Missed = variants, missed
+		} else {
+			s.ByTransform = append(s.ByTransform, TransformStats{Transform: fields[0], Variants: variants, Missed: missed})
+		}
+	}
+	return s, scanner.Err()
+}
+
+/*
+ * Regressions describes the bypass rates of s higher than in baseline,
+ * the total first. Transforms the baseline doesn't know are compared to
+ * a rate of 0.
+ */
+func (s *Summary) Regressions(baseline *Summary) []string {
+	var regressions []string
+	worse := func(name string, st, base TransformStats) {
+		/* st.Missed/st.Variants > base.Missed/base.Variants */
+		if st.Missed*base.Variants > base.Missed*st.Variants || (base.Variants == 0 && st.Missed != 0) {
+			regressions = append(regressions, fmt.Sprintf("%s: %d/%d missed, was %d/%d",
+				name, st.Missed, st.Variants, base.Missed, base.Variants))
+		}
+	}
+
+	worse("total", TransformStats{Variants: s.Variants, Missed: s.Missed},
+		TransformStats{Variants: baseline.Variants, Missed: baseline.Missed})
+	known := map[string]TransformStats{}
+	for _, st := range baseline.ByTransform {
+		known[st.Transform] = st
+	}
+	for _, st := range s.ByTransform {
+		worse(st.Transform, st, known[st.Transform])
+	}
+	return regressions
+}
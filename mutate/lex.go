@@ -0,0 +1,168 @@
+package mutate
+
+import "strings"
+
+/*
+ * Kinds of segments. The lexer is much simpler than the tokenizer of
+ * libinjection: it only needs to know what can be rewritten without
+ * changing the meaning of the payload.
+ */
+const (
+	segWhite    = iota
+	segWord     /* keyword, function or identifier */
+	segNumber   /* decimal integer */
+	segString   /* complete quoted string */
+	segFragment /* unbalanced string, continuing or opened outside the payload */
+	segComment
+	segOther /* operators, punctuation, anything else kept as is */
+)
+
+type segment struct {
+	kind int
+	text string
+}
+
+func isWordStart(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_' || c == '@' || c == '$' || c >= 0x80 && c != 0xa0
+}
+
+func isWordChar(c byte) bool {
+	return isWordStart(c) || (c >= '0' && c <= '9')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+/*
+ * isWhite is char_is_white of the tokenizer.
+ */
+func isWhite(c byte) bool {
+	return strings.IndexByte(whitespace, c) != -1
+}
+
+/*
+ * stringEnd returns the index just past the quote closing the string
+ * whose content starts at i, or -1. Quotes are escaped by doubling them
+ * or with a backslash.
+ */
+func stringEnd(s string, i int, quote byte) int {
+	for ; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case quote:
+			if i+1 < len(s) && s[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return -1
+}
+
+/*
+ * openQuote tells if payload starts inside a string, as in 1' OR '1'='1
+ * where the application supplies the quotes around it. It guesses so when
+ * no whitespace comes before the first quote, and no word character right
+ * after it: admin'--, ' AND 1=1 but not 'abc' OR 1=1.
+ */
+func openQuote(payload string) byte {
+	i := strings.IndexAny(payload, "'\"")
+	if i == -1 {
+		return 0
+	}
+	for j := 0; j < i; j++ {
+		if isWhite(payload[j]) {
+			return 0
+		}
+	}
+	if i+1 < len(payload) && isWordChar(payload[i+1]) {
+		return 0
+	}
+	return payload[i]
+}
+
+/*
+ * lex splits payload into segments. Concatenating the text of the
+ * segments gives payload back.
+ */
+func lex(payload string) []segment {
+	var segs []segment
+	i := 0
+
+	if quote := openQuote(payload); quote != 0 {
+		end := stringEnd(payload, 0, quote)
+		if end == -1 {
+			end = len(payload)
+		}
+		segs = append(segs, segment{segFragment, payload[:end]})
+		i = end
+	}
+
+	for i < len(payload) {
+		c := payload[i]
+		start := i
+		kind := segOther
+
+		switch {
+		case isWhite(c):
+			kind = segWhite
+			for i < len(payload) && isWhite(payload[i]) {
+				i++
+			}
+		case c == '\'' || c == '"':
+			kind = segString
+			i = stringEnd(payload, i+1, c)
+			if i == -1 {
+				kind, i = segFragment, len(payload)
+			}
+		case c == '/' && strings.HasPrefix(payload[i:], "/*"):
+			kind = segComment
+			if end := strings.Index(payload[i+2:], "*/"); end != -1 {
+				i += end + 4
+			} else {
+				i = len(payload)
+			}
+		case c == '#' || (c == '-' && strings.HasPrefix(payload[i:], "--")):
+			kind = segComment
+			if end := strings.IndexByte(payload[i:], '\n'); end != -1 {
+				i += end + 1
+			} else {
+				i = len(payload)
+			}
+		case isDigit(c):
+			kind = segNumber
+			for i < len(payload) && isDigit(payload[i]) {
+				i++
+			}
+			/* 0x1f, 1.5, 1e3, 1abc: not a plain integer, kept as is */
+			if i < len(payload) && (isWordChar(payload[i]) || payload[i] == '.') {
+				kind = segOther
+				for i < len(payload) && (isWordChar(payload[i]) || payload[i] == '.') {
+					i++
+				}
+			}
+		case isWordStart(c):
+			kind = segWord
+			for i < len(payload) && isWordChar(payload[i]) {
+				i++
+			}
+		case strings.HasPrefix(payload[i:], "||") || strings.HasPrefix(payload[i:], "&&"):
+			i += 2
+		default:
+			i++
+		}
+		segs = append(segs, segment{kind, payload[start:i]})
+	}
+	return segs
+}
+
+func join(segs []segment) string {
+	var b strings.Builder
+	for _, s := range segs {
+		b.WriteString(s.text)
+	}
+	return b.String()
+}
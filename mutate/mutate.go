@@ -0,0 +1,372 @@
+/*
+ * Package mutate generates evasion variants of known SQLi payloads, to
+ * measure how many the detector misses and track that bypass rate from
+ * one release to the next.
+ *
+ * Variants are meant to be semantically equivalent to the payload, at
+ * least on MySQL, which accepts the most syntax: comments instead of
+ * spaces, flipped case, every byte the tokenizer takes for whitespace,
+ * || and && for OR and AND, and numbers and strings written differently.
+ * Each transform is applied alone, then paired with the transforms of the
+ * other families.
+ *
+ *	report := mutate.Check("1' OR '1'='1", mutate.SQLi)
+ *	for _, v := range report.Missed {
+ *		fmt.Println(v.Transform, v.Payload)
+ *	}
+ */
+package mutate
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	libinjection "github.com/jptosso/libinjection-go"
+)
+
+/*
+ * The bytes char_is_white of the tokenizer accepts: space, tab, new line,
+ * vertical tab, form feed, carriage return, NUL (oracle) and Latin-1
+ * non-breaking space.
+ */
+const whitespace = " \t\n\v\f\r\x00\xa0"
+
+/*
+ * Transform rewrites one segment of a payload, returning it unchanged
+ * when it doesn't apply.
+ */
+type Transform struct {
+	Name   string /* e.g. "white:0x0b", "case:flip", "num:hex" */
+	Family string /* transforms of the same family are not combined */
+	apply  func(segment) segment
+}
+
+/*
+ * Variant is a payload rewritten by one or two transforms.
+ */
+type Variant struct {
+	Transform string /* names joined with "+" */
+	Payload   string
+}
+
+/*
+ * Transforms lists every transform, by family.
+ */
+var Transforms = transforms()
+
+func transforms() []Transform {
+	var ts []Transform
+
+	for _, comment := range []string{"/**/", "--\n", "#\n"} {
+		comment := comment
+		ts = append(ts, Transform{
+			Name:   "comment:" + strings.ReplaceAll(comment, "\n", `\n`),
+			Family: "space",
+			apply: func(s segment) segment {
+				if s.kind == segWhite {
+					return segment{segComment, comment}
+				}
+				return s
+			},
+		})
+	}
+
+	for i := 0; i < len(whitespace); i++ {
+		white := whitespace[i : i+1]
+		ts = append(ts, Transform{
+			Name:   fmt.Sprintf("white:0x%02x", white[0]),
+			Family: "space",
+			apply: func(s segment) segment {
+				if s.kind == segWhite {
+					return segment{segWhite, white}
+				}
+				return s
+			},
+		})
+	}
+
+	ts = append(ts,
+		Transform{Name: "case:upper", Family: "case", apply: wordFunc(strings.ToUpper)},
+		Transform{Name: "case:lower", Family: "case", apply: wordFunc(strings.ToLower)},
+		Transform{Name: "case:flip", Family: "case", apply: wordFunc(flipCase)},
+		Transform{Name: "op:symbol", Family: "op", apply: replaceOp(map[string]string{"OR": "||", "AND": "&&"})},
+		Transform{Name: "op:word", Family: "op", apply: replaceOp(map[string]string{"||": "OR", "&&": "AND"})},
+		Transform{Name: "num:hex", Family: "num", apply: numberFunc(func(n uint64) string {
+			return "0x" + strconv.FormatUint(n, 16)
+		})},
+		Transform{Name: "num:exp", Family: "num", apply: numberFunc(func(n uint64) string {
+			return strconv.FormatUint(n, 10) + "e0"
+		})},
+		Transform{Name: "str:hex", Family: "str", apply: stringFunc(func(v string) string {
+			return fmt.Sprintf("0x%x", v)
+		})},
+		Transform{Name: "str:char", Family: "str", apply: stringFunc(func(v string) string {
+			codes := make([]string, len(v))
+			for i := 0; i < len(v); i++ {
+				codes[i] = strconv.Itoa(int(v[i]))
+			}
+			return "CHAR(" + strings.Join(codes, ",") + ")"
+		})},
+		Transform{Name: "str:concat", Family: "str", apply: stringFunc(func(v string) string {
+			if len(v) < 2 {
+				return ""
+			}
+			/* adjacent literals are concatenated */
+			return "'" + v[:len(v)/2] + "' '" + v[len(v)/2:] + "'"
+		})},
+	)
+	return ts
+}
+
+func flipCase(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		switch {
+		case i%2 == 0 && c >= 'a' && c <= 'z':
+			b[i] = c - 'a' + 'A'
+		case i%2 == 1 && c >= 'A' && c <= 'Z':
+			b[i] = c - 'A' + 'a'
+		}
+	}
+	return string(b)
+}
+
+func wordFunc(f func(string) string) func(segment) segment {
+	return func(s segment) segment {
+		if s.kind == segWord {
+			s.text = f(s.text)
+		}
+		return s
+	}
+}
+
+func replaceOp(ops map[string]string) func(segment) segment {
+	return func(s segment) segment {
+		if s.kind != segWord && s.kind != segOther {
+			return s
+		}
+		if op, ok := ops[strings.ToUpper(s.text)]; ok {
+			return segment{segOther, op}
+		}
+		return s
+	}
+}
+
+func numberFunc(f func(uint64) string) func(segment) segment {
+	return func(s segment) segment {
+		if s.kind != segNumber {
+			return s
+		}
+		n, err := strconv.ParseUint(s.text, 10, 64)
+		if err != nil {
+			return s
+		}
+		return segment{segOther, f(n)}
+	}
+}
+
+/*
+ * stringFunc rewrites the value of single quoted strings without escapes.
+ * f returns "" when it can't.
+ */
+func stringFunc(f func(string) string) func(segment) segment {
+	return func(s segment) segment {
+		if s.kind != segString || s.text[0] != '\'' {
+			return s
+		}
+		v := s.text[1 : len(s.text)-1]
+		if v == "" || strings.ContainsAny(v, "'\\") {
+			return s
+		}
+		if text := f(v); text != "" {
+			return segment{segOther, text}
+		}
+		return s
+	}
+}
+
+/*
+ * apply rewrites segs with ts. A rewritten segment glued to a word on
+ * either side, as OR replacing the || of 1||2, gets a space there so it
+ * doesn't merge into it; one already separated by whitespace gets none.
+ */
+func apply(segs []segment, ts ...Transform) string {
+	out := make([]segment, 0, len(segs))
+	rewritten := false /* the last segment of out */
+	for i, s := range segs {
+		for _, t := range ts {
+			s = t.apply(s)
+		}
+		changed := s.text != segs[i].text
+		if (changed || rewritten) && len(out) != 0 && glued(out[len(out)-1].text, s.text) {
+			out = append(out, segment{segWhite, " "})
+		}
+		out = append(out, s)
+		rewritten = changed
+	}
+	return join(out)
+}
+
+/*
+ * glued tells if a followed by b would read as a single word.
+ */
+func glued(a, b string) bool {
+	return a != "" && b != "" && isWordChar(a[len(a)-1]) && isWordChar(b[0])
+}
+
+/*
+ * Variants returns the distinct variants of payload: every transform that
+ * changes it, and every pair of those from different families.
+ */
+func Variants(payload string) []Variant {
+	segs := lex(payload)
+	seen := map[string]bool{payload: true}
+	var variants []Variant
+
+	var changing []Transform
+	for _, t := range Transforms {
+		v := apply(segs, t)
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+		changing = append(changing, t)
+		variants = append(variants, Variant{Transform: t.Name, Payload: v})
+	}
+
+	for i, a := range changing {
+		for _, b := range changing[i+1:] {
+			if a.Family == b.Family {
+				continue
+			}
+			v := apply(segs, a, b)
+			if seen[v] {
+				continue
+			}
+			seen[v] = true
+			variants = append(variants, Variant{Transform: a.Name + "+" + b.Name, Payload: v})
+		}
+	}
+	return variants
+}
+
+/*
+ * Detector tells if input is an injection.
+ */
+type Detector func(input string) bool
+
+/*
+ * SQLi is the Detector of libinjection.IsSQLi.
+ */
+func SQLi(input string) bool {
+	issqli, _ := libinjection.IsSQLi(input)
+	return issqli
+}
+
+/*
+ * Report is the outcome of checking the variants of one payload.
+ */
+type Report struct {
+	Payload  string
+	Detected bool /* the payload itself */
+	Variants []Variant
+	Missed   []Variant
+}
+
+/*
+ * BypassRate is the fraction of variants missed, 0 with no variants.
+ */
+func (r *Report) BypassRate() float64 {
+	if len(r.Variants) == 0 {
+		return 0
+	}
+	return float64(len(r.Missed)) / float64(len(r.Variants))
+}
+
+/*
+ * Check runs detect on payload and all its variants.
+ */
+func Check(payload string, detect Detector) Report {
+	r := Report{Payload: payload, Detected: detect(payload), Variants: Variants(payload)}
+	for _, v := range r.Variants {
+		if !detect(v.Payload) {
+			r.Missed = append(r.Missed, v)
+		}
+	}
+	return r
+}
+
+/*
+ * TransformStats counts the variants a transform took part in, and how
+ * many of them were missed.
+ */
+type TransformStats struct {
+	Transform string
+	Variants  int
+	Missed    int
+}
+
+/*
+ * Summary aggregates the reports of a corpus of payloads.
+ */
+type Summary struct {
+	Payloads    int
+	Undetected  []string /* payloads missed before any mutation */
+	Variants    int
+	Missed      int
+	ByTransform []TransformStats /* sorted by name */
+}
+
+/*
+ * BypassRate is the fraction of all variants missed.
+ */
+func (s *Summary) BypassRate() float64 {
+	if s.Variants == 0 {
+		return 0
+	}
+	return float64(s.Missed) / float64(s.Variants)
+}
+
+/*
+ * Run checks every payload of a corpus. Payloads the detector misses as
+ * they are would make any variant a bypass, their variants are not
+ * counted.
+ */
+func Run(payloads []string, detect Detector) Summary {
+	s := Summary{}
+	stats := map[string]*TransformStats{}
+	for _, payload := range payloads {
+		s.Payloads++
+		r := Check(payload, detect)
+		if !r.Detected {
+			s.Undetected = append(s.Undetected, payload)
+			continue
+		}
+		missed := map[string]bool{}
+		for _, v := range r.Missed {
+			missed[v.Payload] = true
+		}
+		s.Variants += len(r.Variants)
+		s.Missed += len(r.Missed)
+		for _, v := range r.Variants {
+			for _, name := range strings.Split(v.Transform, "+") {
+				st := stats[name]
+				if st == nil {
+					st = &TransformStats{Transform: name}
+					stats[name] = st
+				}
+				st.Variants++
+				if missed[v.Payload] {
+					st.Missed++
+				}
+			}
+		}
+	}
+	for _, st := range stats {
+		s.ByTransform = append(s.ByTransform, *st)
+	}
+	sort.Slice(s.ByTransform, func(i, j int) bool { return s.ByTransform[i].Transform < s.ByTransform[j].Transform })
+	return s
+}
@@ -0,0 +1,179 @@
+package mutate
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	libinjection "github.com/jptosso/libinjection-go"
+)
+
+/* classic payloads, all detected as they are */
+var corpus = []string{
+	"1' OR '1'='1",
+	"1 OR 1=1",
+	"admin'--",
+	"1 UNION SELECT username, password FROM users",
+	"1' UNION SELECT NULL, version()#",
+	"1; DROP TABLE users",
+	"' AND 1=CONVERT(int,@@version)--",
+	"1 AND SLEEP(5)",
+	"1' AND 'a'='a",
+	"-1 OR 2>1 AND name='admin'",
+}
+
+var update = flag.Bool("update", false, "rewrite testdata/baseline.tsv")
+
+func TestWhitespace(t *testing.T) {
+	for i := 0; i < len(whitespace); i++ {
+		input := "1" + whitespace[i:i+1] + "OR" + whitespace[i:i+1] + "1"
+		if tokens := libinjection.Tokenize(input, 0); len(tokens) != 3 {
+			t.Errorf("0x%02x is not whitespace for the tokenizer: %d tokens", whitespace[i], len(tokens))
+		}
+	}
+}
+
+func TestLex(t *testing.T) {
+	segs := lex("1' OR '1'='1")
+	kinds := []int{segFragment, segWhite, segWord, segWhite, segString, segOther, segFragment}
+	if len(segs) != len(kinds) {
+		t.Fatalf("expected %d segments, got %v", len(kinds), segs)
+	}
+	for i, s := range segs {
+		if s.kind != kinds[i] {
+			t.Errorf("%d %q: expected kind %d, got %d", i, s.text, kinds[i], s.kind)
+		}
+	}
+
+	for _, payload := range append(corpus, "'abc' OR 1=1", "x /* y */ z -- w\nv", "'unterminated") {
+		if actual := join(lex(payload)); actual != payload {
+			t.Errorf("%q: lexed back to %q", payload, actual)
+		}
+	}
+}
+
+func TestVariants(t *testing.T) {
+	variants := map[string]string{}
+	for _, v := range Variants("1' OR name='admin'--") {
+		if _, dup := variants[v.Transform]; dup {
+			t.Errorf("transform %s listed twice", v.Transform)
+		}
+		variants[v.Transform] = v.Payload
+	}
+	for transform, expected := range map[string]string{
+		"comment:/**/":       "1'/**/OR/**/name='admin'--",
+		"comment:#\\n":       "1'#\nOR#\nname='admin'--",
+		"white:0x0b":         "1'\vOR\vname='admin'--",
+		"case:flip":          "1' Or NaMe='admin'--",
+		"op:symbol":          "1' || name='admin'--",
+		"str:hex":            "1' OR name=0x61646d696e--",
+		"str:char":           "1' OR name=CHAR(97,100,109,105,110)--",
+		"str:concat":         "1' OR name='ad' 'min'--",
+		"case:lower+str:hex": "1' or name=0x61646d696e--",
+	} {
+		if actual := variants[transform]; actual != expected {
+			t.Errorf("%s: expected %q, got %q", transform, expected, actual)
+		}
+	}
+	/* nothing to rewrite for these */
+	for _, transform := range []string{"op:word", "num:hex", "white:0x20"} {
+		if actual, ok := variants[transform]; ok {
+			t.Errorf("%s: unexpected variant %q", transform, actual)
+		}
+	}
+
+	for _, v := range Variants("1||2") {
+		if v.Transform == "op:word" && v.Payload != "1 OR 2" {
+			t.Errorf("op:word: got %q", v.Payload)
+		}
+	}
+	for _, v := range Variants("1 && 2") {
+		if v.Transform == "op:word" && v.Payload != "1 AND 2" {
+			t.Errorf("op:word: got %q", v.Payload)
+		}
+		if v.Transform == "num:hex" && v.Payload != "0x1 && 0x2" {
+			t.Errorf("num:hex: got %q", v.Payload)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	s := Run(corpus, SQLi)
+	if len(s.Undetected) != 0 {
+		t.Errorf("payloads not detected as they are: %q", s.Undetected)
+	}
+	if s.Payloads != len(corpus) || s.Variants == 0 {
+		t.Fatalf("unexpected summary %+v", s)
+	}
+
+	total := 0
+	for _, st := range s.ByTransform {
+		if st.Missed > st.Variants {
+			t.Errorf("%s: %d missed of %d", st.Transform, st.Missed, st.Variants)
+		}
+		total += st.Variants
+	}
+	/* pairs count for both of their transforms */
+	if total < s.Variants {
+		t.Errorf("per transform counts add up to %d, fewer than %d variants", total, s.Variants)
+	}
+
+	t.Logf("%d variants of %d payloads, %d missed (%.1f%%)", s.Variants, s.Payloads, s.Missed, 100*s.BypassRate())
+	for _, st := range s.ByTransform {
+		if st.Missed != 0 {
+			t.Logf("  %-16s %d/%d missed", st.Transform, st.Missed, st.Variants)
+		}
+	}
+
+	r := Check("1 OR 1=1", func(string) bool { return false })
+	if r.Detected || len(r.Missed) != len(r.Variants) || r.BypassRate() != 1 {
+		t.Errorf("a detector finding nothing misses everything, got %+v", r)
+	}
+}
+
+/*
+ * TestBaseline compares the bypass rates on corpus with the checked in
+ * baseline. Check the changes then run go test ./mutate -update to
+ * record new rates.
+ */
+func TestBaseline(t *testing.T) {
+	path := filepath.Join("testdata", "baseline.tsv")
+	s := Run(corpus, SQLi)
+	var actual bytes.Buffer
+	if err := s.WriteBaseline(&actual); err != nil {
+		t.Fatal(err)
+	}
+	if *update {
+		if err := os.WriteFile(path, actual.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseline, err := ReadBaseline(bytes.NewReader(expected))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if regressions := s.Regressions(&baseline); len(regressions) != 0 {
+		t.Errorf("bypass rates above the baseline:\n%s", strings.Join(regressions, "\n"))
+	} else if !bytes.Equal(actual.Bytes(), expected) {
+		t.Errorf("counts differ from %s, run with -update to record them:\n%s", path, actual.String())
+	}
+
+	/* a baseline missing less, or lacking a transform that misses */
+	worse := Summary{Variants: 10, Missed: 3, ByTransform: []TransformStats{{"case:flip", 5, 2}, {"num:hex", 5, 1}}}
+	base := Summary{Variants: 10, Missed: 1, ByTransform: []TransformStats{{"case:flip", 4, 1}}}
+	expectedRegressions := "total: 3/10 missed, was 1/10\ncase:flip: 2/5 missed, was 1/4\nnum:hex: 1/5 missed, was 0/0"
+	if actual := strings.Join(worse.Regressions(&base), "\n"); actual != expectedRegressions {
+		t.Errorf("expected regressions\n%s\ngot\n%s", expectedRegressions, actual)
+	}
+	if regressions := base.Regressions(&worse); len(regressions) != 0 {
+		t.Errorf("unexpected regressions %q", regressions)
+	}
+}
@@ -0,0 +1,207 @@
+package libinjection
+
+import (
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+/*
+ * NoSQL injection into MongoDB style queries: operators where the
+ * application expects a plain value. They come in JSON bodies, e.g.
+ * {"password": {"$ne": null}}, or as the bracket syntax of form and query
+ * string parameters, password[$ne]=, which most frameworks turn into the
+ * same object. $where and $function also run their JavaScript body on
+ * the server.
+ */
+
+const (
+	FLAG_NOSQL_JSON = 128 /* 1 << 7, the input is a JSON document */
+	FLAG_NOSQL_FORM = 256 /* 1 << 8, a query string or form body */
+	FLAG_NOSQL_JS   = 512 /* 1 << 9, a JavaScript body runs on the server */
+)
+
+/*
+ * MongoDB query and aggregation operators that change the meaning of a
+ * value when injected.
+ */
+var nosqlOperators = map[string]bool{
+	"$accumulator": true,
+	"$all":         true,
+	"$and":         true,
+	"$elemMatch":   true,
+	"$eq":          true,
+	"$exists":      true,
+	"$expr":        true,
+	"$function":    true,
+	"$gt":          true,
+	"$gte":         true,
+	"$in":          true,
+	"$jsonSchema":  true,
+	"$lt":          true,
+	"$lte":         true,
+	"$mod":         true,
+	"$ne":          true,
+	"$nin":         true,
+	"$nor":         true,
+	"$not":         true,
+	"$or":          true,
+	"$regex":       true,
+	"$size":        true,
+	"$text":        true,
+	"$type":        true,
+	"$where":       true,
+}
+
+/*
+ * Signs of code in a $where or $function body, rather than a constant.
+ */
+var nosqlJavaScript = []string{"(", "this.", "return", "function", "=>", "while", "for", ";", "||", "&&", "==", "sleep"}
+
+/*
+ * nosqlOperator returns the operator of a key: the key itself, $ne, or
+ * the bracket syntax of form parameters, password[$ne]. It returns "" if
+ * the key isn't an operator.
+ */
+func nosqlOperator(key string) string {
+	if nosqlOperators[key] {
+		return key
+	}
+	for {
+		open := strings.Index(key, "[$")
+		if open == -1 {
+			return ""
+		}
+		key = key[open+1:]
+		if end := strings.IndexByte(key, ']'); end != -1 && nosqlOperators[key[:end]] {
+			return key[:end]
+		}
+	}
+}
+
+/*
+ * nosqlJS tells if body, the value of $where or $function, is code.
+ */
+func nosqlJS(body interface{}) bool {
+	switch v := body.(type) {
+	case string:
+		for _, sign := range nosqlJavaScript {
+			if strings.Contains(v, sign) {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		/* $function: {body: ..., args: [...], lang: "js"} */
+		return nosqlJS(v["body"])
+	}
+	return false
+}
+
+/*
+ * nosqlWalk returns the first operator in the keys of a decoded JSON
+ * value, and whether it runs JavaScript.
+ */
+func nosqlWalk(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if op := nosqlOperator(key); op != "" {
+				return op, (op == "$where" || op == "$function") && nosqlJS(v[key])
+			}
+			if op, js := nosqlWalk(v[key]); op != "" {
+				return op, js
+			}
+		}
+	case []interface{}:
+		for _, e := range v {
+			if op, js := nosqlWalk(e); op != "" {
+				return op, js
+			}
+		}
+	case string:
+		/* JSON in a string, e.g. a filter parameter */
+		return nosqlJSON(v)
+	}
+	return "", false
+}
+
+/*
+ * nosqlJSON checks input if it is a JSON object or array.
+ */
+func nosqlJSON(input string) (string, bool) {
+	trimmed := strings.TrimSpace(input)
+	if trimmed == "" || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return "", false
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(trimmed), &v); err != nil {
+		return "", false
+	}
+	return nosqlWalk(v)
+}
+
+/*
+ * nosqlForm checks the keys of a query string or form body, and the
+ * values that are JSON.
+ */
+func nosqlForm(input string) (string, bool) {
+	values, _ := url.ParseQuery(strings.TrimPrefix(input, "?"))
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if op := nosqlOperator(key); op != "" {
+			js := false
+			if op == "$where" || op == "$function" {
+				for _, value := range values[key] {
+					js = js || nosqlJS(value)
+				}
+			}
+			return op, js
+		}
+		for _, value := range values[key] {
+			if op, js := nosqlJSON(value); op != "" {
+				return op, js
+			}
+		}
+	}
+	return "", false
+}
+
+/*
+ * DetectNoSQL checks a JSON document, query string or form body for
+ * MongoDB operator injection. The fingerprint is the operator, the flags
+ * tell whether it came from JSON or a form, and FLAG_NOSQL_JS if it runs
+ * JavaScript.
+ */
+func DetectNoSQL(input string) Result {
+	op, js := nosqlJSON(input)
+	flags := FLAG_NOSQL_JSON
+	if op == "" && strings.IndexByte(input, '=') != -1 {
+		op, js = nosqlForm(input)
+		flags = FLAG_NOSQL_FORM
+	}
+	if op == "" {
+		return Result{}
+	}
+	if js {
+		flags |= FLAG_NOSQL_JS
+	}
+	return Result{Injection: true, Fingerprint: op, Flags: flags}
+}
+
+/*
+ * IsNoSQL tells if input is a NoSQL injection, with the operator.
+ */
+func IsNoSQL(input string) (bool, string) {
+	result := DetectNoSQL(input)
+	return result.Injection, result.Fingerprint
+}
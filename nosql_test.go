@@ -0,0 +1,41 @@
+package libinjection
+
+import "testing"
+
+func TestDetectNoSQL(t *testing.T) {
+	tests := []struct {
+		input    string
+		operator string
+		flags    int
+	}{
+		{`{"username": "admin", "password": {"$ne": null}}`, "$ne", FLAG_NOSQL_JSON},
+		{`{"age": {"$gt": ""}}`, "$gt", FLAG_NOSQL_JSON},
+		{`[{"name": {"$regex": "^a"}}]`, "$regex", FLAG_NOSQL_JSON},
+		{`{"$expr": {"$eq": ["$a", "$b"]}}`, "$expr", FLAG_NOSQL_JSON},
+		{`{"$ne": 1}`, "$ne", FLAG_NOSQL_JSON},
+		{`{"$where": "sleep(5000) || true"}`, "$where", FLAG_NOSQL_JSON | FLAG_NOSQL_JS},
+		{`{"$where": "1"}`, "$where", FLAG_NOSQL_JSON},
+		{`{"x": {"$function": {"body": "function() { return true }", "args": [], "lang": "js"}}}`, "$function", FLAG_NOSQL_JSON | FLAG_NOSQL_JS},
+		{`{"filter": "{\"$ne\": 1}"}`, "$ne", FLAG_NOSQL_JSON},
+		{"username=admin&password[$ne]=x", "$ne", FLAG_NOSQL_FORM},
+		{"user%5B%24gt%5D=", "$gt", FLAG_NOSQL_FORM},
+		{"?user[profile][$regex]=.*", "$regex", FLAG_NOSQL_FORM},
+		{"$where=this.password.length>0", "$where", FLAG_NOSQL_FORM | FLAG_NOSQL_JS},
+		{`filter={"price":{"$lt":0}}`, "$lt", FLAG_NOSQL_FORM},
+		/* benign */
+		{`{"username": "admin", "password": "$ecret"}`, "", 0},
+		{`{"price": "$5"}`, "", 0},
+		{"q=%24ne&sort[price]=asc", "", 0},
+		{"{not json", "", 0},
+		{"hello world", "", 0},
+	}
+	for _, test := range tests {
+		result := DetectNoSQL(test.input)
+		if result.Injection != (test.operator != "") || result.Fingerprint != test.operator || result.Flags != test.flags {
+			t.Errorf("%q: expected %q %d, got %+v", test.input, test.operator, test.flags, result)
+		}
+		if is, operator := IsNoSQL(test.input); is != result.Injection || operator != test.operator {
+			t.Errorf("%q: IsNoSQL gives %v %q", test.input, is, operator)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package libinjection
+
+/*
+ * Result is the outcome of running a detector on one input, with the
+ * details of the pass that decided it.
+ */
+type Result struct {
+	Injection   bool
+	Fingerprint string /* SQLi only */
+	/*
+	 * The context of the pass: FLAG_QUOTE_* | FLAG_SQL_* for SQLi,
+	 * DATA_STATE, VALUE_NO_QUOTE, ... for XSS. For benign SQLi input this
+	 * is the last pass tried, which is also where Fingerprint comes from.
+	 */
+	Flags int
+}
+
+/*
+ * DetectSQLi is IsSQLi, also telling which pass matched. Benign input
+ * still gets the fingerprint of the last pass tried.
+ */
+func DetectSQLi(input string) Result {
+	sqli := &Sqli{state: newState(input, len(input), 0)}
+	injection := sqli.libinjection_is_sqli()
+	return Result{
+		Injection:   injection,
+		Fingerprint: sqli.state.fingerprint,
+		Flags:       sqli.state.flags,
+	}
+}
+
+/*
+ * DetectSQLiFlags runs a single pass, for input known to be injected in the
+ * context given by flags: FLAG_QUOTE_NONE, FLAG_QUOTE_SINGLE or
+ * FLAG_QUOTE_DOUBLE, or'ed with FLAG_SQL_ANSI or FLAG_SQL_MYSQL.
+ */
+func DetectSQLiFlags(input string, flags int) Result {
+	sqli := &Sqli{state: newState(input, len(input), flags)}
+	if len(input) == 0 {
+		return Result{Flags: sqli.state.flags}
+	}
+	sqli.libinjection_sqli_fingerprint(sqli.state.flags)
+	return Result{
+		Injection:   sqli.libinjection_sqli_check_fingerprint(),
+		Fingerprint: sqli.state.fingerprint,
+		Flags:       sqli.state.flags,
+	}
+}
+
+/*
+ * DetectXSS is IsXSS, also telling in which html5 context the input
+ * matched.
+ */
+func DetectXSS(input string) Result {
+	flags := libinjection_xss(input)
+	if flags == -1 {
+		return Result{}
+	}
+	return Result{Injection: true, Flags: flags}
+}
+
+/*
+ * DetectXSSFlags checks a single html5 context: DATA_STATE, VALUE_NO_QUOTE,
+ * VALUE_SINGLE_QUOTE, VALUE_DOUBLE_QUOTE or VALUE_BACK_QUOTE.
+ */
+func DetectXSSFlags(input string, flags int) Result {
+	return Result{Injection: libinjection_is_xss(input, flags), Flags: flags}
+}
+
+/*
+ * SQLiFlagsString names a SQLi pass: quote context and dialect, e.g.
+ * "single/mysql".
+ */
+func SQLiFlagsString(flags int) string {
+	quote := "none"
+	if flags&FLAG_QUOTE_SINGLE != 0 {
+		quote = "single"
+	} else if flags&FLAG_QUOTE_DOUBLE != 0 {
+		quote = "double"
+	}
+	dialect := "ansi"
+	if flags&FLAG_SQL_MYSQL != 0 {
+		dialect = "mysql"
+	}
+	return quote + "/" + dialect
+}
+
+/*
+ * XSSFlagsString names an html5 context: "data", "attr-unquoted",
+ * "attr-single", "attr-double" or "attr-backquote".
+ */
+func XSSFlagsString(flags int) string {
+	switch flags {
+	case DATA_STATE:
+		return "data"
+	case VALUE_NO_QUOTE:
+		return "attr-unquoted"
+	case VALUE_SINGLE_QUOTE:
+		return "attr-single"
+	case VALUE_DOUBLE_QUOTE:
+		return "attr-double"
+	case VALUE_BACK_QUOTE:
+		return "attr-backquote"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,97 @@
+package libinjection
+
+import (
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestDetectSQLi(t *testing.T) {
+	tests := []struct {
+		input       string
+		injection   bool
+		fingerprint string
+		flags       int
+	}{
+		{"1 UNION SELECT 1", true, "1UE1", FLAG_QUOTE_NONE | FLAG_SQL_ANSI},
+		{"admin' OR 1=1--", true, "s&1c", FLAG_QUOTE_SINGLE | FLAG_SQL_ANSI},
+		{"hello world", false, "", 0},
+	}
+
+	for _, test := range tests {
+		result := DetectSQLi(test.input)
+		if result.Injection != test.injection {
+			t.Errorf("%q: expected %v, got %v", test.input, test.injection, result.Injection)
+		}
+		if test.injection && (result.Fingerprint != test.fingerprint || result.Flags != test.flags) {
+			t.Errorf("%q: expected %s/%d, got %s/%d", test.input, test.fingerprint, test.flags, result.Fingerprint, result.Flags)
+		}
+		if is, fingerprint := IsSQLi(test.input); is != result.Injection || (is && fingerprint != result.Fingerprint) {
+			t.Errorf("%q: IsSQLi gives %v %q", test.input, is, fingerprint)
+		}
+	}
+
+	if result := DetectSQLiFlags("1 OR 1=1", FLAG_QUOTE_NONE|FLAG_SQL_ANSI); !result.Injection {
+		t.Errorf("expected SQLi, got %+v", result)
+	}
+	if result := DetectSQLiFlags("admin' OR 1=1--", FLAG_QUOTE_NONE|FLAG_SQL_ANSI); result.Injection {
+		t.Errorf("expected no SQLi without the single quote pass, got %+v", result)
+	}
+}
+
+func TestDetectXSS(t *testing.T) {
+	if result := DetectXSSFlags("\" onload=\"alert(1)", VALUE_DOUBLE_QUOTE); !result.Injection {
+		t.Errorf("expected XSS in a double quoted value, got %+v", result)
+	}
+	if result := DetectXSS("<script>"); !result.Injection || result.Flags != DATA_STATE {
+		t.Errorf("expected XSS in text, got %+v", result)
+	}
+	if result := DetectXSSFlags("<script>", VALUE_DOUBLE_QUOTE); result.Injection {
+		t.Errorf("expected no XSS inside a double quoted value, got %+v", result)
+	}
+}
+
+/*
+ * sqliFromPasses is libinjection_is_sqli, run on the result of SQLiPasses.
+ */
+func sqliFromPasses(passes []SQLiPass) (bool, string) {
+	for _, pass := range passes {
+		if pass.Tried && IsFingerprint(pass.Fingerprint) && !pass.Whitelisted {
+			return true, pass.Fingerprint
+		}
+	}
+	return false, ""
+}
+
+func TestSQLiPasses(t *testing.T) {
+	inputs := []string{"", "1 UNION SELECT 1", "admin' OR 1=1--", "1 OR 1=1 #", "a\" OR \"1\"=\"1", "O'Reilly & Sons"}
+	files, _ := filepath.Glob(filepath.Join("tests", "test-sqli-*.txt"))
+	for _, file := range files {
+		input, _ := readTestFile(t, file)
+		inputs = append(inputs, input)
+	}
+
+	for _, input := range inputs {
+		passes := SQLiPasses(input)
+		if len(passes) != 6 {
+			t.Fatalf("%q: %d passes", input, len(passes))
+		}
+		issqli, fingerprint := IsSQLi(input)
+		if is, fp := sqliFromPasses(passes); is != issqli || fp != fingerprint {
+			t.Errorf("%q: IsSQLi gives %v %q, the passes %v %q", input, issqli, fingerprint, is, fp)
+		}
+		for _, pass := range passes {
+			if result := DetectSQLiFlags(input, pass.Flags); result.Fingerprint != pass.Fingerprint {
+				t.Errorf("%q, %s: expected %q, got %q", input, SQLiFlagsString(pass.Flags), result.Fingerprint, pass.Fingerprint)
+			}
+		}
+	}
+
+	if !IsFingerprint("1UE1") || !IsFingerprint("1ue1") || IsFingerprint("") || IsFingerprint("UNION") {
+		t.Error("unexpected IsFingerprint")
+	}
+	fingerprints := Fingerprints()
+	if len(fingerprints) < 8000 || !sort.StringsAreSorted(fingerprints) {
+		t.Errorf("unexpected database of %d fingerprints", len(fingerprints))
+	}
+}
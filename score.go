@@ -0,0 +1,189 @@
+package libinjection
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+/*
+ * Action is what a score calls for.
+ */
+type Action int
+
+const (
+	ActionAllow Action = iota
+	/* the grey zone: let the input through but log it */
+	ActionLog
+	ActionBlock
+)
+
+func (a Action) String() string {
+	switch a {
+	case ActionAllow:
+		return "allow"
+	case ActionLog:
+		return "log"
+	case ActionBlock:
+		return "block"
+	default:
+		return fmt.Sprintf("Action(%d)", int(a))
+	}
+}
+
+/*
+ * Points of the signals. A pass whose fingerprint is in the database
+ * starts at scoreMatch, or scoreWhitelisted when
+ * libinjection_sqli_not_whitelist turned it down, else at 0. The other
+ * signals add to or take from that.
+ */
+const (
+	scoreMatch       = 80
+	scoreWhitelisted = 30
+	scoreQuoteGuess  = -10 /* FLAG_QUOTE_SINGLE or FLAG_QUOTE_DOUBLE pass */
+	scoreReparse     = -10 /* match only in the MySQL reparse of a pass with comments */
+	scoreRare        = 10  /* fingerprint never seen in benign traffic */
+	scoreCommon      = -40 /* seen in commonShare of benign traffic or more */
+	scoreEvil        = 25  /* TYPE_EVIL: could not be parsed accurately */
+	scoreFold        = 2   /* per fold, up to maxFolds */
+	maxFolds         = 5
+	commonShare      = 0.01
+
+	/* comments, by kind */
+	scoreCommentDDW  = 3 /* "-- " */
+	scoreCommentDDX  = 8 /* "--" not followed by a space, MySQL disagrees */
+	scoreCommentC    = 5 /* C style */
+	scoreCommentHash = 8 /* '#', MySQL only */
+)
+
+/*
+ * Score grades an input from 0, benign, to 100.
+ */
+type Score struct {
+	Result        /* of DetectSQLi, the verdict is unchanged */
+	Score  int    /* of the pass that scored highest */
+	Action Action /* from the thresholds of the Scorer */
+	/* the signals that made up Score, e.g. "match", "comment:hash" */
+	Reasons []string
+}
+
+/*
+ * Scorer grades inputs by the signals the SQLi detector computes, so
+ * clear attacks can be blocked and doubtful ones only logged. The zero
+ * value is ready to use.
+ */
+type Scorer struct {
+	/*
+	 * Benign is the share of benign inputs with each fingerprint, from 0
+	 * to 1, e.g. counted with SQLiPasses on known good traffic. Fingerprints
+	 * common in benign traffic score lower, ones never seen higher. When
+	 * nil, rarity is not a signal.
+	 */
+	Benign map[string]float64
+	Block  int /* the least score blocked, default 70 */
+	Log    int /* the least score logged, default 40 */
+}
+
+func (s *Scorer) action(score int) Action {
+	block, log := s.Block, s.Log
+	if block <= 0 {
+		block = 70
+	}
+	if log <= 0 {
+		log = 40
+	}
+	switch {
+	case score >= block:
+		return ActionBlock
+	case score >= log:
+		return ActionLog
+	default:
+		return ActionAllow
+	}
+}
+
+/*
+ * scorePass grades one pass from the state libinjection_sqli_fingerprint
+ * left. ansiMatch tells if the ANSI pass with the same quote flags
+ * matched.
+ */
+func (s *Scorer) scorePass(pass *SQLiPass, state *State, ansiMatch bool) (int, []string) {
+	score := 0
+	var reasons []string
+	add := func(points int, reason string) {
+		score += points
+		reasons = append(reasons, reason)
+	}
+
+	if IsFingerprint(pass.Fingerprint) {
+		if pass.Whitelisted {
+			add(scoreWhitelisted, "whitelisted")
+		} else {
+			add(scoreMatch, "match")
+		}
+		if pass.Flags&(FLAG_QUOTE_SINGLE|FLAG_QUOTE_DOUBLE) != 0 {
+			add(scoreQuoteGuess, "quote-guess")
+		}
+		/*
+		 * a match only MySQL sees is less certain, unless the input has a
+		 * '#' comment: that is MySQL only, the input was written for it
+		 */
+		if pass.Flags&FLAG_SQL_MYSQL != 0 && pass.Flags&FLAG_QUOTE_DOUBLE == 0 &&
+			!ansiMatch && state.stats_comment_hash == 0 {
+			add(scoreReparse, "mysql-reparse")
+		}
+		if s.Benign != nil {
+			if share := s.Benign[pass.Fingerprint]; share == 0 {
+				add(scoreRare, "rare")
+			} else if points := int(float64(scoreCommon) * math.Min(1, share/commonShare)); points != 0 {
+				add(points, "common")
+			}
+		}
+	}
+
+	if strings.IndexByte(pass.Fingerprint, TYPE_EVIL) != -1 {
+		add(scoreEvil, "evil")
+	}
+	if state.stats_comment_ddw > 0 {
+		add(scoreCommentDDW, "comment:ddw")
+	}
+	if state.stats_comment_ddx > 0 {
+		add(scoreCommentDDX, "comment:ddx")
+	}
+	if state.stats_comment_c > 0 {
+		add(scoreCommentC, "comment:c")
+	}
+	if state.stats_comment_hash > 0 {
+		add(scoreCommentHash, "comment:hash")
+	}
+	if state.stats_folds > 0 {
+		add(scoreFold*imin(state.stats_folds, maxFolds), fmt.Sprintf("folds:%d", state.stats_folds))
+	}
+	return imax(0, imin(100, score)), reasons
+}
+
+/*
+ * ScoreSQLi grades input by the pass libinjection_is_sqli tries that
+ * scores highest.
+ */
+func (s *Scorer) ScoreSQLi(input string) Score {
+	score := Score{Result: DetectSQLi(input)}
+	best := -1
+	/* by quote flags, the ANSI pass comes first */
+	ansiMatch := map[int]bool{}
+	sqliPasses(input, func(pass *SQLiPass, state *State) {
+		quote := pass.Flags &^ (FLAG_SQL_ANSI | FLAG_SQL_MYSQL)
+		if pass.Flags&FLAG_SQL_ANSI != 0 {
+			ansiMatch[quote] = pass.Tried && IsFingerprint(pass.Fingerprint) && !pass.Whitelisted
+		}
+		if !pass.Tried {
+			return
+		}
+		if points, reasons := s.scorePass(pass, state, ansiMatch[quote]); points > best {
+			best = points
+			score.Score, score.Reasons = points, reasons
+		}
+	})
+	score.Action = s.action(score.Score)
+	return score
+}
@@ -0,0 +1,68 @@
+package libinjection
+
+import (
+	"strings"
+	"testing"
+)
+
+/*
+ * Classic payloads ending in a '#' comment are blocked with the default
+ * thresholds.
+ */
+func TestScoreHashComment(t *testing.T) {
+	var s Scorer
+	for _, input := range []string{"1' and 1=1#", "1' or 1=1#", "' or 1=1#", "admin' or '1'='1'#", "1 union select 1#"} {
+		if score := s.ScoreSQLi(input); score.Action != ActionBlock {
+			t.Errorf("%q: expected block, got %d %v %v", input, score.Score, score.Action, score.Reasons)
+		}
+	}
+}
+
+func TestScoreSQLi(t *testing.T) {
+	tests := []struct {
+		input   string
+		score   int
+		action  Action
+		reasons string
+	}{
+		{"hello world", 0, ActionAllow, ""},
+		{"1 or 1", 30, ActionAllow, "whitelisted"},
+		{"1 UNION SELECT 1", 80, ActionBlock, "match"},
+		{"admin' OR 1=1--", 70, ActionBlock, "match,quote-guess"},
+		/* '#' is MySQL only, its reparse is no guess */
+		{"1' and 1=1#", 78, ActionBlock, "match,quote-guess,comment:hash"},
+		/* only the MySQL reparse of a quote guess matches */
+		{"1' --x or 1=1", 62, ActionLog, "match,quote-guess,mysql-reparse,folds:1"},
+		{"1 or 1 --x", 88, ActionBlock, "match,comment:ddx"},
+		{"1 /*!union*/ select 1", 100, ActionBlock, "match,evil"},
+		{"-1 or +-1=1", 84, ActionBlock, "match,folds:2"},
+	}
+	var s Scorer
+	for _, test := range tests {
+		score := s.ScoreSQLi(test.input)
+		if score.Score != test.score || score.Action != test.action || strings.Join(score.Reasons, ",") != test.reasons {
+			t.Errorf("%q: expected %d %v %s, got %d %v %v", test.input, test.score, test.action, test.reasons, score.Score, score.Action, score.Reasons)
+		}
+		if score.Result != DetectSQLi(test.input) {
+			t.Errorf("%q: unexpected result %+v", test.input, score.Result)
+		}
+	}
+
+	s = Scorer{Benign: map[string]float64{"1&1": 0.02, "s&sos": 0.005}, Block: 90, Log: 50}
+	tests = []struct {
+		input   string
+		score   int
+		action  Action
+		reasons string
+	}{
+		{"-1 or 2", 40, ActionAllow, "match,common"},
+		{"1' OR '1'='1", 50, ActionLog, "match,quote-guess,common"},
+		{"1 UNION SELECT 1", 90, ActionBlock, "match,rare"},
+	}
+	for _, test := range tests {
+		score := s.ScoreSQLi(test.input)
+		if score.Score != test.score || score.Action != test.action || strings.Join(score.Reasons, ",") != test.reasons {
+			t.Errorf("%q: expected %d %v %s, got %d %v %v", test.input, test.score, test.action, test.reasons, score.Score, score.Action, score.Reasons)
+		}
+	}
+}
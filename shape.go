@@ -0,0 +1,218 @@
+package libinjection
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+ * Shape is the normalized form of a complete SQL statement.
+ *
+ * While the fingerprint only looks at the first five folded tokens of a
+ * user parameter, a shape covers the whole statement: every token is kept,
+ * compound keywords are merged ("UNION ALL", "ORDER BY"), and literals are
+ * replaced so the same query with different values has the same shape.
+ */
+type Shape struct {
+	/* one token type per token, e.g. "Eonknon?" */
+	Fingerprint string
+	/*
+	 * the statement with literals and placeholders replaced by '?', lists
+	 * of literals folded to a single '?', keywords upper cased, barewords
+	 * lower cased and comments replaced by an empty one
+	 */
+	Normalized string
+}
+
+/*
+ * QueryShape tokenizes a complete statement and returns its shape. flags
+ * are the same as for fingerprinting, 0 means FLAG_QUOTE_NONE |
+ * FLAG_SQL_ANSI.
+ *
+ * Like for fingerprints, token values are truncated to
+ * LIBINJECTION_SQLI_TOKEN_SIZE - 1 bytes, so identifiers sharing a long
+ * prefix get the same shape.
+ */
+func QueryShape(query string, flags int) Shape {
+	tokens := Tokenize(query, flags)
+
+	/* merge compound keywords, same as the folding does */
+	for i := 0; i < len(tokens)-1; {
+		if tokens[i].syntax_merge_words(&tokens[i+1]) {
+			tokens = append(tokens[:i+1], tokens[i+2:]...)
+			continue
+		}
+		i++
+	}
+
+	types := make([]byte, 0, len(tokens))
+	words := make([]string, 0, len(tokens))
+	for i := range tokens {
+		token := &tokens[i]
+		stype := token.Type
+		word := ""
+
+		switch {
+		case stype == TYPE_STRING || stype == TYPE_NUMBER || (stype == TYPE_UNKNOWN && token.val == "?"):
+			/* a literal, or a placeholder of a prepared statement */
+			stype = TYPE_UNKNOWN
+			word = "?"
+
+			/* "?,?,?" -> "?", so IN lists of any size have the same shape */
+			n := len(words)
+			if n >= 2 && words[n-1] == "," && words[n-2] == "?" {
+				words = words[:n-1]
+				types = types[:n-1]
+				continue
+			}
+		case stype == TYPE_COMMENT:
+			word = "/**/"
+		case stype == TYPE_BAREWORD:
+			word = strings.ToLower(token.val)
+		case stype == TYPE_VARIABLE:
+			word = strings.Repeat("@", token.count) + token.val
+		default:
+			word = strings.ToUpper(token.val)
+		}
+
+		types = append(types, stype)
+		words = append(words, word)
+	}
+
+	return Shape{
+		Fingerprint: string(types),
+		Normalized:  strings.Join(words, " "),
+	}
+}
+
+/*
+ * ShapeAllowlist holds the shapes of known good statements. Shapes are
+ * learned from the statements an application is known to run, statements
+ * whose shape is not in the list had their structure changed, which is what
+ * an injection does. It is safe for concurrent use.
+ */
+type ShapeAllowlist struct {
+	mu     sync.RWMutex
+	flags  int
+	shapes map[string]bool
+}
+
+/*
+ * NewShapeAllowlist returns an empty allowlist, flags are given to
+ * QueryShape.
+ */
+func NewShapeAllowlist(flags int) *ShapeAllowlist {
+	if flags == 0 {
+		flags = FLAG_QUOTE_NONE | FLAG_SQL_ANSI
+	}
+	return &ShapeAllowlist{
+		flags:  flags,
+		shapes: map[string]bool{},
+	}
+}
+
+/*
+ * Flags returns the flags shapes are made with. Load changes them to the
+ * flags of the shapes it reads.
+ */
+func (a *ShapeAllowlist) Flags() int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.flags
+}
+
+/*
+ * Learn adds the shape of query to the allowlist.
+ */
+func (a *ShapeAllowlist) Learn(query string) Shape {
+	shape := QueryShape(query, a.Flags())
+	a.mu.Lock()
+	a.shapes[shape.Normalized] = true
+	a.mu.Unlock()
+	return shape
+}
+
+/*
+ * Check returns the shape of query and whether it is in the allowlist.
+ */
+func (a *ShapeAllowlist) Check(query string) (Shape, bool) {
+	shape := QueryShape(query, a.Flags())
+	a.mu.RLock()
+	known := a.shapes[shape.Normalized]
+	a.mu.RUnlock()
+	return shape, known
+}
+
+/*
+ * Shapes returns the normalized shapes in the allowlist, sorted.
+ */
+func (a *ShapeAllowlist) Shapes() []string {
+	a.mu.RLock()
+	shapes := make([]string, 0, len(a.shapes))
+	for shape := range a.shapes {
+		shapes = append(shapes, shape)
+	}
+	a.mu.RUnlock()
+	sort.Strings(shapes)
+	return shapes
+}
+
+/*
+ * A saved allowlist starts with the flags its shapes were made with. A
+ * normalized shape can't be mistaken for it, numbers are replaced by '?'.
+ */
+const shapeFlagsHeader = "# flags "
+
+/*
+ * Save writes the allowlist to w: the flags, then one normalized shape
+ * per line.
+ */
+func (a *ShapeAllowlist) Save(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString(shapeFlagsHeader + strconv.Itoa(a.Flags()) + "\n"); err != nil {
+		return err
+	}
+	for _, shape := range a.Shapes() {
+		if _, err := bw.WriteString(shape + "\n"); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+/*
+ * Load adds the shapes written by Save to the allowlist. Shapes made with
+ * other flags than the allowlist's would never match, so an empty
+ * allowlist takes the flags of the shapes, and one with shapes already
+ * must have the same. Files without flags, from before they were saved,
+ * are taken as made with the allowlist's. Empty lines are ignored.
+ */
+func (a *ShapeAllowlist) Load(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := scanner.Text()
+		if lineno == 1 && strings.HasPrefix(line, shapeFlagsHeader) {
+			flags, err := strconv.Atoi(line[len(shapeFlagsHeader):])
+			if err != nil {
+				return fmt.Errorf("line 1: bad flags %q", line[len(shapeFlagsHeader):])
+			}
+			if flags != a.flags && len(a.shapes) > 0 {
+				return fmt.Errorf("shapes made with flags %d, the allowlist has shapes made with %d", flags, a.flags)
+			}
+			a.flags = flags
+			continue
+		}
+		if line != "" {
+			a.shapes[line] = true
+		}
+	}
+	return scanner.Err()
+}
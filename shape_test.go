@@ -0,0 +1,111 @@
+package libinjection
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestQueryShape(t *testing.T) {
+	tests := []struct {
+		query      string
+		normalized string
+	}{
+		{"SELECT * FROM users WHERE id = 42", "SELECT * FROM users WHERE id = ?"},
+		{"select *  from Users where id=?", "SELECT * FROM users WHERE id = ?"},
+		{"SELECT name FROM users WHERE name = 'bob' ORDER BY id", "SELECT name FROM users WHERE name = ? ORDER BY id"},
+		{"SELECT * FROM t WHERE id IN (1, 2, 3)", "SELECT * FROM t WHERE id IN ( ? )"},
+		{"SELECT a FROM t UNION ALL SELECT b FROM u", "SELECT a FROM t UNION ALL SELECT b FROM u"},
+		{"SELECT * FROM t WHERE a = 1 -- comment", "SELECT * FROM t WHERE a = ? /**/"},
+	}
+
+	for _, test := range tests {
+		if shape := QueryShape(test.query, 0); shape.Normalized != test.normalized {
+			t.Errorf("%q: expected %q, got %q", test.query, test.normalized, shape.Normalized)
+		}
+	}
+
+	if a, b := QueryShape("SELECT * FROM t WHERE id IN (1)", 0), QueryShape("SELECT * FROM t WHERE id IN (7,8,9)", 0); a != b {
+		t.Errorf("IN lists should have the same shape: %v %v", a, b)
+	}
+}
+
+func TestShapeAllowlist(t *testing.T) {
+	allowlist := NewShapeAllowlist(0)
+	allowlist.Learn("SELECT * FROM users WHERE name = ? AND password = ?")
+	allowlist.Learn("SELECT id FROM products WHERE price < 10 ORDER BY price")
+
+	tests := []struct {
+		query string
+		known bool
+	}{
+		{"SELECT * FROM users WHERE name = 'alice' AND password = 'secret'", true},
+		{"SELECT * FROM users WHERE name = 'O''Reilly' AND password = 'x'", true},
+		{"SELECT * FROM users WHERE name = 'admin' --' AND password = ''", false},
+		{"SELECT * FROM users WHERE name = '' OR '1'='1' AND password = ''", false},
+		{"SELECT id FROM products WHERE price < 99 ORDER BY price", true},
+		{"SELECT id FROM products WHERE price < 1 UNION SELECT password FROM users ORDER BY price", false},
+	}
+	for _, test := range tests {
+		if _, known := allowlist.Check(test.query); known != test.known {
+			t.Errorf("%q: expected known=%v", test.query, test.known)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := allowlist.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded := NewShapeAllowlist(0)
+	if err := loaded.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Shapes()) != 2 {
+		t.Errorf("expected 2 shapes, got %v", loaded.Shapes())
+	}
+	if _, known := loaded.Check("SELECT * FROM users WHERE name = 'x' AND password = 'y'"); !known {
+		t.Error("loaded allowlist does not know the learned shape")
+	}
+}
+
+func TestShapeAllowlistFlags(t *testing.T) {
+	mysql := NewShapeAllowlist(FLAG_QUOTE_NONE | FLAG_SQL_MYSQL)
+	mysql.Learn("SELECT * FROM users WHERE id = ? # by id")
+
+	var buf bytes.Buffer
+	if err := mysql.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	saved := buf.String()
+	if !strings.HasPrefix(saved, "# flags 17\n") {
+		t.Errorf("expected the flags first, got %q", saved)
+	}
+
+	/* an empty allowlist takes the flags of what it loads */
+	loaded := NewShapeAllowlist(0)
+	if err := loaded.Load(strings.NewReader(saved)); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Flags() != FLAG_QUOTE_NONE|FLAG_SQL_MYSQL || len(loaded.Shapes()) != 1 {
+		t.Errorf("unexpected allowlist %d %v", loaded.Flags(), loaded.Shapes())
+	}
+	if _, known := loaded.Check("SELECT * FROM users WHERE id = 7 # by id"); !known {
+		t.Error("loaded allowlist does not know the learned shape")
+	}
+
+	/* shapes made with other flags don't mix */
+	ansi := NewShapeAllowlist(0)
+	ansi.Learn("SELECT 1")
+	if err := ansi.Load(strings.NewReader(saved)); err == nil {
+		t.Error("expected an error loading MySQL shapes into an ANSI allowlist")
+	}
+
+	/* files saved without flags */
+	old := NewShapeAllowlist(FLAG_QUOTE_NONE | FLAG_SQL_MYSQL)
+	if err := old.Load(strings.NewReader("SELECT * FROM users WHERE id = ? /**/\n")); err != nil {
+		t.Fatal(err)
+	}
+	if old.Flags() != FLAG_QUOTE_NONE|FLAG_SQL_MYSQL || len(old.Shapes()) != 1 {
+		t.Errorf("unexpected allowlist %d %v", old.Flags(), old.Shapes())
+	}
+}
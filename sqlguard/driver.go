@@ -0,0 +1,275 @@
+package sqlguard
+
+import (
+	"context"
+	"database/sql/driver"
+
+	libinjection "github.com/jptosso/libinjection-go"
+)
+
+/*
+ * WrapDriver returns a driver whose connections inspect every query before
+ * handing it to d. handler may be nil.
+ */
+func WrapDriver(d driver.Driver, policy Policy, handler Handler) driver.Driver {
+	return WrapDriverShapes(d, nil, policy, handler)
+}
+
+/*
+ * WrapDriverShapes is WrapDriver for an application whose statements are
+ * in shapes: the text of a query is checked against its learned shape
+ * instead of looked at for comments, stacked statements and tautologies.
+ */
+func WrapDriverShapes(d driver.Driver, shapes *libinjection.ShapeAllowlist, policy Policy, handler Handler) driver.Driver {
+	return &guardDriver{parent: d, guard: &guard{policy: policy, handler: handler, shapes: shapes}}
+}
+
+/*
+ * WrapConnector is the same as WrapDriver for drivers opened with
+ * sql.OpenDB. handler may be nil.
+ */
+func WrapConnector(c driver.Connector, policy Policy, handler Handler) driver.Connector {
+	return WrapConnectorShapes(c, nil, policy, handler)
+}
+
+/*
+ * WrapConnectorShapes is the same as WrapDriverShapes for drivers opened
+ * with sql.OpenDB.
+ */
+func WrapConnectorShapes(c driver.Connector, shapes *libinjection.ShapeAllowlist, policy Policy, handler Handler) driver.Connector {
+	g := &guard{policy: policy, handler: handler, shapes: shapes}
+	return &guardConnector{
+		parent: c,
+		guard:  g,
+		driver: &guardDriver{parent: c.Driver(), guard: g},
+	}
+}
+
+type guardDriver struct {
+	parent driver.Driver
+	guard  *guard
+}
+
+func (d *guardDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.parent.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &guardConn{parent: conn, guard: d.guard}, nil
+}
+
+func (d *guardDriver) OpenConnector(name string) (driver.Connector, error) {
+	if dc, ok := d.parent.(driver.DriverContext); ok {
+		c, err := dc.OpenConnector(name)
+		if err != nil {
+			return nil, err
+		}
+		return &guardConnector{parent: c, guard: d.guard, driver: d}, nil
+	}
+	return &dsnConnector{name: name, driver: d}, nil
+}
+
+type guardConnector struct {
+	parent driver.Connector
+	guard  *guard
+	driver driver.Driver
+}
+
+func (c *guardConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.parent.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &guardConn{parent: conn, guard: c.guard}, nil
+}
+
+func (c *guardConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+/* connector for drivers that only implement Open */
+type dsnConnector struct {
+	name   string
+	driver *guardDriver
+}
+
+func (c *dsnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.name)
+}
+
+func (c *dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+/*
+ * guardConn implements every optional interface of database/sql and falls
+ * back to what database/sql would do when the wrapped connection does not.
+ */
+type guardConn struct {
+	parent driver.Conn
+	guard  *guard
+}
+
+func (c *guardConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *guardConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if pc, ok := c.parent.(driver.ConnPrepareContext); ok {
+		stmt, err = pc.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.parent.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &guardStmt{parent: stmt, query: query, guard: c.guard}, nil
+}
+
+func (c *guardConn) Close() error {
+	return c.parent.Close()
+}
+
+func (c *guardConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *guardConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if bc, ok := c.parent.(driver.ConnBeginTx); ok {
+		return bc.BeginTx(ctx, opts)
+	}
+	return c.parent.Begin()
+}
+
+/*
+ * When the wrapped connection can not execute directly, ErrSkip makes
+ * database/sql prepare a statement instead, which is checked there.
+ */
+func (c *guardConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	ec, ok := c.parent.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	if err := c.guard.check(ctx, query, args); err != nil {
+		return nil, err
+	}
+	return ec.ExecContext(ctx, query, args)
+}
+
+func (c *guardConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	qc, ok := c.parent.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	if err := c.guard.check(ctx, query, args); err != nil {
+		return nil, err
+	}
+	return qc.QueryContext(ctx, query, args)
+}
+
+func (c *guardConn) Ping(ctx context.Context) error {
+	if p, ok := c.parent.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *guardConn) ResetSession(ctx context.Context) error {
+	if sr, ok := c.parent.(driver.SessionResetter); ok {
+		return sr.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *guardConn) IsValid() bool {
+	if v, ok := c.parent.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *guardConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if nvc, ok := c.parent.(driver.NamedValueChecker); ok {
+		return nvc.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+type guardStmt struct {
+	parent driver.Stmt
+	query  string
+	guard  *guard
+}
+
+func (s *guardStmt) Close() error {
+	return s.parent.Close()
+}
+
+func (s *guardStmt) NumInput() int {
+	return s.parent.NumInput()
+}
+
+func (s *guardStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+func (s *guardStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+func (s *guardStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if err := s.guard.check(ctx, s.query, args); err != nil {
+		return nil, err
+	}
+	if ec, ok := s.parent.(driver.StmtExecContext); ok {
+		return ec.ExecContext(ctx, args)
+	}
+	return s.parent.Exec(values(args))
+}
+
+func (s *guardStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	if err := s.guard.check(ctx, s.query, args); err != nil {
+		return nil, err
+	}
+	if qc, ok := s.parent.(driver.StmtQueryContext); ok {
+		return qc.QueryContext(ctx, args)
+	}
+	return s.parent.Query(values(args))
+}
+
+/*
+ * database/sql asks the statement how to convert arguments when neither
+ * the statement nor the connection checks them, the default converter is
+ * what it uses otherwise.
+ */
+func (s *guardStmt) ColumnConverter(idx int) driver.ValueConverter {
+	if cc, ok := s.parent.(driver.ColumnConverter); ok {
+		return cc.ColumnConverter(idx)
+	}
+	return driver.DefaultParameterConverter
+}
+
+func (s *guardStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if nvc, ok := s.parent.(driver.NamedValueChecker); ok {
+		return nvc.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return named
+}
+
+func values(args []driver.NamedValue) []driver.Value {
+	vals := make([]driver.Value, len(args))
+	for i, nv := range args {
+		vals[i] = nv.Value
+	}
+	return vals
+}
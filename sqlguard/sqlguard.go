@@ -0,0 +1,246 @@
+/*
+ * Package sqlguard wraps a database/sql driver and inspects every query
+ * right at the database boundary.
+ *
+ * String arguments bound to Exec and Query are run through the SQLi
+ * detector. The query text itself is tokenized and checked for structures
+ * a query template written by the application never has, which is how
+ * dynamically concatenated queries give injections away: comments, stacked
+ * statements, tautologies such as OR 1=1 and unparsable input. Closed
+ * comments before or after the statement, like the tags sqlcommenter
+ * appends, are left alone.
+ *
+ * When the statements of the application are known, a ShapeAllowlist
+ * learned from them replaces these checks: the query text is fine if its
+ * shape is in the allowlist.
+ */
+package sqlguard
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+
+	libinjection "github.com/jptosso/libinjection-go"
+)
+
+/*
+ * Policy tells the wrapper what to do with a query once a violation is
+ * found.
+ */
+type Policy int
+
+const (
+	/* the query is not sent to the database, ErrBlocked is returned */
+	Block Policy = iota
+	/* the query is sent to the database, the violation is only reported */
+	Alert
+)
+
+/*
+ * ErrBlocked is returned, wrapped, for queries refused by the Block policy.
+ */
+var ErrBlocked = errors.New("sqlguard: query blocked")
+
+/*
+ * Violation describes one reason a query was flagged.
+ */
+type Violation struct {
+	Query       string
+	Arg         int    /* 1-based ordinal of the argument, 0 for the query text */
+	Fingerprint string /* SQLi fingerprint, for arguments only */
+	Reason      string
+}
+
+/*
+ * Handler is called for every violation, with both policies.
+ */
+type Handler func(ctx context.Context, v Violation)
+
+type guard struct {
+	policy  Policy
+	handler Handler
+	shapes  *libinjection.ShapeAllowlist
+}
+
+func (g *guard) check(ctx context.Context, query string, args []driver.NamedValue) error {
+	violations := inspect(query, args, g.shapes)
+	if len(violations) == 0 {
+		return nil
+	}
+
+	if g.handler != nil {
+		for _, v := range violations {
+			g.handler(ctx, v)
+		}
+	}
+
+	if g.policy == Block {
+		return fmt.Errorf("%w: %s", ErrBlocked, violations[0].Reason)
+	}
+	return nil
+}
+
+/*
+ * Inspect returns the violations found in query and its arguments.
+ */
+func Inspect(query string, args []driver.NamedValue) []Violation {
+	return inspect(query, args, nil)
+}
+
+/*
+ * InspectShapes is Inspect for a query whose shape must be in shapes.
+ */
+func InspectShapes(query string, args []driver.NamedValue, shapes *libinjection.ShapeAllowlist) []Violation {
+	return inspect(query, args, shapes)
+}
+
+func inspect(query string, args []driver.NamedValue, shapes *libinjection.ShapeAllowlist) []Violation {
+	var violations []Violation
+
+	reasons := inspectQuery(query)
+	if shapes != nil {
+		reasons = nil
+		/* learned with or without the tags around it */
+		shape, known := shapes.Check(query)
+		if !known {
+			_, known = shapes.Check(untagged(query))
+		}
+		if !known {
+			reasons = append(reasons, fmt.Sprintf("query shape is not in the allowlist: %s", shape.Normalized))
+		}
+	}
+	for _, reason := range reasons {
+		violations = append(violations, Violation{Query: query, Reason: reason})
+	}
+
+	for _, arg := range args {
+		var value string
+		switch v := arg.Value.(type) {
+		case string:
+			value = v
+		case []byte:
+			value = string(v)
+		default:
+			continue
+		}
+
+		if issqli, fingerprint := libinjection.IsSQLi(value); issqli {
+			violations = append(violations, Violation{
+				Query:       query,
+				Arg:         arg.Ordinal,
+				Fingerprint: fingerprint,
+				Reason:      fmt.Sprintf("argument %d is SQLi (%s)", arg.Ordinal, fingerprint),
+			})
+		}
+	}
+
+	return violations
+}
+
+/*
+ * Looks at the whole statement, token by token. MySQL mode is used so '#'
+ * comments are seen as comments.
+ */
+func inspectQuery(query string) []string {
+	var reasons []string
+	tokens := libinjection.Tokenize(query, libinjection.FLAG_QUOTE_NONE|libinjection.FLAG_SQL_MYSQL)
+
+	first, last := statement(query, tokens)
+
+	comment, stacked, tautology, evil := false, false, false, false
+	for i := range tokens {
+		switch tokens[i].Type {
+		case libinjection.TYPE_COMMENT:
+			if i >= first && i <= last || !isTag(query, &tokens[i]) {
+				comment = true
+			}
+		case libinjection.TYPE_EVIL:
+			evil = true
+		case libinjection.TYPE_SEMICOLON:
+			/* a trailing semicolon is fine, another statement is not */
+			for _, next := range tokens[i+1:] {
+				if next.Type != libinjection.TYPE_SEMICOLON && next.Type != libinjection.TYPE_COMMENT {
+					stacked = true
+					break
+				}
+			}
+		case libinjection.TYPE_LOGIC_OPERATOR:
+			if i+3 < len(tokens) && isTautology(&tokens[i+1], &tokens[i+2], &tokens[i+3]) {
+				tautology = true
+			}
+		}
+	}
+
+	if evil {
+		reasons = append(reasons, "query can not be parsed")
+	}
+	if comment {
+		reasons = append(reasons, "query contains a comment")
+	}
+	if stacked {
+		reasons = append(reasons, "query contains stacked statements")
+	}
+	if tautology {
+		reasons = append(reasons, "query contains a tautology")
+	}
+	return reasons
+}
+
+/*
+ * statement returns the first and last tokens of the statement, without
+ * the closed comments and semicolons around it.
+ */
+func statement(query string, tokens []libinjection.Token) (int, int) {
+	first, last := 0, len(tokens)-1
+	for first <= last && isTag(query, &tokens[first]) {
+		first++
+	}
+	for last >= first && (isTag(query, &tokens[last]) || tokens[last].Type == libinjection.TYPE_SEMICOLON) {
+		last--
+	}
+	return first, last
+}
+
+/*
+ * untagged returns query without the closed comments around it.
+ */
+func untagged(query string) string {
+	tokens := libinjection.Tokenize(query, libinjection.FLAG_QUOTE_NONE|libinjection.FLAG_SQL_MYSQL)
+	first, last := statement(query, tokens)
+	if first > last {
+		return ""
+	}
+	end := len(query)
+	if last+1 < len(tokens) {
+		end = tokens[last+1].Pos()
+	}
+	return strings.TrimSpace(query[tokens[first].Pos():end])
+}
+
+/*
+ * isTag tells if token is a closed C style comment, the kind sqlcommenter
+ * and ORMs put around a statement. Comments to the end of the line are
+ * not, they are how an injection cuts the rest of a query off.
+ */
+func isTag(query string, token *libinjection.Token) bool {
+	if token.Type != libinjection.TYPE_COMMENT || !strings.HasPrefix(query[token.Pos():], "/*") {
+		return false
+	}
+	return strings.Contains(query[token.Pos()+2:], "*/")
+}
+
+/*
+ * left = right where both sides are the same literal, e.g. 1=1 or 'a'='a'
+ */
+func isTautology(left *libinjection.Token, op *libinjection.Token, right *libinjection.Token) bool {
+	if op.Type != libinjection.TYPE_OPERATOR || op.Value() != "=" {
+		return false
+	}
+	if left.Type != right.Type || left.Value() != right.Value() {
+		return false
+	}
+	return left.Type == libinjection.TYPE_NUMBER || left.Type == libinjection.TYPE_STRING
+}
@@ -0,0 +1,241 @@
+package sqlguard
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	libinjection "github.com/jptosso/libinjection-go"
+)
+
+/*
+ * a driver that records queries, with or without ExecerContext, or with
+ * statements that convert their arguments
+ */
+type fakeDriver struct {
+	direct  bool
+	convert bool
+	queries []string
+	args    []driver.Value
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	if d.direct {
+		return &fakeDirectConn{fakeConn{d}}, nil
+	}
+	if d.convert {
+		return &fakeConvertConn{fakeConn{d}}, nil
+	}
+	return &fakeConn{d}, nil
+}
+
+type fakeConn struct {
+	driver *fakeDriver
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{c.driver, query}, nil
+}
+
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+type fakeDirectConn struct {
+	fakeConn
+}
+
+func (c *fakeDirectConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.driver.queries = append(c.driver.queries, query)
+	return driver.RowsAffected(1), nil
+}
+
+type fakeStmt struct {
+	driver *fakeDriver
+	query  string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.driver.queries = append(s.driver.queries, s.query)
+	s.driver.args = append(s.driver.args, args...)
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.driver.queries = append(s.driver.queries, s.query)
+	return &fakeRows{}, nil
+}
+
+type fakeConvertConn struct {
+	fakeConn
+}
+
+func (c *fakeConvertConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeConvertStmt{fakeStmt{c.driver, query}}, nil
+}
+
+/* database/sql only converts the arguments a statement says it takes */
+type fakeConvertStmt struct {
+	fakeStmt
+}
+
+func (s *fakeConvertStmt) NumInput() int { return 1 }
+
+func (s *fakeConvertStmt) ColumnConverter(idx int) driver.ValueConverter {
+	return upperConverter{}
+}
+
+type upperConverter struct{}
+
+func (upperConverter) ConvertValue(v interface{}) (driver.Value, error) {
+	if s, ok := v.(string); ok {
+		return strings.ToUpper(s), nil
+	}
+	return v, nil
+}
+
+type fakeRows struct{}
+
+func (r *fakeRows) Columns() []string              { return nil }
+func (r *fakeRows) Close() error                   { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error { return io.EOF }
+
+type connector struct {
+	driver *fakeDriver
+}
+
+func (c connector) Connect(ctx context.Context) (driver.Conn, error) { return c.driver.Open("") }
+func (c connector) Driver() driver.Driver                            { return c.driver }
+
+func TestInspect(t *testing.T) {
+	tests := []struct {
+		query string
+		args  []interface{}
+		bad   bool
+	}{
+		{"SELECT * FROM users WHERE id = ?", []interface{}{"42"}, false},
+		{"SELECT * FROM users WHERE name = ?", []interface{}{"O'Reilly"}, false},
+		{"SELECT * FROM users WHERE name = ?", []interface{}{"x' or '1'='1"}, true},
+		{"SELECT * FROM users WHERE name = ?", []interface{}{[]byte("1 union select password from users --")}, true},
+		{"SELECT * FROM users WHERE name = 'bob';", nil, false},
+		{"SELECT * FROM users WHERE name = 'bob' OR 'a'='a'", nil, true},
+		{"SELECT * FROM users WHERE id = 1 OR 1=1", nil, true},
+		{"SELECT * FROM users WHERE id = 1; DROP TABLE users", nil, true},
+		{"SELECT * FROM users WHERE name = 'admin'-- ' AND password = ''", nil, true},
+		/* sqlcommenter and ORM tags */
+		{"SELECT * FROM users WHERE id = ? /* app:svc,route:/x */", []interface{}{"42"}, false},
+		{"SELECT * FROM users WHERE id = 1 /*controller='users'*/;", nil, false},
+		{"/* request 12 */ SELECT * FROM users WHERE id = 1", nil, false},
+		{"SELECT * FROM users /* x */ WHERE id = 1", nil, true},
+		{"SELECT * FROM users WHERE id = 1 /* unclosed", nil, true},
+		{"SELECT * FROM users WHERE id = 1 # app:svc", nil, true},
+	}
+
+	for _, test := range tests {
+		var args []driver.NamedValue
+		for i, arg := range test.args {
+			args = append(args, driver.NamedValue{Ordinal: i + 1, Value: arg})
+		}
+		if bad := len(Inspect(test.query, args)) > 0; bad != test.bad {
+			t.Errorf("%q %q: expected %v, got %v", test.query, test.args, test.bad, bad)
+		}
+	}
+}
+
+func TestInspectShapes(t *testing.T) {
+	shapes := libinjection.NewShapeAllowlist(libinjection.FLAG_QUOTE_NONE | libinjection.FLAG_SQL_MYSQL)
+	shapes.Learn("SELECT * FROM users WHERE id = ?")
+	shapes.Learn("SELECT * FROM users WHERE id = ? -- by id")
+
+	tests := []struct {
+		query string
+		bad   bool
+	}{
+		{"SELECT * FROM users WHERE id = 42 /* app:svc,route:/x */", false},
+		{"SELECT * FROM users WHERE id = 7 -- by id", false},
+		{"SELECT * FROM users WHERE id = 1 OR 1=1", true},
+		{"SELECT * FROM users WHERE id = 1; DROP TABLE users", true},
+		{"SELECT * FROM users", true},
+	}
+	for _, test := range tests {
+		if bad := len(InspectShapes(test.query, nil, shapes)) > 0; bad != test.bad {
+			t.Errorf("%q: expected %v, got %v", test.query, test.bad, bad)
+		}
+	}
+	if v := InspectShapes("SELECT * FROM users WHERE id = ?", []driver.NamedValue{{Ordinal: 1, Value: "1 or 1=1"}}, shapes); len(v) != 1 || v[0].Arg != 1 {
+		t.Errorf("arguments should still be checked: %v", v)
+	}
+
+	fake := &fakeDriver{}
+	db := sql.OpenDB(WrapConnectorShapes(connector{fake}, shapes, Block, nil))
+	defer db.Close()
+	if _, err := db.Exec("SELECT * FROM users WHERE id = ?", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("SELECT * FROM users WHERE id = ? OR id > 0", 1); !errors.Is(err, ErrBlocked) {
+		t.Errorf("expected ErrBlocked, got %v", err)
+	}
+}
+
+func TestWrapConnector(t *testing.T) {
+	for _, direct := range []bool{false, true} {
+		fake := &fakeDriver{direct: direct}
+		var violations []Violation
+		db := sql.OpenDB(WrapConnector(connector{fake}, Block, func(ctx context.Context, v Violation) {
+			violations = append(violations, v)
+		}))
+
+		if _, err := db.Exec("UPDATE users SET name = ? WHERE id = ?", "bob", 1); err != nil {
+			t.Fatal(err)
+		}
+		_, err := db.Exec("UPDATE users SET name = ? WHERE id = ?", "bob", "1 or 1=1")
+		if !errors.Is(err, ErrBlocked) {
+			t.Errorf("expected ErrBlocked, got %v", err)
+		}
+		if len(fake.queries) != 1 {
+			t.Errorf("blocked query reached the driver: %v", fake.queries)
+		}
+		if len(violations) != 1 || violations[0].Arg != 2 {
+			t.Errorf("unexpected violations: %v", violations)
+		}
+		db.Close()
+	}
+}
+
+func TestWrapDriverAlert(t *testing.T) {
+	fake := &fakeDriver{}
+	sql.Register("sqlguard-test", WrapDriver(fake, Alert, nil))
+	db, err := sql.Open("sqlguard-test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT * FROM users WHERE id = 1 OR 1=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+	if len(fake.queries) != 1 {
+		t.Errorf("query did not reach the driver: %v", fake.queries)
+	}
+}
+
+func TestColumnConverter(t *testing.T) {
+	fake := &fakeDriver{convert: true}
+	db := sql.OpenDB(WrapConnector(connector{fake}, Block, nil))
+	defer db.Close()
+
+	if _, err := db.Exec("UPDATE users SET name = ?", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.args) != 1 || fake.args[0] != "BOB" {
+		t.Errorf("the statement's converter was not used: %v", fake.args)
+	}
+}
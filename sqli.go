@@ -2,6 +2,7 @@ package libinjection
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"unicode"
 )
@@ -63,6 +64,12 @@ const (
 
 type Sqli struct {
 	state *State
+	/*
+	 * allow, if set, is asked about every fingerprint
+	 * libinjection_sqli_check_fingerprint matches, returning true turns the
+	 * match down. See Allowlist.
+	 */
+	allow func(fingerprint string) bool
 }
 
 func (sqli *Sqli) parse_number() int {
@@ -201,11 +208,11 @@ func (sqli *Sqli) parse_money() int {
 				state.tokenvec[state.current] = token
 				return slen
 			} else {
-				token := newToken(TYPE_STRING, pos+2, strend-(pos+2), s[pos+2:strend])
+				token := newToken(TYPE_STRING, pos+2, strend, s[pos+2:pos+2+strend])
 				token.str_open = '$'
 				token.str_close = '$'
 				state.tokenvec[state.current] = token
-				return strend + 2
+				return pos + 2 + strend + 2
 			}
 		} else {
 			/* it's not '$$', but maybe it's pgsql "$ quoted strings" */
@@ -225,8 +232,16 @@ func (sqli *Sqli) parse_money() int {
 					return pos + 1
 				}
 
-				/* we have $foobar$... find it again */
-				strend = strings.Index(s[pos+xlen+2:], s[pos:pos+xlen+2])
+				/*
+				 * we have $foobar$... find it again
+				 *
+				 * Porting Notes: the C version searches starting at
+				 * xlen+2 rather than pos+xlen+2, keep it that way.
+				 */
+				strend = strings.Index(s[xlen+2:slen-pos], s[pos:pos+xlen+2])
+				if strend != -1 {
+					strend += xlen + 2
+				}
 
 				if strend == -1 || strend < pos+xlen+2 {
 					/* fell off edge */
@@ -273,11 +288,10 @@ func (sqli *Sqli) parse_var() int {
 	/*
 	 * move past optional other '@'
 	 */
+	count := 1
 	if pos < slen && s[pos] == '@' {
 		pos += 1
-		state.tokenvec[state.current].count = 2
-	} else {
-		state.tokenvec[state.current].count = 1
+		count = 2
 	}
 
 	/*
@@ -288,25 +302,22 @@ func (sqli *Sqli) parse_var() int {
 			state.pos = pos
 			pos = sqli.parse_tick()
 			state.tokenvec[state.current].Type = TYPE_VARIABLE
+			state.tokenvec[state.current].count = count
 			return pos
 		} else if s[pos] == CHAR_SINGLE || s[pos] == CHAR_DOUBLE {
 			state.pos = pos
 			pos = sqli.parse_string()
 			state.tokenvec[state.current].Type = TYPE_VARIABLE
+			state.tokenvec[state.current].count = count
 			return pos
 		}
 	}
 
 	xlen = strlencspn(s[pos:], " <>:\\?=@!#~+-*/&|^%(),';\t\n\u000b\f\r'`\"")
-	if xlen == 0 {
-		token := newToken(TYPE_VARIABLE, pos, 0, "")
-		state.tokenvec[state.current] = token
-		return pos
-	} else {
-		token := newToken(TYPE_VARIABLE, pos, xlen, s[pos:pos+xlen])
-		state.tokenvec[state.current] = token
-		return pos + xlen
-	}
+	token := newToken(TYPE_VARIABLE, pos, xlen, s[pos:pos+xlen])
+	token.count = count
+	state.tokenvec[state.current] = token
+	return pos + xlen
 }
 
 func (sqli *Sqli) parse_tick() int {
@@ -372,16 +383,18 @@ func (sqli *Sqli) parse_word() int {
 	/*
 	 * do normal lookup with word including '.'
 	 */
-	wordtype = libinjection_sqli_lookup_word(token.val)
-	/*
-	 * before, we differentiated fingerprint lookups from word lookups
-	 * by adding a 0 to the front for fingerprint lookups.
-	 * now, just check if word we found was a fingerprint
-	 */
-	if wordtype == 0 || wordtype == 'F' {
-		wordtype = TYPE_BAREWORD
+	if wlen < LIBINJECTION_SQLI_TOKEN_SIZE {
+		wordtype = libinjection_sqli_lookup_word(token.val)
+		/*
+		 * before, we differentiated fingerprint lookups from word lookups
+		 * by adding a 0 to the front for fingerprint lookups.
+		 * now, just check if word we found was a fingerprint
+		 */
+		if wordtype == 0 || wordtype == 'F' {
+			wordtype = TYPE_BAREWORD
+		}
+		state.tokenvec[state.current].Type = wordtype
 	}
-	state.tokenvec[state.current].Type = wordtype
 
 	return pos + wlen
 }
@@ -402,9 +415,9 @@ func (sqli *Sqli) parse_bword() int {
 		state.tokenvec[state.current] = token
 		return state.slen
 	} else {
-		token := newToken(TYPE_BAREWORD, pos, endptr+1-pos, s[pos:endptr+1])
+		token := newToken(TYPE_BAREWORD, pos, endptr+1, s[pos:pos+endptr+1])
 		state.tokenvec[state.current] = token
-		return endptr + 1
+		return pos + endptr + 1
 	}
 }
 
@@ -514,9 +527,10 @@ func (sqli *Sqli) parse_qstring_core(offset int) int {
 	ch = s[pos+2]
 
 	/*
-	 * the ch > 127 is un-needed since we assume char is signed
+	 * the C version assumes char is signed, so anything above 127 is
+	 * rejected as well
 	 */
-	if ch < 33 /* || ch > 127 */ {
+	if ch < 33 || ch > 127 {
 		return sqli.parse_word()
 	}
 	switch ch {
@@ -545,11 +559,11 @@ func (sqli *Sqli) parse_qstring_core(offset int) int {
 		state.tokenvec[state.current] = token
 		return slen
 	} else {
-		token := newToken(TYPE_STRING, pos+3, found-pos-3, s[pos+3:found])
+		token := newToken(TYPE_STRING, pos+3, found, s[pos+3:pos+3+found])
 		token.str_open = 'q'
 		token.str_close = 'q'
 		state.tokenvec[state.current] = token
-		return found + 2 /* +2 to skip over )' or ]' or }' or >' */
+		return pos + 3 + found + 2 /* +2 to skip over )' or ]' or }' or >' */
 	}
 
 }
@@ -612,7 +626,7 @@ func (sqli *Sqli) parse_string_core(delim byte, offset int) int {
 	s := state.s
 	slen := state.slen
 	pos := state.pos
-	qpos := strings.IndexByte(s[pos+offset:], delim) /* offset to skip first quote */
+	qpos := index_byte_from(s, pos+offset, delim) /* offset to skip first quote */
 	/* real quote if offset > 0, simulated quote if not */
 	str_open := byte(0x00)
 	if offset > 0 {
@@ -628,10 +642,10 @@ func (sqli *Sqli) parse_string_core(delim byte, offset int) int {
 			state.tokenvec[state.current] = token
 			return slen
 		} else if is_backslash_escaped(qpos-1, pos+offset, s) {
-			qpos = strings.IndexByte(s[qpos+1:], delim)
+			qpos = index_byte_from(s, qpos+1, delim)
 			continue
 		} else if is_double_delim_escaped(qpos, slen, s) {
-			qpos = strings.IndexByte(s[qpos+2:], delim)
+			qpos = index_byte_from(s, qpos+2, delim)
 			continue
 		} else {
 			/* quote is closed: it's a normal string */
@@ -723,9 +737,8 @@ func (sqli *Sqli) parse_slash() int {
 
 	if !closed {
 		clen = slen - pos
-		cend = slen - 2
 	} else {
-		clen = (cend + 2) - pos
+		clen = 2 + cend + 2
 	}
 
 	/*
@@ -736,13 +749,13 @@ func (sqli *Sqli) parse_slash() int {
 	 * Also, Mysql's "conditional" comments for version are an automatic
 	 * black ban!
 	 */
-	if closed && strings.Contains(s[pos+2:cend+2], "/*") {
+	if closed && strings.Contains(s[pos+2:pos+2+cend+1], "/*") {
 		ctype = TYPE_EVIL
 	} else if is_mysql_comment(s, slen, pos) {
 		ctype = TYPE_EVIL
 	}
 
-	token := newToken(int(ctype), pos, clen, s[pos:cend+2])
+	token := newToken(int(ctype), pos, clen, s[pos:pos+clen])
 	state.tokenvec[state.current] = token
 	return pos + clen
 }
@@ -808,9 +821,9 @@ func (sqli *Sqli) parse_eol_comment() int {
 		 * tokenize from pos to endpos - 1.
 		 * example: if "abc--\n" then tokenize "--"
 		 */
-		token := newToken(TYPE_COMMENT, pos, endpos-pos, s[pos:endpos])
+		token := newToken(TYPE_COMMENT, pos, endpos, s[pos:pos+endpos])
 		state.tokenvec[state.current] = token
-		return endpos + 1
+		return pos + endpos + 1
 	}
 }
 
@@ -1762,12 +1775,6 @@ func (sqli *Sqli) libinjection_sqli_fold() (int, error) {
 			pos -= 1
 			state.stats_folds += 1
 			continue
-		} else if state.tokenvec[left].Type == TYPE_SEMICOLON &&
-			state.tokenvec[left+1].Type == TYPE_FUNCTION &&
-			strings.EqualFold(state.tokenvec[left+1].val, "IF") {
-			state.tokenvec[left+1].Type = TYPE_TSQL
-			left += 2
-			continue /*reparse everything. but we probably can advance left, and pos */
 		} else if (state.tokenvec[left].Type == TYPE_OPERATOR || state.tokenvec[left].Type == TYPE_LOGIC_OPERATOR) &&
 			(state.tokenvec[left+1].is_unary_op() || state.tokenvec[left+1].Type == TYPE_SQLTYPE) {
 			pos -= 1
@@ -1781,13 +1788,25 @@ func (sqli *Sqli) libinjection_sqli_fold() (int, error) {
 				left -= 1
 			}
 			continue
-		} else if state.tokenvec[left].syntax_merge_words(sqli, left, state.tokenvec[left+1], left+1) {
+		} else if state.tokenvec[left].syntax_merge_words(&state.tokenvec[left+1]) {
 			pos -= 1
 			state.stats_folds += 1
 			if left > 0 {
 				left -= 1
 			}
 			continue
+		} else if state.tokenvec[left].Type == TYPE_SEMICOLON &&
+			state.tokenvec[left+1].Type == TYPE_FUNCTION &&
+			len(state.tokenvec[left+1].val) >= 2 &&
+			strings.EqualFold(state.tokenvec[left+1].val[:2], "IF") {
+			/*
+			 * IF is normally a function, except in Transact-SQL where it can
+			 * be used as a standalone control flow operator, e.g. ; IF 1=1 ...
+			 * if found after a semicolon, convert from 'f' type to 'T' type
+			 */
+			state.tokenvec[left+1].Type = TYPE_TSQL
+			/* left += 2 */
+			continue /*reparse everything. but we probably can advance left, and pos */
 			/* ELSE two token handling. */
 		} else if (state.tokenvec[left].Type == TYPE_BAREWORD || state.tokenvec[left].Type == TYPE_VARIABLE) &&
 			state.tokenvec[left+1].Type == TYPE_LEFTPARENS &&
@@ -2202,7 +2221,7 @@ func (sqli *Sqli) libinjection_sqli_not_whitelist() bool {
 			/*
 			 * if 'comment' is '#' ignore.. too many FP
 			 */
-			if state.tokenvec[1].val[0] == '#' {
+			if state.tokenvec[1].val_at(0) == '#' {
 				return false
 			}
 
@@ -2212,7 +2231,7 @@ func (sqli *Sqli) libinjection_sqli_not_whitelist() bool {
 			 */
 			if state.tokenvec[0].Type == TYPE_BAREWORD &&
 				state.tokenvec[1].Type == TYPE_COMMENT &&
-				state.tokenvec[1].val[0] != '/' {
+				state.tokenvec[1].val_at(0) != '/' {
 				return false
 			}
 
@@ -2221,7 +2240,7 @@ func (sqli *Sqli) libinjection_sqli_not_whitelist() bool {
 			 */
 			if state.tokenvec[0].Type == TYPE_NUMBER &&
 				state.tokenvec[1].Type == TYPE_COMMENT &&
-				state.tokenvec[1].val[0] == '/' {
+				state.tokenvec[1].val_at(0) == '/' {
 				return true
 			}
 
@@ -2247,7 +2266,7 @@ func (sqli *Sqli) libinjection_sqli_not_whitelist() bool {
 				 * we check that next character after the number is either whitespace,
 				 * or '/' or a '-' ==> SQLi.
 				 */
-				ch = state.s[state.tokenvec[0].Len]
+				ch = char_at(state.s, state.tokenvec[0].Len)
 				if ch <= 32 {
 					/* next char was whitespace,e.g. "1234 --"
 					 * this isn't exactly correct.. ideally we should skip over all whitespace
@@ -2255,10 +2274,10 @@ func (sqli *Sqli) libinjection_sqli_not_whitelist() bool {
 					 */
 					return true
 				}
-				if ch == '/' && state.s[state.tokenvec[0].Len+1] == '*' {
+				if ch == '/' && char_at(state.s, state.tokenvec[0].Len+1) == '*' {
 					return true
 				}
-				if ch == '-' && state.s[state.tokenvec[0].Len+1] == '-' {
+				if ch == '-' && char_at(state.s, state.tokenvec[0].Len+1) == '-' {
 					return true
 				}
 
@@ -2270,7 +2289,7 @@ func (sqli *Sqli) libinjection_sqli_not_whitelist() bool {
 			 * so only detect if input ends with '--', e.g. 1-- but not 1-- foo
 			 */
 			if (state.tokenvec[1].Len > 2) &&
-				state.tokenvec[1].val[0] == '-' {
+				state.tokenvec[1].val_at(0) == '-' {
 				return false
 			}
 
@@ -2316,9 +2335,8 @@ func (sqli *Sqli) libinjection_sqli_not_whitelist() bool {
 					return false
 				}
 			} else if state.tokenvec[1].Type == TYPE_KEYWORD {
-				keyword := strings.ToUpper(state.tokenvec[1].val)
 				if (state.tokenvec[1].Len < 5) ||
-					!(keyword == "INTO OUTFILE" || keyword == "INTO DUMPFILE") {
+					!strings.EqualFold(state.tokenvec[1].val[:4], "INTO") {
 					/*
 					 * if it's not "INTO OUTFILE", or "INTO DUMPFILE" (MySQL)
 					 * then treat as safe
@@ -2334,7 +2352,7 @@ func (sqli *Sqli) libinjection_sqli_not_whitelist() bool {
 			if state.fingerprint == "novc" || state.fingerprint == "1ovc" {
 				if state.tokenvec[1].val == "!" &&
 					state.tokenvec[2].Len == 0 &&
-					state.tokenvec[3].val[0] == '#' {
+					state.tokenvec[3].val_at(0) == '#' {
 					/*
 					 * case where user enters !@# in password
 					 */
@@ -2353,17 +2371,19 @@ func (sqli *Sqli) libinjection_sqli_not_whitelist() bool {
 	return true
 }
 
+/*
+ * Fingerprints are stored in the keyword table upper cased and prefixed
+ * with a '0', so they do not collide with regular words.
+ */
 func (sqli *Sqli) is_keyword(str string) bool {
-	value := sql_keywords[strings.ToUpper(str)]
-
-	if value == 0 || value != TYPE_FINGERPRINT {
-		return false
-	}
-	return true
+	return sql_keywords["0"+strings.ToUpper(str)] == TYPE_FINGERPRINT
 }
 
 func (sqli *Sqli) libinjection_sqli_check_fingerprint() bool {
-	return sqli.libinjection_sqli_blacklist() && sqli.libinjection_sqli_not_whitelist()
+	if !sqli.libinjection_sqli_blacklist() || !sqli.libinjection_sqli_not_whitelist() {
+		return false
+	}
+	return sqli.allow == nil || !sqli.allow(sqli.state.fingerprint)
 }
 
 func (sqli *Sqli) libinjection_sqli_blacklist() bool {
@@ -2395,6 +2415,7 @@ func (sqli *Sqli) libinjection_sqli_fingerprint(flags int) (string, error) {
 	 * - double quote mode
 	 */
 	state := newState(sqli.state.s, sqli.state.slen, flags)
+	sqli.state = state
 
 	/* get fingerprint */
 	fplen, err := sqli.libinjection_sqli_fold()
@@ -2428,78 +2449,208 @@ func (sqli *Sqli) libinjection_sqli_fingerprint(flags int) (string, error) {
 	 * false positive
 	 */
 	if strings.IndexByte(state.fingerprint, TYPE_EVIL) != -1 {
-		state.fingerprint = "X"
-		token := newToken(TYPE_EVIL, 0, 0, string(TYPE_EVIL))
-		replace := [8]*Token{token, nil, nil, nil, nil, nil, nil, nil}
-		state.tokenvec = replace
+		state.fingerprint = string(TYPE_EVIL)
+		state.tokenvec[0].Type = TYPE_EVIL
+		state.tokenvec[0].val = string(TYPE_EVIL)
+		state.tokenvec[0].Len = 1
+		state.tokenvec[1].Type = CHAR_NULL
 	}
 
 	return state.fingerprint, nil
 }
 
+/*
+ * sqliPassFlags are the contexts libinjection_is_sqli can try input in,
+ * in order.
+ */
+var sqliPassFlags = [...]int{
+	FLAG_QUOTE_NONE | FLAG_SQL_ANSI,
+	FLAG_QUOTE_NONE | FLAG_SQL_MYSQL,
+	FLAG_QUOTE_SINGLE | FLAG_SQL_ANSI,
+	FLAG_QUOTE_SINGLE | FLAG_SQL_MYSQL,
+	FLAG_QUOTE_DOUBLE | FLAG_SQL_ANSI,
+	FLAG_QUOTE_DOUBLE | FLAG_SQL_MYSQL,
+}
+
+/*
+ * passes fingerprints the input under sqliPassFlags, in order, and calls
+ * fn after each pass with whether libinjection_is_sqli tries it, until fn
+ * returns false. Unless all is set, the passes not tried are skipped:
+ *
+ * - the input as is, then as if it started with a single quote when it
+ *   has one, e.g. admin' OR 1=1-- is tested as 'admin' OR 1=1--
+ * - each of these again as MySQL when reparse_as_mysql says so
+ * - as if it started with a double quote, as MySQL only
+ */
+func (sqli *Sqli) passes(all bool, fn func(flags int, tried bool) bool) {
+	s := sqli.state.s
+	tried, reparse := false, false
+	for _, flags := range sqliPassFlags {
+		switch flags {
+		case FLAG_QUOTE_NONE | FLAG_SQL_ANSI:
+			tried = true
+		case FLAG_QUOTE_SINGLE | FLAG_SQL_ANSI:
+			tried = strings.Contains(s, "'")
+		case FLAG_QUOTE_DOUBLE | FLAG_SQL_ANSI:
+			tried = false
+		case FLAG_QUOTE_DOUBLE | FLAG_SQL_MYSQL:
+			tried = strings.Contains(s, "\"")
+		default:
+			/* reparse of the ANSI pass before */
+			tried = tried && reparse
+		}
+		if !tried && !all {
+			continue
+		}
+		sqli.libinjection_sqli_fingerprint(flags)
+		reparse = sqli.reparse_as_mysql()
+		if !fn(flags, tried) {
+			return
+		}
+	}
+}
+
 func (sqli *Sqli) libinjection_is_sqli() bool {
 	state := sqli.state
-	s := state.s
-	slen := state.slen
-	sqlifingerprint := false
 
-	if slen == 0 {
+	if state.slen == 0 {
 		state.fingerprint = ""
 		return false
 	}
 
-	/* test input as-is */
-	sqli.libinjection_sqli_fingerprint(FLAG_QUOTE_NONE | FLAG_SQL_ANSI)
-	sqlifingerprint = sqli.libinjection_sqli_check_fingerprint()
-	if sqlifingerprint {
-		return true
-	} else if sqli.reparse_as_mysql() {
-		sqli.libinjection_sqli_fingerprint(FLAG_QUOTE_NONE | FLAG_SQL_MYSQL)
+	sqlifingerprint := false
+	sqli.passes(false, func(flags int, tried bool) bool {
 		sqlifingerprint = sqli.libinjection_sqli_check_fingerprint()
-		if sqlifingerprint {
-			return true
-		}
+		return !sqlifingerprint
+	})
+	return sqlifingerprint
+}
+
+func (sqli *Sqli) libinjection_sqli(input string) (bool, string) {
+	sqli.state = newState(input, len(input), 0)
+	issqli := sqli.libinjection_is_sqli()
+	if !issqli {
+		return false, ""
 	}
+	return issqli, sqli.state.fingerprint
+}
 
-	/*
-	 * if input contains single quote, pretend it starts with single quote
-	 * example: admin' OR 1=1--  is tested as  'admin' OR 1=1--
-	 */
-	if strings.Contains(s, "'") {
-		sqli.libinjection_sqli_fingerprint(FLAG_QUOTE_SINGLE | FLAG_SQL_ANSI)
-		sqlifingerprint = sqli.libinjection_sqli_check_fingerprint()
-		if sqlifingerprint {
-			return true
-		} else if sqli.reparse_as_mysql() {
-			sqli.libinjection_sqli_fingerprint(FLAG_QUOTE_SINGLE | FLAG_SQL_MYSQL)
-			sqlifingerprint = sqli.libinjection_sqli_check_fingerprint()
-			if sqlifingerprint {
-				return true
-			}
-		}
+func libinjection_sqli_lookup_word(str string) byte {
+	return sql_keywords[strings.ToUpper(str)]
+}
+
+/*
+ * IsSQLi is the main API: it returns true if input is SQLi along with the
+ * matching fingerprint. Like the C version, the fingerprint is empty when
+ * the input is benign.
+ */
+func IsSQLi(input string) (bool, string) {
+	sqli := &Sqli{}
+	return sqli.libinjection_sqli(input)
+}
+
+/*
+ * Tokenize returns every token of input, without folding. Unlike the
+ * fingerprint, which stops after LIBINJECTION_SQLI_MAX_TOKENS, the whole
+ * input is tokenized, so it can be used on complete statements. flags are
+ * the same as for fingerprinting, 0 means FLAG_QUOTE_NONE | FLAG_SQL_ANSI.
+ */
+func Tokenize(input string, flags int) []Token {
+	sqli := &Sqli{state: newState(input, len(input), flags)}
+	var tokens []Token
+	for sqli.libinjection_sqli_tokenize() {
+		tokens = append(tokens, sqli.state.tokenvec[sqli.state.current])
 	}
+	return tokens
+}
 
-	/*
-	 * same as above but with a double-quote "
-	 */
-	if strings.Contains(s, "\"") {
-		sqli.libinjection_sqli_fingerprint(FLAG_QUOTE_DOUBLE | FLAG_SQL_MYSQL)
-		sqlifingerprint = sqli.libinjection_sqli_check_fingerprint()
-		if sqlifingerprint {
-			return true
+/*
+ * Fold returns the folded tokens the fingerprint is made of, at most
+ * LIBINJECTION_SQLI_MAX_TOKENS. Unlike the fingerprint, the PHP backquote
+ * and 'X' adjustments are not applied. The error is the failed assertion
+ * of libinjection_sqli_fold.
+ */
+func Fold(input string, flags int) ([]Token, error) {
+	sqli := &Sqli{state: newState(input, len(input), flags)}
+	fplen, err := sqli.libinjection_sqli_fold()
+	if err != nil {
+		return nil, err
+	}
+	return append([]Token(nil), sqli.state.tokenvec[:fplen]...), nil
+}
+
+/*
+ * IsFingerprint tells if fingerprint is in the SQLi database, which is
+ * the blacklist check of libinjection_sqli_blacklist.
+ */
+func IsFingerprint(fingerprint string) bool {
+	return fingerprint != "" && sql_keywords["0"+strings.ToUpper(fingerprint)] == TYPE_FINGERPRINT
+}
+
+/*
+ * Fingerprints returns every fingerprint of the SQLi database, sorted.
+ */
+func Fingerprints() []string {
+	var fingerprints []string
+	for word, typ := range sql_keywords {
+		if typ == TYPE_FINGERPRINT {
+			fingerprints = append(fingerprints, word[1:])
 		}
 	}
+	sort.Strings(fingerprints)
+	return fingerprints
+}
 
-	/* Not SQLi! */
-	return false
+/*
+ * SQLiPass is the fingerprint of input in one context, whether or not it
+ * is in the database.
+ */
+type SQLiPass struct {
+	Flags       int
+	Fingerprint string
+	/*
+	 * libinjection_sqli_not_whitelist rejects the fingerprint, so it isn't
+	 * SQLi even if it is in the database.
+	 */
+	Whitelisted bool
+	/* libinjection_is_sqli runs this pass unless an earlier one matches */
+	Tried bool
 }
 
-func (sqli *Sqli) libinjection_sqli(input string) (bool, string) {
-	sqli.state = newState(input, len(input), 0)
-	issqli := sqli.libinjection_is_sqli()
-	return issqli, sqli.state.fingerprint
+/*
+ * SQLiPasses fingerprints input with libinjection_sqli_fingerprint under
+ * every flag combination, in the order libinjection_is_sqli tries them.
+ * Input is SQLi when one of the passes tried has a fingerprint in the
+ * database that isn't whitelisted.
+ */
+func SQLiPasses(input string) []SQLiPass {
+	return sqliPasses(input, nil)
 }
 
-func libinjection_sqli_lookup_word(str string) byte {
-	return sql_keywords[strings.ToUpper(str)]
+/*
+ * sqliPasses is SQLiPasses, calling fn with the state of every pass it
+ * fingerprints.
+ */
+func sqliPasses(input string, fn func(pass *SQLiPass, state *State)) []SQLiPass {
+	passes := make([]SQLiPass, len(sqliPassFlags))
+	for i, flags := range sqliPassFlags {
+		passes[i].Flags = flags
+	}
+	if len(input) == 0 {
+		return passes
+	}
+	sqli := &Sqli{state: newState(input, len(input), 0)}
+	i := 0
+	sqli.passes(true, func(flags int, tried bool) bool {
+		pass := &passes[i]
+		i++
+		pass.Fingerprint = sqli.state.fingerprint
+		pass.Whitelisted = !sqli.libinjection_sqli_not_whitelist()
+		pass.Tried = tried
+		if fn != nil {
+			fn(pass, sqli.state)
+		}
+		return true
+	})
+	return passes
 }
@@ -0,0 +1,393 @@
+package libinjection
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+/*
+ * Server-side request forgery: a URL given to a server to fetch, e.g. a
+ * webhook, that reaches the server's own network. URL parsers disagree,
+ * and the filter that checks a URL is rarely the client that fetches it,
+ * so the host is taken the way every common parser would take it:
+ *
+ *	http://evil.com\@127.0.0.1/   a backslash ends the host for browsers
+ *	                              but not for RFC 3986 parsers
+ *	http://127.0.0.1:80@evil.com  some take the host before the '@'
+ *	http://evil.com#@127.0.0.1    or after it, ignoring the fragment
+ *
+ * Every host found is then read as inet_aton(3) does, which takes 1 to 4
+ * parts in decimal, octal or hex, 2130706433 and 0x7f.1 are 127.0.0.1, as
+ * an IPv6 address with or without a zone, or as a hostname that embeds an
+ * address, like 127.0.0.1.nip.io.
+ *
+ * The fingerprint is the most dangerous class reached: SSRF_METADATA,
+ * SSRF_LOOPBACK, SSRF_LINK_LOCAL or SSRF_PRIVATE. The flags tell which
+ * quirk got there.
+ */
+
+const (
+	FLAG_SSRF_USERINFO  = 32768  /* 1 << 15, host taken from the userinfo */
+	FLAG_SSRF_BACKSLASH = 65536  /* 1 << 16, host ended by a backslash */
+	FLAG_SSRF_NUMERIC   = 131072 /* 1 << 17, IPv4 not in dotted decimal */
+	FLAG_SSRF_DNS       = 262144 /* 1 << 18, address in a hostname */
+
+	//ssrf fingerprints, most dangerous first
+	SSRF_METADATA   = "metadata"   /* cloud instance metadata */
+	SSRF_LOOPBACK   = "loopback"   /* also 0.0.0.0, which Linux routes to it */
+	SSRF_LINK_LOCAL = "link-local" /* 169.254.0.0/16, fe80::/10 */
+	SSRF_PRIVATE    = "private"    /* RFC 1918, RFC 6598 and fc00::/7 */
+)
+
+var ssrfRank = map[string]int{SSRF_METADATA: 4, SSRF_LOOPBACK: 3, SSRF_LINK_LOCAL: 2, SSRF_PRIVATE: 1}
+
+/*
+ * Addresses of instance metadata services: AWS, GCP and Azure, the AWS
+ * IPv6 endpoint, Alibaba Cloud, and ECS task metadata.
+ */
+var ssrfMetadata = map[string]bool{
+	"169.254.169.254": true,
+	"fd00:ec2::254":   true,
+	"100.100.100.200": true,
+	"169.254.170.2":   true,
+}
+
+/*
+ * Hostnames with a fixed meaning.
+ */
+var ssrfNames = map[string]string{
+	"localhost":                  SSRF_LOOPBACK,
+	"localhost.localdomain":      SSRF_LOOPBACK,
+	"ip6-localhost":              SSRF_LOOPBACK,
+	"ip6-loopback":               SSRF_LOOPBACK,
+	"metadata":                   SSRF_METADATA,
+	"metadata.google.internal":   SSRF_METADATA,
+	"instance-data":              SSRF_METADATA,
+	"instance-data.ec2.internal": SSRF_METADATA,
+	/* public names that resolve to 127.0.0.1 */
+	"localtest.me": SSRF_LOOPBACK,
+	"lvh.me":       SSRF_LOOPBACK,
+	"vcap.me":      SSRF_LOOPBACK,
+}
+
+/*
+ * Wildcard DNS services, a.b.c.d.nip.io, a-b-c-d.nip.io and the hex form
+ * 7f000001.nip.io all resolve to the address.
+ */
+var ssrfWildcardDNS = []string{".nip.io", ".sslip.io", ".xip.io"}
+
+var (
+	ssrfCGNAT = ssrfNetwork("100.64.0.0/10")
+	ssrfThis  = ssrfNetwork("0.0.0.0/8")
+	ssrfNAT64 = ssrfNetwork("64:ff9b::/96")
+
+	/* RFC 1918 and fc00::/7, net.IP.IsPrivate needs go1.17 */
+	ssrfPrivate = []*net.IPNet{
+		ssrfNetwork("10.0.0.0/8"),
+		ssrfNetwork("172.16.0.0/12"),
+		ssrfNetwork("192.168.0.0/16"),
+		ssrfNetwork("fc00::/7"),
+	}
+)
+
+func ssrfNetwork(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+/*
+ * ssrfParseIP parses an IPv6 address, with or without a zone, or an IPv4
+ * address in strict dotted decimal: net.ParseIP takes leading zeros
+ * before go1.17 and not after.
+ */
+func ssrfParseIP(s string) net.IP {
+	if strings.IndexByte(s, ':') != -1 {
+		if i := strings.IndexByte(s, '%'); i != -1 {
+			s = s[:i]
+		}
+		return net.ParseIP(s)
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) != 4 {
+		return nil
+	}
+	ip := make(net.IP, 4)
+	for i, part := range parts {
+		if part == "" || len(part) > 3 || len(part) > 1 && part[0] == '0' {
+			return nil
+		}
+		n, err := strconv.ParseUint(part, 10, 8)
+		if err != nil {
+			return nil
+		}
+		ip[i] = byte(n)
+	}
+	return ip
+}
+
+type ssrfHost struct {
+	host  string
+	flags int
+}
+
+/*
+ * ssrfHosts returns the hosts parsers may take from a URL, with the
+ * quirks that lead to each.
+ */
+func ssrfHosts(input string) []ssrfHost {
+	/* browsers drop tabs and newlines anywhere, and spaces around */
+	s := strings.NewReplacer("\t", "", "\n", "", "\r", "").Replace(input)
+	s = strings.TrimSpace(s)
+
+	rest := s
+	if i := strings.IndexByte(s, ':'); i > 0 && ssrfScheme(s[:i]) && !ssrfPort(s[i+1:]) {
+		rest = s[i+1:]
+	}
+	flags := 0
+	start := 0
+	for start < len(rest) && (rest[start] == '/' || rest[start] == '\\') {
+		if rest[start] == '\\' {
+			flags |= FLAG_SSRF_BACKSLASH
+		}
+		start++
+	}
+	rest = rest[start:]
+
+	var hosts []ssrfHost
+	seen := map[ssrfHost]bool{}
+	/* RFC 3986, WHATWG with backslashes, and parsers that ignore ? and # */
+	for _, delims := range []string{"/?#", "/?#\\", "/\\"} {
+		authority, f := rest, flags
+		if end := strings.IndexAny(rest, delims); end != -1 {
+			authority = rest[:end]
+			if rest[end] == '\\' {
+				f |= FLAG_SSRF_BACKSLASH
+			}
+		}
+		if strings.IndexByte(authority, '\\') != -1 {
+			f |= FLAG_SSRF_BACKSLASH
+		}
+		pieces := strings.Split(authority, "@")
+		for i, piece := range pieces {
+			host := ssrfHost{ssrfHostname(piece), f}
+			if i < len(pieces)-1 {
+				host.flags |= FLAG_SSRF_USERINFO
+			}
+			if host.host != "" && !seen[host] {
+				seen[host] = true
+				hosts = append(hosts, host)
+			}
+		}
+	}
+	return hosts
+}
+
+func ssrfScheme(scheme string) bool {
+	for i := 0; i < len(scheme); i++ {
+		c := scheme[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' ||
+			i > 0 && (c >= '0' && c <= '9' || c == '+' || c == '-' || c == '.')) {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+ * ssrfPort tells if s, what follows the first ':', starts with a port,
+ * as in localhost:8080, rather than being the rest of a URL after its
+ * scheme.
+ */
+func ssrfPort(s string) bool {
+	end := strings.IndexAny(s, "/?#\\")
+	if end == -1 {
+		end = len(s)
+	}
+	_, err := strconv.ParseUint(s[:end], 10, 16)
+	return end > 0 && err == nil
+}
+
+/*
+ * ssrfHostname strips the port of an authority piece, decodes it and
+ * lower cases it.
+ */
+func ssrfHostname(piece string) string {
+	host := piece
+	if strings.HasPrefix(host, "[") {
+		host = host[1:]
+		if i := strings.IndexByte(host, ']'); i != -1 {
+			host = host[:i]
+		}
+	} else if strings.Count(host, ":") == 1 {
+		host = host[:strings.IndexByte(host, ':')]
+	}
+	host, _ = traversalUnescape(host)
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+/*
+ * ssrfInetAton parses an IPv4 address as inet_aton(3): 1 to 4 parts, in
+ * decimal, octal with a leading 0 or hex with 0x, the last part filling
+ * the bytes left.
+ */
+func ssrfInetAton(host string) (net.IP, bool) {
+	parts := strings.Split(host, ".")
+	if len(parts) > 4 {
+		return nil, false
+	}
+	var ip uint32
+	for i, part := range parts {
+		base, digits := 10, part
+		switch {
+		case strings.HasPrefix(part, "0x"):
+			base, digits = 16, part[2:]
+		case len(part) > 1 && part[0] == '0':
+			base, digits = 8, part[1:]
+		}
+		n, err := strconv.ParseUint(digits, base, 32)
+		if err != nil {
+			return nil, false
+		}
+		if i < len(parts)-1 {
+			if n > 0xff {
+				return nil, false
+			}
+			ip |= uint32(n) << (8 * (3 - i))
+			continue
+		}
+		if bits := 8 * (4 - i); bits < 32 && n >= 1<<bits {
+			return nil, false
+		}
+		ip |= uint32(n)
+	}
+	return net.IPv4(byte(ip>>24), byte(ip>>16), byte(ip>>8), byte(ip)), true
+}
+
+/*
+ * ssrfEmbedded returns the address a hostname embeds: four labels in a
+ * row that are an IPv4 address, or for wildcard DNS services, a label
+ * with the address in dashes or hex.
+ */
+func ssrfEmbedded(host string) (net.IP, bool) {
+	labels := strings.Split(host, ".")
+	for i := 0; i+4 <= len(labels); i++ {
+		if addr := ssrfParseIP(strings.Join(labels[i:i+4], ".")); addr != nil {
+			return addr, true
+		}
+	}
+	for _, suffix := range ssrfWildcardDNS {
+		if !strings.HasSuffix(host, suffix) {
+			continue
+		}
+		for _, label := range labels {
+			/* a prefix is allowed, app-10-0-0-1.nip.io */
+			dashes := strings.Split(label, "-")
+			if len(dashes) >= 4 {
+				if addr := ssrfParseIP(strings.Join(dashes[len(dashes)-4:], ".")); addr != nil {
+					return addr, true
+				}
+			}
+			if len(label) == 8 {
+				if n, err := strconv.ParseUint(label, 16, 32); err == nil {
+					return net.IPv4(byte(n>>24), byte(n>>16), byte(n>>8), byte(n)), true
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+/*
+ * ssrfClass returns the class of an address, "" for public ones.
+ */
+func ssrfClass(addr net.IP) string {
+	if v4 := addr.To4(); v4 != nil {
+		addr = v4
+	} else if ssrfNAT64.Contains(addr) {
+		addr = addr[12:16]
+	}
+	switch {
+	case ssrfMetadata[addr.String()]:
+		return SSRF_METADATA
+	case addr.IsLoopback(), addr.IsUnspecified(), ssrfThis.Contains(addr):
+		return SSRF_LOOPBACK
+	case addr.IsLinkLocalUnicast(), addr.IsLinkLocalMulticast():
+		return SSRF_LINK_LOCAL
+	case ssrfCGNAT.Contains(addr):
+		return SSRF_PRIVATE
+	}
+	for _, network := range ssrfPrivate {
+		if network.Contains(addr) {
+			return SSRF_PRIVATE
+		}
+	}
+	return ""
+}
+
+/*
+ * ssrfClassify returns the most dangerous class a host can reach, and
+ * the quirks that get there.
+ */
+func ssrfClassify(host string) (string, int) {
+	if class, ok := ssrfNames[host]; ok {
+		return class, 0
+	}
+	if strings.HasSuffix(host, ".localhost") {
+		return SSRF_LOOPBACK, 0
+	}
+	if addr := ssrfParseIP(host); addr != nil {
+		return ssrfClass(addr), 0
+	}
+	class, numeric := "", false
+	if addr, ok := ssrfInetAton(host); ok {
+		class, numeric = ssrfClass(addr), true
+	}
+	/* 0127.0.0.1 is octal for inet_aton, decimal for others */
+	if parts := strings.Split(host, "."); len(parts) == 4 {
+		for i := range parts {
+			if parts[i] = strings.TrimLeft(parts[i], "0"); parts[i] == "" {
+				parts[i] = "0"
+			}
+		}
+		if addr := ssrfParseIP(strings.Join(parts, ".")); addr != nil {
+			if numeric = true; ssrfRank[ssrfClass(addr)] > ssrfRank[class] {
+				class = ssrfClass(addr)
+			}
+		}
+	}
+	if numeric {
+		return class, FLAG_SSRF_NUMERIC
+	}
+	if addr, ok := ssrfEmbedded(host); ok {
+		return ssrfClass(addr), FLAG_SSRF_DNS
+	}
+	return "", 0
+}
+
+/*
+ * DetectSSRF checks input, a URL a server is asked to fetch, for hosts
+ * that reach internal addresses.
+ */
+func DetectSSRF(input string) Result {
+	var result Result
+	for _, host := range ssrfHosts(input) {
+		class, flags := ssrfClassify(host.host)
+		if ssrfRank[class] > ssrfRank[result.Fingerprint] {
+			result = Result{Injection: true, Fingerprint: class, Flags: host.flags | flags}
+		}
+	}
+	return result
+}
+
+/*
+ * IsSSRF tells if input is a URL reaching an internal address, with the
+ * class of the address.
+ */
+func IsSSRF(input string) (bool, string) {
+	result := DetectSSRF(input)
+	return result.Injection, result.Fingerprint
+}
@@ -0,0 +1,422 @@
+package libinjection
+
+import (
+	"strings"
+)
+
+/*
+ * Server-side template injection: template expressions in input that is
+ * rendered as a template, or pasted into one. Every expression delimiter
+ * of the common engines is tokenized, and the expression is an injection
+ * when it does more than name a variable: it reaches an attribute, calls
+ * something or computes, as in {{7*7}} or
+ * {{''.__class__.__mro__[1].__subclasses__()}}, or when it names one of
+ * the globals Jinja2 and Twig give every template, as in {{config}}. Bare
+ * Velocity references have no delimiters, they are only an injection when
+ * they call a method, as in $x.getClass().forName('java.lang.Runtime').
+ *
+ * The fingerprint is the delimiter followed by the tokens up to the first
+ * that makes the expression dangerous, at most SSTI_MAX_TOKENS in all.
+ */
+
+const (
+	SSTI_MAX_TOKENS = 5
+
+	//ssti delimiter types, the first byte of a fingerprint
+	SSTI_DELIM_MUSTACHE   = '{' /* {{ }}, Jinja2, Twig, Go text/template, Handlebars */
+	SSTI_DELIM_STATEMENT  = '%' /* {% %}, Jinja2, Twig */
+	SSTI_DELIM_DOLLAR     = '$' /* ${ }, FreeMarker, Spring EL, JSP EL */
+	SSTI_DELIM_HASH       = '#' /* #{ }, Ruby, JSF, Thymeleaf messages */
+	SSTI_DELIM_SELECTION  = '*' /* *{ }, Thymeleaf */
+	SSTI_DELIM_ERB        = 'r' /* <% %> and <%= %>, ERB, JSP */
+	SSTI_DELIM_FREEMARKER = 'f' /* <# >, FreeMarker directives */
+	SSTI_DELIM_VELOCITY   = 'v' /* #set( ), Velocity */
+	SSTI_DELIM_REFERENCE  = 'V' /* $x.y(), $!x.y(), bare Velocity references */
+
+	//ssti token types
+	SSTI_TYPE_NAME     = 'n'
+	SSTI_TYPE_GLOBAL   = 'g' /* config, self, _self, request, ... */
+	SSTI_TYPE_KEYWORD  = 'k' /* if, for, in, and, ... */
+	SSTI_TYPE_NUMBER   = '1'
+	SSTI_TYPE_STRING   = 's'
+	SSTI_TYPE_DOT      = '.' /* also FreeMarker built-ins, x?new */
+	SSTI_TYPE_CALL     = '('
+	SSTI_TYPE_CLOSE    = ')'
+	SSTI_TYPE_INDEX    = '['
+	SSTI_TYPE_INDEXEND = ']'
+	SSTI_TYPE_OPERATOR = 'o' /* arithmetic: + - * / % ~ ** */
+	SSTI_TYPE_OTHER    = 'x' /* comparisons, filters, commas, ... */
+)
+
+type sstiDelimiter struct {
+	open, close string
+	typ         byte
+}
+
+/*
+ * Longer openers first, "<%=" before "<%".
+ */
+var sstiDelimiters = []sstiDelimiter{
+	{"{{", "}}", SSTI_DELIM_MUSTACHE},
+	{"{%", "%}", SSTI_DELIM_STATEMENT},
+	{"${", "}", SSTI_DELIM_DOLLAR},
+	{"#{", "}", SSTI_DELIM_HASH},
+	{"*{", "}", SSTI_DELIM_SELECTION},
+	{"<%=", "%>", SSTI_DELIM_ERB},
+	{"<%", "%>", SSTI_DELIM_ERB},
+	{"<#", ">", SSTI_DELIM_FREEMARKER},
+	{"#set", ")", SSTI_DELIM_VELOCITY},
+}
+
+/*
+ * Keywords of the engines, they don't name a variable.
+ */
+var sstiKeywords = map[string]bool{
+	"and": true, "or": true, "not": true, "in": true, "is": true,
+	"if": true, "else": true, "elif": true, "elsif": true, "endif": true, "end": true,
+	"for": true, "endfor": true, "range": true, "with": true, "endwith": true,
+	"set": true, "endset": true, "block": true, "endblock": true, "define": true,
+	"extends": true, "include": true, "import": true, "from": true, "as": true,
+	"template": true, "macro": true, "endmacro": true, "raw": true, "endraw": true,
+	"true": true, "false": true, "nil": true, "none": true, "null": true,
+}
+
+/*
+ * Globals of Jinja2, Flask and Twig, that print the application's
+ * configuration or lead to its internals with no attribute access in the
+ * input.
+ */
+var sstiGlobals = map[string]bool{
+	"config": true, "request": true, "self": true, "lipsum": true, "cycler": true,
+	"joiner": true, "namespace": true, "url_for": true, "get_flashed_messages": true,
+	"_self": true, "_context": true,
+}
+
+func isSSTIName(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '_' || c == '$' || c == '@'
+}
+
+/*
+ * sstiMemo keeps what scanning to the end of the input found, so that
+ * later expressions don't scan it again: quotes with no closing quote
+ * after them, closers after which brackets never balance and closers not
+ * found at all.
+ */
+type sstiMemo struct {
+	unterminated [256]int /* 1 + where a string with that quote didn't end */
+	unbalanced   map[string]bool
+	unclosed     map[string]bool
+	budget       int /* bytes left to scan for closers only inside strings */
+}
+
+func newSSTIMemo(input string) *sstiMemo {
+	return &sstiMemo{
+		unbalanced: map[string]bool{},
+		unclosed:   map[string]bool{},
+		budget:     8*len(input) + 1024,
+	}
+}
+
+/*
+ * tokenize is sstiTokenize, for closers not known to be missing. Closers
+ * only found inside strings don't count as missing, an opener in a string
+ * can still start an expression, but past the budget they do, so that
+ * openers don't each scan the rest of the input.
+ */
+func (memo *sstiMemo) tokenize(s string, pos int, close string) ([]byte, int) {
+	if memo.unclosed[close] {
+		return nil, -1
+	}
+	tokens, end := sstiTokenize(s, pos, close, memo)
+	if end == -1 {
+		memo.budget -= 2 * (len(s) - pos)
+		if memo.budget < 0 || !strings.Contains(s[pos:], close) {
+			memo.unclosed[close] = true
+		}
+	}
+	return tokens, end
+}
+
+/*
+ * sstiTokenize reads the expression at s[pos:] up to close, outside of
+ * strings and brackets. It returns the tokens and the end of the closing
+ * delimiter, or -1 if there is none. An unterminated string or unbalanced
+ * brackets end the expression at the first closer, as engines that print
+ * invalid expressions verbatim go on with the next one.
+ */
+func sstiTokenize(s string, pos int, close string, memo *sstiMemo) ([]byte, int) {
+	var tokens []byte
+	depth := 0
+	/* the first closer inside brackets, where they end if they never balance */
+	firstClose, firstTokens := -1, 0
+	for pos < len(s) {
+		if strings.HasPrefix(s[pos:], close) {
+			if depth == 0 || memo.unbalanced[close] {
+				return tokens, pos + len(close)
+			}
+			if firstClose == -1 {
+				firstClose, firstTokens = pos, len(tokens)
+			}
+		}
+		c := s[pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			pos++
+			continue
+		case c == '\'' || c == '"' || c == '`':
+			/* a later quote was escaped in the earlier string, it can't end either */
+			end := len(s)
+			if from := memo.unterminated[c]; from == 0 || pos < from-1 {
+				end = pos + 1
+				for end < len(s) && s[end] != c {
+					if s[end] == '\\' {
+						end++
+					}
+					end++
+				}
+				if end >= len(s) {
+					memo.unterminated[c] = pos + 1
+				}
+			}
+			tokens = append(tokens, SSTI_TYPE_STRING)
+			if end >= len(s) {
+				if i := strings.Index(s[pos:], close); i != -1 {
+					return tokens, pos + i + len(close)
+				}
+				return tokens, -1
+			}
+			pos = end + 1
+			continue
+		case c >= '0' && c <= '9':
+			for pos < len(s) && (s[pos] >= '0' && s[pos] <= '9' ||
+				s[pos] == '.' && pos+1 < len(s) && s[pos+1] >= '0' && s[pos+1] <= '9') {
+				pos++
+			}
+			tokens = append(tokens, SSTI_TYPE_NUMBER)
+			continue
+		case isSSTIName(c):
+			start := pos
+			for pos < len(s) && isSSTIName(s[pos]) {
+				pos++
+			}
+			switch {
+			case sstiKeywords[strings.ToLower(s[start:pos])]:
+				tokens = append(tokens, SSTI_TYPE_KEYWORD)
+			case sstiGlobals[s[start:pos]]:
+				tokens = append(tokens, SSTI_TYPE_GLOBAL)
+			default:
+				tokens = append(tokens, SSTI_TYPE_NAME)
+			}
+			continue
+		case c == '.':
+			tokens = append(tokens, SSTI_TYPE_DOT)
+		case c == '?' && pos+1 < len(s) && isSSTIName(s[pos+1]):
+			tokens = append(tokens, SSTI_TYPE_DOT)
+		case c == '(' || c == '[':
+			depth++
+			tokens = append(tokens, c)
+		case c == '{':
+			depth++
+			tokens = append(tokens, SSTI_TYPE_OTHER)
+		case c == ')' || c == ']' || c == '}':
+			if depth > 0 {
+				depth--
+			}
+			if c == '}' {
+				c = SSTI_TYPE_OTHER
+			}
+			tokens = append(tokens, c)
+		case strings.IndexByte("+-*/%~", c) != -1:
+			if c == '*' && pos+1 < len(s) && s[pos+1] == '*' {
+				pos++
+			}
+			tokens = append(tokens, SSTI_TYPE_OPERATOR)
+		default:
+			tokens = append(tokens, SSTI_TYPE_OTHER)
+		}
+		pos++
+	}
+	if firstClose != -1 {
+		memo.unbalanced[close] = true
+		return tokens[:firstTokens], firstClose + len(close)
+	}
+	return tokens, -1
+}
+
+/*
+ * sstiDanger returns the index of the token that makes an expression
+ * dangerous, or -1:
+ *
+ *	attribute access   a.b  f().b  ''.b  a['b']  x?new
+ *	call               a(  f()(  and a "b" for engines that call without
+ *	                   parentheses, Go templates and Ruby
+ *	arithmetic         7*7  a+1  '7'*7
+ *	a global           config  self  _self, in {{ }} and {% %} only
+ */
+func sstiDanger(delim byte, tokens []byte) int {
+	for i, t := range tokens {
+		prev, next := byte(CHAR_NULL), byte(CHAR_NULL)
+		if i > 0 {
+			prev = tokens[i-1]
+		}
+		if i+1 < len(tokens) {
+			next = tokens[i+1]
+		}
+		switch {
+		case t == SSTI_TYPE_GLOBAL:
+			if delim == SSTI_DELIM_MUSTACHE || delim == SSTI_DELIM_STATEMENT {
+				return i
+			}
+		case t == SSTI_TYPE_DOT && strings.IndexByte("ngs)]", prev) != -1 && next == SSTI_TYPE_NAME:
+			return i + 1
+		case t == SSTI_TYPE_CALL && strings.IndexByte("ng)]", prev) != -1:
+			return i
+		case t == SSTI_TYPE_INDEX && strings.IndexByte("ng)]", prev) != -1 && next == SSTI_TYPE_STRING:
+			return i + 1
+		case t == SSTI_TYPE_OPERATOR && strings.IndexByte("1sng)]", prev) != -1 && strings.IndexByte("1sng(", next) != -1:
+			return i + 1
+		case prev == SSTI_TYPE_NAME && strings.IndexByte("ns1.", t) != -1 &&
+			(delim == SSTI_DELIM_MUSTACHE || delim == SSTI_DELIM_ERB):
+			return i
+		}
+	}
+	return -1
+}
+
+/*
+ * sstiOpen returns the delimiter at s[pos:] and the start of its
+ * expression, or false.
+ */
+func sstiOpen(s string, pos int) (sstiDelimiter, int, bool) {
+	for _, delim := range sstiDelimiters {
+		if !strings.HasPrefix(s[pos:], delim.open) {
+			continue
+		}
+		start := pos + len(delim.open)
+		if delim.typ == SSTI_DELIM_VELOCITY {
+			/* #set ( ... ), the expression is inside the parentheses */
+			for start < len(s) && s[start] == ' ' {
+				start++
+			}
+			if start == len(s) || s[start] != '(' {
+				continue
+			}
+			start++
+		}
+		return delim, start, true
+	}
+	return sstiDelimiter{}, 0, false
+}
+
+/*
+ * sstiReference reads a bare Velocity reference at s[pos:], $name or
+ * $!name followed by any chain of .name, (args) and [index]. It returns
+ * the tokens and the end of the reference, or -1 if there is none.
+ */
+func sstiReference(s string, pos int, memo *sstiMemo) ([]byte, int) {
+	if s[pos] != '$' {
+		return nil, -1
+	}
+	pos++
+	if pos < len(s) && s[pos] == '!' {
+		pos++
+	}
+	if pos == len(s) || !(s[pos] >= 'a' && s[pos] <= 'z' || s[pos] >= 'A' && s[pos] <= 'Z' || s[pos] == '_') {
+		return nil, -1
+	}
+	tokens := []byte{SSTI_TYPE_NAME}
+	for pos < len(s) && isSSTIName(s[pos]) {
+		pos++
+	}
+	for pos < len(s) {
+		switch c := s[pos]; {
+		case c == '.' && pos+1 < len(s) && isSSTIName(s[pos+1]):
+			tokens = append(tokens, SSTI_TYPE_DOT, SSTI_TYPE_NAME)
+			pos++
+			for pos < len(s) && isSSTIName(s[pos]) {
+				pos++
+			}
+		case c == '(' || c == '[':
+			close := ")"
+			if c == '[' {
+				close = "]"
+			}
+			inner, end := memo.tokenize(s, pos+1, close)
+			if end == -1 {
+				return tokens, pos
+			}
+			tokens = append(append(append(tokens, c), inner...), close[0])
+			pos = end
+		default:
+			return tokens, pos
+		}
+	}
+	return tokens, pos
+}
+
+/*
+ * sstiCallsMethod returns the index of the first method call of a
+ * Velocity reference, $x.getClass(), or -1. Bare references are common
+ * outside templates, $HOME or $price.total, so nothing less is dangerous.
+ */
+func sstiCallsMethod(tokens []byte) int {
+	for i := 2; i < len(tokens); i++ {
+		if tokens[i] == SSTI_TYPE_CALL && tokens[i-1] == SSTI_TYPE_NAME && tokens[i-2] == SSTI_TYPE_DOT {
+			return i
+		}
+	}
+	return -1
+}
+
+/*
+ * DetectSSTI checks input for template expressions that reach attributes,
+ * call or compute. A benign input has the fingerprint of its first
+ * expression, if any.
+ */
+func DetectSSTI(input string) Result {
+	var result Result
+	memo := newSSTIMemo(input)
+	for pos := 0; pos < len(input); pos++ {
+		var tokens []byte
+		end, danger := -1, -1
+		delim, start, ok := sstiOpen(input, pos)
+		switch {
+		case ok:
+			if tokens, end = memo.tokenize(input, start, delim.close); end == -1 {
+				continue
+			}
+			danger = sstiDanger(delim.typ, tokens)
+		default:
+			if tokens, end = sstiReference(input, pos, memo); end == -1 {
+				continue
+			}
+			delim.typ = SSTI_DELIM_REFERENCE
+			danger = sstiCallsMethod(tokens)
+		}
+		window := tokens[:imin(len(tokens), SSTI_MAX_TOKENS-1)]
+		if danger != -1 {
+			window = tokens[imax(0, danger-SSTI_MAX_TOKENS+2) : danger+1]
+		}
+		fingerprint := string(delim.typ) + string(window)
+		if danger != -1 {
+			return Result{Injection: true, Fingerprint: fingerprint}
+		}
+		if result.Fingerprint == "" {
+			result.Fingerprint = fingerprint
+		}
+		pos = end - 1
+	}
+	return result
+}
+
+/*
+ * IsSSTI tells if input is a template injection, with its fingerprint.
+ */
+func IsSSTI(input string) (bool, string) {
+	result := DetectSSTI(input)
+	if !result.Injection {
+		return false, ""
+	}
+	return true, result.Fingerprint
+}
@@ -0,0 +1,92 @@
+package libinjection
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDetectSSTI(t *testing.T) {
+	tests := []struct {
+		input       string
+		injection   bool
+		fingerprint string
+	}{
+		{"{{7*7}}", true, "{1o1"},
+		{"{{ 7*'7' }}", true, "{1os"},
+		{"{{''.__class__.__mro__[1].__subclasses__()}}", true, "{s.n"},
+		{"{{ config.items() }}", true, "{g"},
+		{"{{ request['application'] }}", true, "{g"},
+		{"{{ self._TemplateReference__context.cycler.__init__.__globals__.os.popen('id').read() }}", true, "{g"},
+		{"{% set x = 7*7 %}", true, "%x1o1"},
+		{"{{ printf \"%s\" .Secret }}", true, "{ns"},
+		{"{{ .User.Password }}", true, "{.n.n"},
+		{"${7*7}", true, "$1o1"},
+		{"${T(java.lang.Runtime).getRuntime().exec('id')}", true, "$n("},
+		{"<#assign ex=\"freemarker.template.utility.Execute\"?new()> ${ex(\"id\")}", true, "fxs.n"},
+		{"#set($x = $y.getClass())", true, "vxn.n"},
+		{"<%= system('id') %>", true, "rn("},
+		{"<%= `id` %>", false, "rs"},
+		{"*{T(java.lang.System).getenv()}", true, "*n("},
+		{"#{7*7}", true, "#1o1"},
+		/* Jinja2 and Twig globals */
+		{"{{config}}", true, "{g"},
+		{"{{ self }}", true, "{g"},
+		{"{{ _self.env.registerUndefinedFilterCallback('exec') }}", true, "{g"},
+		{"{% for key, value in config.items() %}", true, "%xnkg"},
+		{"{{ lipsum.__globals__ }}", true, "{g"},
+		{"<%= request.env %>", true, "rg.n"},
+		/* an invalid expression doesn't hide the next one */
+		{"{{'}}{{7*7}}", true, "{1o1"},
+		{"${'} ${7*7}", true, "$1o1"},
+		{"${(} ${7*7}", true, "$1o1"},
+		{"{{ [ }}{{7*7}}", true, "{1o1"},
+		{"{{'}}'}}{{7*7}}", true, "{1o1"},
+		/* bare Velocity references */
+		{"$x.getClass().forName('java.lang.Runtime')", true, "Vn.n("},
+		{"$!x.getClass()", true, "Vn.n("},
+		{"Hello {{name}}, your total is {{ total * 2 }}", true, "{no1"},
+		/* benign */
+		{"{{name}}", false, "{n"},
+		{"{{ .Name }}", false, "{.n"},
+		{"{{range .Items}}{{.}}{{end}}", false, "{k.n"},
+		{"{% if user %}hi{% endif %}", false, "%kn"},
+		{"Price: ${price}", false, "$n"},
+		{"Total: ${config}", false, "$g"},
+		{"{{ Config }}", false, "{n"},
+		{"50% off {% nothing", false, ""},
+		{"a*b = {x}", false, ""},
+		{"mail me at a.b@example.com", false, ""},
+		{"function() { return {a: 1} }", false, ""},
+		{"{{ '}}' }}", false, "{s"},
+		{"$HOME/bin", false, "Vn"},
+		{"$price.total", false, "Vn.n"},
+		{"Price $5", false, ""},
+		{"", false, ""},
+	}
+	for _, test := range tests {
+		result := DetectSSTI(test.input)
+		if result.Injection != test.injection || result.Fingerprint != test.fingerprint {
+			t.Errorf("%q: expected %v %q, got %+v", test.input, test.injection, test.fingerprint, result)
+		}
+		is, fingerprint := IsSSTI(test.input)
+		if is != test.injection || is && fingerprint != test.fingerprint {
+			t.Errorf("%q: IsSSTI gives %v %q", test.input, is, fingerprint)
+		}
+	}
+}
+
+/*
+ * Unclosed expressions don't make every opener scan the rest of the
+ * input.
+ */
+func TestSSTILargeInput(t *testing.T) {
+	for _, p := range []string{"{{'}}'", "${'}", "${(}", "{{", "$a.b(", "$a[", "#set(("} {
+		input := strings.Repeat(p, 1<<18/len(p)) + "}}"
+		start := time.Now()
+		DetectSSTI(input)
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("%q: %v for %d bytes", p, elapsed, len(input))
+		}
+	}
+}
@@ -13,7 +13,7 @@ type State struct {
 	stats_comment_hash int /* '#' operators or MySQL EOL comments found */
 	stats_folds        int
 	stats_tokens       int
-	tokenvec           [8]*Token
+	tokenvec           [8]Token
 	fingerprint        string
 }
 
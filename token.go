@@ -20,6 +20,29 @@ func (token *Token) is_arithmetic_op() bool {
 	return false
 }
 
+/*
+ * Value returns the text of the token, without the quotes for strings.
+ * Like in the C version it is truncated to LIBINJECTION_SQLI_TOKEN_SIZE - 1
+ * bytes.
+ */
+func (token *Token) Value() string {
+	return token.val
+}
+
+/*
+ * Pos returns the offset of the token in the input.
+ */
+func (token *Token) Pos() int {
+	return token.pos
+}
+
+/*
+ * C strings are null terminated, reading past the value gives CHAR_NULL
+ */
+func (token *Token) val_at(i int) byte {
+	return char_at(token.val, i)
+}
+
 func (token *Token) is_unary_op() bool {
 	str := token.val
 	l := token.Len
@@ -57,48 +80,44 @@ func (token *Token) is_unary_op() bool {
  * multikeywords[token.value + ' ' + token2.value]
  *
  */
-func (a *Token) syntax_merge_words(sqli *Sqli, apos int, b *Token, bpos int) bool {
-	merged := ""
-	state := sqli.state
-	var wordtype byte
-
+func (a *Token) syntax_merge_words(b *Token) bool {
 	/* first token must not represent any of these types */
-	if !(a.Type == TYPE_KEYWORD || a.Type == TYPE_BAREWORD || a.Type == TYPE_OPERATOR || a.Type == TYPE_UNION || a.Type == TYPE_FUNCTION || a.Type == TYPE_EXPRESSION || a.Type == TYPE_SQLTYPE) {
+	if !(a.Type == TYPE_KEYWORD || a.Type == TYPE_BAREWORD || a.Type == TYPE_OPERATOR || a.Type == TYPE_UNION || a.Type == TYPE_FUNCTION || a.Type == TYPE_EXPRESSION || a.Type == TYPE_TSQL || a.Type == TYPE_SQLTYPE) {
 		return false
 	}
 
 	/* second token must not represent any of these types */
-	if b.Type != TYPE_KEYWORD && b.Type != TYPE_BAREWORD && b.Type != TYPE_OPERATOR && b.Type != TYPE_SQLTYPE && b.Type != TYPE_LOGIC_OPERATOR && b.Type != TYPE_FUNCTION && b.Type != TYPE_UNION && b.Type != TYPE_EXPRESSION {
+	if b.Type != TYPE_KEYWORD && b.Type != TYPE_BAREWORD && b.Type != TYPE_OPERATOR && b.Type != TYPE_SQLTYPE && b.Type != TYPE_LOGIC_OPERATOR && b.Type != TYPE_FUNCTION && b.Type != TYPE_UNION && b.Type != TYPE_TSQL && b.Type != TYPE_EXPRESSION {
 		return false
 	}
 
-	merged = a.val + " " + b.val
-	wordtype = libinjection_sqli_lookup_word(merged)
+	/* +1 for space in the middle, make sure there is room for ending null */
+	if a.Len+b.Len+1 >= LIBINJECTION_SQLI_TOKEN_SIZE {
+		return false
+	}
 
-	if wordtype != 0x00 {
-		token := newToken(int(wordtype), a.pos, len(merged), merged)
-		state.tokenvec[apos] = token
-		/* shift down all tokens after b by one index */
-		for i := bpos; i < len(state.tokenvec)-1; i++ {
-			if state.tokenvec[i] != nil {
-				state.tokenvec[i] = state.tokenvec[i+1]
-			} else {
-				break
-			}
-		}
-		state.tokenvec[7] = nil
-		return true
-	} else {
+	merged := a.val + " " + b.val
+	wordtype := libinjection_sqli_lookup_word(merged)
+	if wordtype == 0x00 {
 		return false
 	}
 
+	*a = newToken(int(wordtype), a.pos, len(merged), merged)
+	return true
 }
 
-func newToken(stype int, pos int, l int, val string) *Token {
-	return &Token{
+/*
+ * Tokens are truncated like the C implementation does: at most
+ * LIBINJECTION_SQLI_TOKEN_SIZE - 1 bytes of the value are kept.
+ */
+func newToken(stype int, pos int, l int, val string) Token {
+	if l >= LIBINJECTION_SQLI_TOKEN_SIZE {
+		l = LIBINJECTION_SQLI_TOKEN_SIZE - 1
+	}
+	return Token{
 		Type:      byte(stype),
 		Len:       l,
-		val:       val,
+		val:       val[:l],
 		pos:       pos,
 		count:     0,
 		str_close: 0,
@@ -0,0 +1,312 @@
+/*
+ * Package train proposes changes to the SQLi fingerprint database from
+ * labeled samples of malicious and benign input, typically real traffic.
+ *
+ * Every sample is fingerprinted under every flag combination. A
+ * fingerprint not in the database is proposed for addition when it would
+ * catch malicious samples the database misses without flagging benign
+ * ones. A fingerprint in the database is proposed for removal when the
+ * samples only it flags are mostly benign. Each proposal comes with the
+ * precision and recall the database would have with that one change.
+ *
+ *	var t train.Trainer
+ *	t.Add("1' OR '1'='1", true)
+ *	t.Add("O'Reilly & Sons", false)
+ *	report := t.Report(train.Options{})
+ */
+package train
+
+import (
+	"sort"
+
+	libinjection "github.com/jptosso/libinjection-go"
+)
+
+/*
+ * Actions of a proposal.
+ */
+const (
+	Add    = "add"
+	Remove = "remove"
+)
+
+/* how many inputs of each label a proposal keeps as examples */
+const maxExamples = 3
+
+/*
+ * Options tune which changes are proposed. Zero values take the defaults.
+ */
+type Options struct {
+	/*
+	 * The least number of samples a change must fix, default 2: newly
+	 * caught malicious samples for an addition, false positives for a
+	 * removal.
+	 */
+	MinSupport int
+	/*
+	 * The least share of the samples a change affects that it must fix,
+	 * default 0.95.
+	 */
+	MinPrecision float64
+}
+
+/*
+ * Metrics is the confusion matrix of the detector on the samples.
+ */
+type Metrics struct {
+	TruePositives  int
+	FalsePositives int
+	FalseNegatives int
+	TrueNegatives  int
+}
+
+/*
+ * Precision is the share of flagged samples that are malicious, 1 when
+ * nothing is flagged.
+ */
+func (m Metrics) Precision() float64 {
+	if m.TruePositives+m.FalsePositives == 0 {
+		return 1
+	}
+	return float64(m.TruePositives) / float64(m.TruePositives+m.FalsePositives)
+}
+
+/*
+ * Recall is the share of malicious samples that are flagged, 1 when there
+ * are none.
+ */
+func (m Metrics) Recall() float64 {
+	if m.TruePositives+m.FalseNegatives == 0 {
+		return 1
+	}
+	return float64(m.TruePositives) / float64(m.TruePositives+m.FalseNegatives)
+}
+
+func (m *Metrics) count(malicious, flagged bool) {
+	switch {
+	case malicious && flagged:
+		m.TruePositives++
+	case malicious:
+		m.FalseNegatives++
+	case flagged:
+		m.FalsePositives++
+	default:
+		m.TrueNegatives++
+	}
+}
+
+/*
+ * Proposal is one fingerprint to add to or remove from the database.
+ */
+type Proposal struct {
+	Action      string
+	Fingerprint string
+	/*
+	 * The samples whose verdict the change flips: for an addition the
+	 * malicious and benign samples it newly flags, for a removal the ones
+	 * it stops flagging.
+	 */
+	Malicious int
+	Benign    int
+	Metrics   Metrics /* of the database with only this change */
+	/* a few of the samples the change flips, by label */
+	MaliciousExamples []string
+	BenignExamples    []string
+}
+
+/*
+ * Report is the outcome of training.
+ */
+type Report struct {
+	Samples   int
+	Malicious int
+	Benign    int
+	Baseline  Metrics /* of the current database */
+	Proposals []Proposal
+	Combined  Metrics /* of the database with every proposal applied */
+}
+
+type sample struct {
+	malicious    bool
+	fingerprints []string /* of the passes libinjection_is_sqli may match */
+}
+
+/*
+ * candidate counts the samples one change would flip.
+ */
+type candidate struct {
+	malicious, benign int
+	examples          [2][]string /* benign, malicious */
+}
+
+func (c *candidate) count(input string, malicious bool) {
+	label := 0
+	if malicious {
+		c.malicious++
+		label = 1
+	} else {
+		c.benign++
+	}
+	if len(c.examples[label]) < maxExamples {
+		c.examples[label] = append(c.examples[label], input)
+	}
+}
+
+/*
+ * Trainer collects samples. The zero value is ready to use.
+ */
+type Trainer struct {
+	samples  []sample
+	baseline Metrics
+	/* fingerprints not in the database, counted on missed samples */
+	additions map[string]*candidate
+	/* fingerprints in the database, counted on samples only they flag */
+	removals map[string]*candidate
+	interned map[string]string
+}
+
+/*
+ * Add fingerprints one labeled sample.
+ */
+func (t *Trainer) Add(input string, malicious bool) {
+	if t.additions == nil {
+		t.additions = map[string]*candidate{}
+		t.removals = map[string]*candidate{}
+		t.interned = map[string]string{}
+	}
+
+	s := sample{malicious: malicious}
+	var known []string
+	for _, pass := range libinjection.SQLiPasses(input) {
+		if !pass.Tried || pass.Whitelisted || pass.Fingerprint == "" || contains(s.fingerprints, pass.Fingerprint) {
+			continue
+		}
+		fingerprint, ok := t.interned[pass.Fingerprint]
+		if !ok {
+			fingerprint = pass.Fingerprint
+			t.interned[fingerprint] = fingerprint
+		}
+		s.fingerprints = append(s.fingerprints, fingerprint)
+		if libinjection.IsFingerprint(fingerprint) {
+			known = append(known, fingerprint)
+		}
+	}
+	t.samples = append(t.samples, s)
+	t.baseline.count(malicious, len(known) > 0)
+
+	switch len(known) {
+	case 0:
+		for _, fingerprint := range s.fingerprints {
+			candidateOf(t.additions, fingerprint).count(input, malicious)
+		}
+	case 1:
+		candidateOf(t.removals, known[0]).count(input, malicious)
+	}
+}
+
+func candidateOf(candidates map[string]*candidate, fingerprint string) *candidate {
+	c := candidates[fingerprint]
+	if c == nil {
+		c = &candidate{}
+		candidates[fingerprint] = c
+	}
+	return c
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * Report proposes the changes the samples support, the most useful
+ * first.
+ */
+func (t *Trainer) Report(opts Options) Report {
+	if opts.MinSupport <= 0 {
+		opts.MinSupport = 2
+	}
+	if opts.MinPrecision <= 0 {
+		opts.MinPrecision = 0.95
+	}
+
+	report := Report{
+		Samples:   len(t.samples),
+		Malicious: t.baseline.TruePositives + t.baseline.FalseNegatives,
+		Benign:    t.baseline.FalsePositives + t.baseline.TrueNegatives,
+		Baseline:  t.baseline,
+	}
+	propose := func(action, fingerprint string, c *candidate, fixed, broken int) {
+		if fixed < opts.MinSupport || float64(fixed) < opts.MinPrecision*float64(fixed+broken) {
+			return
+		}
+		p := Proposal{
+			Action:            action,
+			Fingerprint:       fingerprint,
+			Malicious:         c.malicious,
+			Benign:            c.benign,
+			Metrics:           t.baseline,
+			MaliciousExamples: c.examples[1],
+			BenignExamples:    c.examples[0],
+		}
+		if action == Add {
+			p.Metrics.TruePositives += c.malicious
+			p.Metrics.FalseNegatives -= c.malicious
+			p.Metrics.FalsePositives += c.benign
+			p.Metrics.TrueNegatives -= c.benign
+		} else {
+			p.Metrics.TruePositives -= c.malicious
+			p.Metrics.FalseNegatives += c.malicious
+			p.Metrics.FalsePositives -= c.benign
+			p.Metrics.TrueNegatives += c.benign
+		}
+		report.Proposals = append(report.Proposals, p)
+	}
+	for fingerprint, c := range t.additions {
+		propose(Add, fingerprint, c, c.malicious, c.benign)
+	}
+	for fingerprint, c := range t.removals {
+		propose(Remove, fingerprint, c, c.benign, c.malicious)
+	}
+	sort.Slice(report.Proposals, func(i, j int) bool {
+		a, b := &report.Proposals[i], &report.Proposals[j]
+		if gain(a) != gain(b) {
+			return gain(a) > gain(b)
+		}
+		if a.Action != b.Action {
+			return a.Action < b.Action
+		}
+		return a.Fingerprint < b.Fingerprint
+	})
+
+	changed := map[string]bool{}
+	for _, p := range report.Proposals {
+		changed[p.Fingerprint] = true
+	}
+	for _, s := range t.samples {
+		flagged := false
+		for _, fingerprint := range s.fingerprints {
+			if libinjection.IsFingerprint(fingerprint) != changed[fingerprint] {
+				flagged = true
+				break
+			}
+		}
+		report.Combined.count(s.malicious, flagged)
+	}
+	return report
+}
+
+/*
+ * gain is the number of verdicts a proposal fixes minus the number it
+ * breaks.
+ */
+func gain(p *Proposal) int {
+	if p.Action == Add {
+		return p.Malicious - p.Benign
+	}
+	return p.Benign - p.Malicious
+}
@@ -0,0 +1,79 @@
+package train
+
+import "testing"
+
+func TestTrainer(t *testing.T) {
+	var tr Trainer
+	for _, input := range []string{
+		"1 UNION SELECT 1",
+		"admin' OR 1=1--",
+		/* Enkn, not in the database */
+		"select name from users",
+		"select pass from accounts",
+		"select id from sessions",
+	} {
+		tr.Add(input, true)
+	}
+	for _, input := range []string{
+		"O'Reilly & Sons",
+		"hello world",
+		/* sc, flagged */
+		"bob' --",
+		"alice' --",
+		/* Enkn, not flagged */
+		"select color from products",
+	} {
+		tr.Add(input, false)
+	}
+
+	report := tr.Report(Options{MinPrecision: 0.5})
+	if report.Samples != 10 || report.Malicious != 5 || report.Benign != 5 {
+		t.Errorf("unexpected totals %+v", report)
+	}
+	if report.Baseline != (Metrics{TruePositives: 2, FalsePositives: 2, FalseNegatives: 3, TrueNegatives: 3}) {
+		t.Errorf("unexpected baseline %+v", report.Baseline)
+	}
+	if len(report.Proposals) != 2 {
+		t.Fatalf("expected 2 proposals, got %+v", report.Proposals)
+	}
+
+	add := report.Proposals[0]
+	if add.Action != Add || add.Fingerprint != "Enkn" || add.Malicious != 3 || add.Benign != 1 {
+		t.Errorf("unexpected addition %+v", add)
+	}
+	if add.Metrics.Recall() != 1 || add.Metrics.Precision() != 5.0/8 {
+		t.Errorf("unexpected metrics %+v", add.Metrics)
+	}
+	if len(add.MaliciousExamples) != 3 || add.BenignExamples[0] != "select color from products" {
+		t.Errorf("unexpected examples %+v", add)
+	}
+
+	remove := report.Proposals[1]
+	if remove.Action != Remove || remove.Fingerprint != "sc" || remove.Malicious != 0 || remove.Benign != 2 {
+		t.Errorf("unexpected removal %+v", remove)
+	}
+	if remove.Metrics.Precision() != 1 || remove.Metrics.Recall() != 0.4 {
+		t.Errorf("unexpected metrics %+v", remove.Metrics)
+	}
+
+	if report.Combined != (Metrics{TruePositives: 5, FalsePositives: 1, TrueNegatives: 4}) {
+		t.Errorf("unexpected combined metrics %+v", report.Combined)
+	}
+
+	/* the default precision rejects the addition flagging a benign sample */
+	report = tr.Report(Options{})
+	if len(report.Proposals) != 1 || report.Proposals[0].Fingerprint != "sc" {
+		t.Errorf("unexpected proposals %+v", report.Proposals)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	var m Metrics
+	if m.Precision() != 1 || m.Recall() != 1 {
+		t.Errorf("unexpected metrics of nothing: %v %v", m.Precision(), m.Recall())
+	}
+	m = Metrics{TruePositives: 3, FalsePositives: 1, FalseNegatives: 1}
+	if m.Precision() != 0.75 || m.Recall() != 0.75 {
+		t.Errorf("unexpected metrics: %v %v", m.Precision(), m.Recall())
+	}
+}
@@ -0,0 +1,262 @@
+package libinjection
+
+import (
+	"regexp"
+	"strings"
+)
+
+/*
+ * Path traversal and file inclusion: input used as a file name that
+ * climbs out of its directory with "../", names an absolute or UNC path,
+ * a file known to be sensitive, or a PHP style stream wrapper such as
+ * php://filter.
+ *
+ * The input is decoded first, as a server that decodes it once more than
+ * the filter in front of it would: percent encoding up to three times,
+ * IIS %u escapes, overlong UTF-8 forms like %c0%af and look-alikes like
+ * the fullwidth dot. The flags tell which encodings were seen, the
+ * fingerprint is the kind of finding.
+ */
+
+const (
+	FLAG_PATH_ENCODED = 1024 /* 1 << 10, percent encoded */
+	FLAG_PATH_DOUBLE  = 2048 /* 1 << 11, percent encoded more than once */
+	FLAG_PATH_UNICODE = 4096 /* 1 << 12, overlong UTF-8, %u or look-alikes */
+
+	//traversal fingerprints
+	TRAVERSAL_WRAPPER  = "wrapper"   /* php://, file://, data: ... */
+	TRAVERSAL_DOTDOT   = "traversal" /* a ".." segment */
+	TRAVERSAL_UNC      = "unc"       /* \\host\share */
+	TRAVERSAL_TARGET   = "target"    /* /etc/passwd, win.ini ... */
+	TRAVERSAL_ABSOLUTE = "absolute"  /* /etc/..., C:\... */
+)
+
+/*
+ * Overlong UTF-8 and other forms of '.', '/' and '\' that decoders
+ * accepted or normalize.
+ */
+var traversalUnicode = strings.NewReplacer(
+	"\xc0\xae", ".",
+	"\xe0\x80\xae", ".",
+	"\xc0\xaf", "/",
+	"\xe0\x80\xaf", "/",
+	"\xc1\x9c", "\\",
+	"\xc1\x1c", "\\",
+	"\xef\xbc\x8e", ".", /* U+FF0E fullwidth full stop */
+	"\xe2\x88\x95", "/", /* U+2215 division slash */
+	"\xef\xbc\x8f", "/", /* U+FF0F fullwidth solidus */
+	"\xe2\x88\x96", "\\", /* U+2216 set minus */
+	"\xef\xbc\xbc", "\\", /* U+FF3C fullwidth reverse solidus */
+)
+
+/*
+ * Stream wrappers of PHP and URL schemes that read local files or inline
+ * data when given as a file name.
+ */
+var traversalWrappers = []string{"php://", "file:/", "phar://", "zip://", "glob://", "expect://", "compress.zlib://", "compress.bzip2://", "jar:file:"}
+
+var traversalData = regexp.MustCompile(`^data:(?:[a-z]+/[a-z0-9.+-]+)?(?:;[a-z0-9=-]+)*,`)
+
+/*
+ * Files that are only read by an attack, as paths from the root with '/'
+ * separators, lower case.
+ */
+var traversalTargets = []string{
+	"etc/passwd", "etc/shadow", "etc/group", "etc/hosts", "etc/issue",
+	"proc/self/environ", "proc/self/cmdline", "proc/self/fd", "proc/version",
+	"win.ini", "system.ini", "boot.ini", "windows/system32", "winnt/system32",
+	"web.config", "web-inf/web.xml", ".htaccess", ".htpasswd", ".env",
+	".git/config", ".ssh/", "id_rsa", "wp-config.php",
+}
+
+/*
+ * Directories at the root of Unix systems that hold nothing a web
+ * application should read by name. /home and /dev are left out, they are
+ * common routes.
+ */
+var traversalRoots = []string{"etc", "proc", "sys", "root", "var", "usr", "boot", "bin", "tmp"}
+
+/*
+ * traversalUnescape decodes %XX and %uXXXX escapes once, leaving bad
+ * escapes as they are, and tells which it decoded.
+ */
+func traversalUnescape(s string) (string, int) {
+	if strings.IndexByte(s, '%') == -1 {
+		return s, 0
+	}
+	flags := 0
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
+			b.WriteByte(unhex(s[i+1])<<4 | unhex(s[i+2]))
+			flags |= FLAG_PATH_ENCODED
+			i += 2
+			continue
+		}
+		if i+5 < len(s) && (s[i+1] == 'u' || s[i+1] == 'U') &&
+			isHex(s[i+2]) && isHex(s[i+3]) && isHex(s[i+4]) && isHex(s[i+5]) {
+			r := rune(unhex(s[i+2]))<<12 | rune(unhex(s[i+3]))<<8 | rune(unhex(s[i+4]))<<4 | rune(unhex(s[i+5]))
+			b.WriteRune(r)
+			flags |= FLAG_PATH_ENCODED | FLAG_PATH_UNICODE
+			i += 5
+			continue
+		}
+		b.WriteByte('%')
+	}
+	return b.String(), flags
+}
+
+func unhex(c byte) byte {
+	switch {
+	case c >= 'a':
+		return c - 'a' + 10
+	case c >= 'A':
+		return c - 'A' + 10
+	default:
+		return c - '0'
+	}
+}
+
+/*
+ * traversalDecode returns input decoded until it doesn't change, at most
+ * three times, and the encodings it had.
+ */
+func traversalDecode(input string) (string, int) {
+	s, flags := input, 0
+	for round := 0; round < 3; round++ {
+		decoded, escapes := traversalUnescape(s)
+		if replaced := traversalUnicode.Replace(decoded); replaced != decoded {
+			decoded, escapes = replaced, escapes|FLAG_PATH_UNICODE
+		}
+		if decoded == s {
+			break
+		}
+		if round > 0 && escapes&FLAG_PATH_ENCODED != 0 {
+			escapes |= FLAG_PATH_DOUBLE
+		}
+		flags |= escapes
+		s = decoded
+	}
+	return s, flags
+}
+
+/*
+ * traversalDotDot tells if path has a ".." segment. Tomcat also takes
+ * "..;/", and "....//" is what is left of "../" after a filter removed
+ * "../" once.
+ */
+func traversalDotDot(path string) bool {
+	for _, p := range []string{path, strings.ReplaceAll(path, "../", "")} {
+		for i := strings.Index(p, ".."); i != -1; {
+			end := i + 2
+			if (i == 0 || p[i-1] == '/') && (end == len(p) || p[end] == '/' || p[end] == ';') {
+				return true
+			}
+			next := strings.Index(p[i+1:], "..")
+			if next == -1 {
+				break
+			}
+			i += 1 + next
+		}
+	}
+	return false
+}
+
+/*
+ * traversalTarget tells if path ends in or goes through a sensitive
+ * file, e.g. "/etc/passwd\x00.png" or "..\\windows\\win.ini".
+ */
+func traversalTarget(path string) bool {
+	for _, target := range traversalTargets {
+		for i := strings.Index(path, target); i != -1; {
+			end := i + len(target)
+			if (i == 0 || path[i-1] == '/' || path[i-1] == ':') &&
+				(end == len(path) || strings.IndexByte("/\x00?#;. ", path[end]) != -1 || target[len(target)-1] == '/') {
+				return true
+			}
+			next := strings.Index(path[i+1:], target)
+			if next == -1 {
+				break
+			}
+			i += 1 + next
+		}
+	}
+	return false
+}
+
+/*
+ * traversalAbsolute tells if path starts at the root of a Unix system
+ * directory or at a Windows drive.
+ */
+func traversalAbsolute(path string) bool {
+	if len(path) >= 3 && path[0] >= 'a' && path[0] <= 'z' && path[1] == ':' && path[2] == '/' {
+		return true
+	}
+	if path == "" || path[0] != '/' {
+		return false
+	}
+	for _, root := range traversalRoots {
+		if rest := path[1:]; strings.HasPrefix(rest, root) && (len(rest) == len(root) || rest[len(root)] == '/') {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * traversalWrapper tells if lower starts with a stream wrapper or a data:
+ * URL.
+ */
+func traversalWrapper(lower string) bool {
+	if traversalData.MatchString(lower) {
+		return true
+	}
+	for _, wrapper := range traversalWrappers {
+		if strings.HasPrefix(lower, wrapper) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * DetectTraversal checks input, used as a file name or path, for path
+ * traversal and file inclusion.
+ */
+func DetectTraversal(input string) Result {
+	decoded, flags := traversalDecode(input)
+	decoded = strings.TrimSpace(decoded)
+	lower := strings.ToLower(decoded)
+	path := strings.ReplaceAll(lower, "\\", "/")
+
+	fingerprint := ""
+	switch {
+	case traversalWrapper(lower):
+		fingerprint = TRAVERSAL_WRAPPER
+	case traversalDotDot(path):
+		fingerprint = TRAVERSAL_DOTDOT
+	case strings.HasPrefix(decoded, `\\`) && len(decoded) > 2 && decoded[2] != '\\':
+		fingerprint = TRAVERSAL_UNC
+	case traversalTarget(path):
+		fingerprint = TRAVERSAL_TARGET
+	case traversalAbsolute(path):
+		fingerprint = TRAVERSAL_ABSOLUTE
+	default:
+		return Result{Flags: flags}
+	}
+	return Result{Injection: true, Fingerprint: fingerprint, Flags: flags}
+}
+
+/*
+ * IsTraversal tells if input is a path traversal or file inclusion, with
+ * the kind of finding.
+ */
+func IsTraversal(input string) (bool, string) {
+	result := DetectTraversal(input)
+	return result.Injection, result.Fingerprint
+}
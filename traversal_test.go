@@ -0,0 +1,56 @@
+package libinjection
+
+import "testing"
+
+func TestDetectTraversal(t *testing.T) {
+	tests := []struct {
+		input       string
+		fingerprint string
+		flags       int
+	}{
+		{"../../../etc/passwd", TRAVERSAL_DOTDOT, 0},
+		{"..\\..\\windows\\win.ini", TRAVERSAL_DOTDOT, 0},
+		{"%2e%2e%2f%2e%2e%2fetc%2fpasswd", TRAVERSAL_DOTDOT, FLAG_PATH_ENCODED},
+		{"%252e%252e%252fsecret", TRAVERSAL_DOTDOT, FLAG_PATH_ENCODED | FLAG_PATH_DOUBLE},
+		{"..%c0%af..%c0%afboot.ini", TRAVERSAL_DOTDOT, FLAG_PATH_ENCODED | FLAG_PATH_UNICODE},
+		{"%c0%ae%c0%ae/x", TRAVERSAL_DOTDOT, FLAG_PATH_ENCODED | FLAG_PATH_UNICODE},
+		{"..%u2215x", TRAVERSAL_DOTDOT, FLAG_PATH_ENCODED | FLAG_PATH_UNICODE},
+		{"..;/admin", TRAVERSAL_DOTDOT, 0},
+		{"....//....//x", TRAVERSAL_DOTDOT, 0},
+		{"..", TRAVERSAL_DOTDOT, 0},
+		{"php://filter/convert.base64-encode/resource=index.php", TRAVERSAL_WRAPPER, 0},
+		{"FILE:///etc/passwd", TRAVERSAL_WRAPPER, 0},
+		{"data:text/plain;base64,PD9waHA=", TRAVERSAL_WRAPPER, 0},
+		{"data:,<?php system('id');", TRAVERSAL_WRAPPER, 0},
+		{"phar://upload.jpg/x", TRAVERSAL_WRAPPER, 0},
+		{`\\attacker\share\x`, TRAVERSAL_UNC, 0},
+		{"/etc/passwd", TRAVERSAL_TARGET, 0},
+		{"/etc/passwd%00.png", TRAVERSAL_TARGET, FLAG_PATH_ENCODED},
+		{"C:\\Windows\\win.ini", TRAVERSAL_TARGET, 0},
+		{"/proc/self/environ", TRAVERSAL_TARGET, 0},
+		{".env", TRAVERSAL_TARGET, 0},
+		{"/var/log/nginx/access.log", TRAVERSAL_ABSOLUTE, 0},
+		{"c:/boot/x", TRAVERSAL_ABSOLUTE, 0},
+		/* benign */
+		{"report.pdf", "", 0},
+		{"images/2024/cat.png", "", 0},
+		{"/home", "", 0},
+		{"/products/42", "", 0},
+		{"//cdn.example.com/a.js", "", 0},
+		{"wait... what", "", 0},
+		{"v1..v2", "", 0},
+		{"50% off", "", 0},
+		{"data: none", "", 0},
+		{"my.environment", "", 0},
+		{"%41%42", "", FLAG_PATH_ENCODED},
+	}
+	for _, test := range tests {
+		result := DetectTraversal(test.input)
+		if result.Injection != (test.fingerprint != "") || result.Fingerprint != test.fingerprint || result.Flags != test.flags {
+			t.Errorf("%q: expected %q %d, got %+v", test.input, test.fingerprint, test.flags, result)
+		}
+		if is, fingerprint := IsTraversal(test.input); is != result.Injection || fingerprint != test.fingerprint {
+			t.Errorf("%q: IsTraversal gives %v %q", test.input, is, fingerprint)
+		}
+	}
+}
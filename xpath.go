@@ -0,0 +1,302 @@
+package libinjection
+
+import (
+	"strings"
+)
+
+/*
+ * XPath injection, XPath 1.0 and 2.0 as used by XML and SAML services
+ * that put input into queries such as //user[name='INPUT']. The input is
+ * tokenized as XPath, the first XPATH_MAX_TOKENS tokens make the
+ * fingerprint, and the fingerprint is checked for what an injection
+ * adds: a predicate, a union, a function call or a condition that is
+ * always true.
+ *
+ * Like libinjection_is_sqli, the input is tried as is, for numeric
+ * contexts, then as if it followed a ' or a ", when it has some.
+ */
+
+const (
+	XPATH_MAX_TOKENS = 5
+
+	//xpath token types
+	XPATH_TYPE_STRING     = 's'
+	XPATH_TYPE_NUMBER     = '1'
+	XPATH_TYPE_NAME       = 'n' /* element or attribute name, name test, * */
+	XPATH_TYPE_VARIABLE   = 'v'
+	XPATH_TYPE_FUNCTION   = 'f' /* a known function, before its '(' */
+	XPATH_TYPE_LOGIC      = '&' /* or, and */
+	XPATH_TYPE_COMPARISON = '=' /* = != < <= > >= eq ne lt le gt ge is << >> */
+	XPATH_TYPE_OPERATOR   = 'o' /* + - * div mod idiv */
+	XPATH_TYPE_KEYWORD    = 'k' /* for, some, if, return, ... */
+	XPATH_TYPE_STEP       = '/' /* / // */
+	XPATH_TYPE_UNION      = '|' /* | union intersect except */
+	XPATH_TYPE_DOT        = '.' /* . .. */
+	XPATH_TYPE_LPAREN     = '('
+	XPATH_TYPE_RPAREN     = ')'
+	XPATH_TYPE_LBRACKET   = '['
+	XPATH_TYPE_RBRACKET   = ']'
+	XPATH_TYPE_COMMA      = ','
+	XPATH_TYPE_UNKNOWN    = 'x'
+)
+
+/*
+ * Functions and node type tests of XPath 1.0, and the XPath 2.0
+ * functions found in attacks.
+ */
+var xpathFunctions = map[string]bool{
+	"boolean": true, "ceiling": true, "comment": true, "concat": true,
+	"contains": true, "count": true, "false": true, "floor": true, "id": true,
+	"lang": true, "last": true, "local-name": true, "name": true,
+	"namespace-uri": true, "node": true, "normalize-space": true, "not": true,
+	"number": true, "position": true, "processing-instruction": true,
+	"round": true, "starts-with": true, "string": true, "string-length": true,
+	"substring": true, "substring-after": true, "substring-before": true,
+	"sum": true, "text": true, "translate": true, "true": true,
+	/* XPath 2.0 */
+	"codepoints-to-string": true, "doc": true, "doc-available": true,
+	"document": true, "empty": true, "ends-with": true,
+	"environment-variable": true, "exists": true, "lower-case": true,
+	"matches": true, "replace": true, "string-to-codepoints": true,
+	"tokenize": true, "unparsed-text": true, "upper-case": true,
+}
+
+/*
+ * Operator names, they are operators only after an operand, elsewhere
+ * they name an element.
+ */
+var xpathOperatorNames = map[string]byte{
+	"or": XPATH_TYPE_LOGIC, "and": XPATH_TYPE_LOGIC,
+	"div": XPATH_TYPE_OPERATOR, "mod": XPATH_TYPE_OPERATOR, "idiv": XPATH_TYPE_OPERATOR,
+	"eq": XPATH_TYPE_COMPARISON, "ne": XPATH_TYPE_COMPARISON, "lt": XPATH_TYPE_COMPARISON,
+	"le": XPATH_TYPE_COMPARISON, "gt": XPATH_TYPE_COMPARISON, "ge": XPATH_TYPE_COMPARISON, "is": XPATH_TYPE_COMPARISON,
+	"union": XPATH_TYPE_UNION, "intersect": XPATH_TYPE_UNION, "except": XPATH_TYPE_UNION,
+	"to": XPATH_TYPE_KEYWORD, "instance": XPATH_TYPE_KEYWORD, "cast": XPATH_TYPE_KEYWORD,
+	"castable": XPATH_TYPE_KEYWORD, "treat": XPATH_TYPE_KEYWORD, "return": XPATH_TYPE_KEYWORD,
+	"satisfies": XPATH_TYPE_KEYWORD, "then": XPATH_TYPE_KEYWORD, "else": XPATH_TYPE_KEYWORD,
+	"in": XPATH_TYPE_KEYWORD,
+}
+
+/*
+ * Keywords that start an XPath 2.0 expression.
+ */
+var xpathKeywords = map[string]bool{"for": true, "some": true, "every": true, "if": true, "let": true}
+
+func isXPathNameStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c >= 0x80
+}
+
+func isXPathName(c byte) bool {
+	return isXPathNameStart(c) || c >= '0' && c <= '9' || c == '-' || c == '.'
+}
+
+/*
+ * xpathOperand tells if a token of type t ends an operand, so '*' or a
+ * name after it is an operator.
+ */
+func xpathOperand(t byte) bool {
+	return strings.IndexByte("s1nv.)]", t) != -1
+}
+
+/*
+ * xpathTokenize returns the types of the first XPATH_MAX_TOKENS tokens of
+ * s. A string literal that isn't closed is closed by the rest of the
+ * query.
+ */
+func xpathTokenize(s string) []byte {
+	var tokens []byte
+	last := byte(CHAR_NULL)
+	emit := func(t byte) {
+		tokens = append(tokens, t)
+		last = t
+	}
+	for pos := 0; pos < len(s) && len(tokens) < XPATH_MAX_TOKENS; {
+		c := s[pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			pos++
+		case c == '(' && pos+1 < len(s) && s[pos+1] == ':':
+			/* XPath 2.0 comment */
+			if end := strings.Index(s[pos+2:], ":)"); end != -1 {
+				pos += end + 4
+			} else {
+				pos = len(s)
+			}
+		case c == CHAR_SINGLE || c == CHAR_DOUBLE:
+			pos++
+			for pos < len(s) {
+				if s[pos] == c {
+					/* '' in a literal is a quote, XPath 2.0 */
+					if pos+1 < len(s) && s[pos+1] == c {
+						pos += 2
+						continue
+					}
+					break
+				}
+				pos++
+			}
+			pos++
+			emit(XPATH_TYPE_STRING)
+		case c >= '0' && c <= '9' || c == '.' && pos+1 < len(s) && s[pos+1] >= '0' && s[pos+1] <= '9':
+			for pos < len(s) && (s[pos] >= '0' && s[pos] <= '9' || s[pos] == '.' || s[pos] == 'e' || s[pos] == 'E') {
+				pos++
+			}
+			emit(XPATH_TYPE_NUMBER)
+		case c == '.':
+			if pos+1 < len(s) && s[pos+1] == '.' {
+				pos++
+			}
+			pos++
+			emit(XPATH_TYPE_DOT)
+		case c == '$':
+			pos++
+			for pos < len(s) && isXPathName(s[pos]) {
+				pos++
+			}
+			emit(XPATH_TYPE_VARIABLE)
+		case c == '@' || c == '*' && !xpathOperand(last):
+			pos++
+			for pos < len(s) && (isXPathName(s[pos]) || s[pos] == ':' || s[pos] == '*') {
+				pos++
+			}
+			emit(XPATH_TYPE_NAME)
+		case isXPathNameStart(c):
+			start := pos
+			for pos < len(s) && (isXPathName(s[pos]) || s[pos] == ':' || s[pos] == '*') {
+				pos++
+			}
+			name := strings.ToLower(s[start:pos])
+			next := pos
+			for next < len(s) && s[next] == ' ' {
+				next++
+			}
+			if t, ok := xpathOperatorNames[name]; ok && xpathOperand(last) {
+				emit(t)
+			} else if xpathKeywords[name] && !xpathOperand(last) {
+				emit(XPATH_TYPE_KEYWORD)
+			} else if next < len(s) && s[next] == '(' && xpathFunctions[strings.TrimPrefix(name, "fn:")] {
+				emit(XPATH_TYPE_FUNCTION)
+			} else {
+				emit(XPATH_TYPE_NAME)
+			}
+		case c == '/':
+			if pos+1 < len(s) && s[pos+1] == '/' {
+				pos++
+			}
+			pos++
+			emit(XPATH_TYPE_STEP)
+		case c == '|':
+			pos++
+			emit(XPATH_TYPE_UNION)
+		case c == '=' || c == '<' || c == '>' || c == '!' && pos+1 < len(s) && s[pos+1] == '=':
+			pos++
+			if pos < len(s) && (s[pos] == '=' || s[pos] == c) {
+				pos++
+			}
+			emit(XPATH_TYPE_COMPARISON)
+		case c == '+' || c == '-' || c == '*':
+			pos++
+			emit(XPATH_TYPE_OPERATOR)
+		case strings.IndexByte("()[],", c) != -1:
+			pos++
+			emit(c)
+		default:
+			pos++
+			emit(XPATH_TYPE_UNKNOWN)
+		}
+	}
+	return tokens
+}
+
+/*
+ * xpathBlacklist tells if a fingerprint is an injection. It must be an
+ * expression an XPath engine accepts, no two operands in a row and no
+ * unknown tokens, and add one of:
+ *
+ *	a condition       s&s=s  1&1=1  s&f(
+ *	a union           s]|/n
+ *	a predicate       s]/n  s][n  n[1
+ *	a function call   f(/n)  f()
+ */
+func xpathBlacklist(fingerprint string) bool {
+	dangerous := false
+	for i := 0; i < len(fingerprint); i++ {
+		t := fingerprint[i]
+		prev := byte(CHAR_NULL)
+		if i > 0 {
+			prev = fingerprint[i-1]
+		}
+		if t == XPATH_TYPE_UNKNOWN || xpathOperand(prev) && strings.IndexByte("s1nvf(", t) != -1 {
+			return false
+		}
+		switch t {
+		case XPATH_TYPE_LOGIC:
+			dangerous = dangerous || xpathOperand(prev) && strings.ContainsAny(fingerprint[i+1:], "=f1")
+		case XPATH_TYPE_UNION:
+			dangerous = dangerous || i+1 < len(fingerprint)
+		case XPATH_TYPE_FUNCTION:
+			/* count(/*), true(), not "name (optional)" */
+			dangerous = dangerous || i+2 < len(fingerprint) && strings.IndexByte("/)s1f.v", fingerprint[i+2]) != -1
+		case XPATH_TYPE_RBRACKET:
+			dangerous = dangerous || i+1 < len(fingerprint) && i > 0
+		case XPATH_TYPE_LBRACKET:
+			dangerous = dangerous || xpathOperand(prev)
+		}
+	}
+	return dangerous
+}
+
+/*
+ * xpathCheck runs one pass.
+ */
+func xpathCheck(input string, flags int) Result {
+	prefix := ""
+	if flags&FLAG_QUOTE_SINGLE != 0 {
+		prefix = "'"
+	} else if flags&FLAG_QUOTE_DOUBLE != 0 {
+		prefix = "\""
+	}
+	fingerprint := string(xpathTokenize(prefix + input))
+	return Result{Injection: xpathBlacklist(fingerprint), Fingerprint: fingerprint, Flags: flags}
+}
+
+/*
+ * DetectXPath checks input, a value put into an XPath query, for XPath
+ * injection. Like libinjection_is_sqli, the input is tried as is, then as
+ * if it were inside single or double quotes when it has some.
+ */
+func DetectXPath(input string) Result {
+	result := xpathCheck(input, FLAG_QUOTE_NONE)
+	if result.Injection {
+		return result
+	}
+	if strings.IndexByte(input, CHAR_SINGLE) != -1 {
+		if result = xpathCheck(input, FLAG_QUOTE_SINGLE); result.Injection {
+			return result
+		}
+	}
+	if strings.IndexByte(input, CHAR_DOUBLE) != -1 {
+		result = xpathCheck(input, FLAG_QUOTE_DOUBLE)
+	}
+	return result
+}
+
+/*
+ * DetectXPathFlags runs a single pass, for input known to be injected in
+ * the context given by flags: FLAG_QUOTE_NONE, FLAG_QUOTE_SINGLE or
+ * FLAG_QUOTE_DOUBLE.
+ */
+func DetectXPathFlags(input string, flags int) Result {
+	return xpathCheck(input, flags)
+}
+
+/*
+ * IsXPath tells if input is an XPath injection, with its fingerprint.
+ */
+func IsXPath(input string) (bool, string) {
+	result := DetectXPath(input)
+	if !result.Injection {
+		return false, ""
+	}
+	return true, result.Fingerprint
+}
@@ -0,0 +1,45 @@
+package libinjection
+
+import "testing"
+
+func TestDetectXPath(t *testing.T) {
+	tests := []struct {
+		input       string
+		injection   bool
+		fingerprint string
+		flags       int
+	}{
+		{"' or '1'='1", true, "s&s=s", FLAG_QUOTE_SINGLE},
+		{"x\" or \"a\"=\"a", true, "s&s=s", FLAG_QUOTE_DOUBLE},
+		{"'] | //user[", true, "s]|/n", FLAG_QUOTE_SINGLE},
+		{"'] | //*[contains(., 'admin')] | a['", true, "s]|/n", FLAG_QUOTE_SINGLE},
+		{"count(/*)", true, "f(/n)", FLAG_QUOTE_NONE},
+		{"1 or 1=1", true, "1&1=1", FLAG_QUOTE_NONE},
+		{"1] | //password", true, "1]|/n", FLAG_QUOTE_NONE},
+		{"' or true() or '", true, "s&f()", FLAG_QUOTE_SINGLE},
+		{"' and string-length(name(/*[1]))=4 and '", true, "s&f(f", FLAG_QUOTE_SINGLE},
+		{"'or(:x:)'1'='1", true, "s&s=s", FLAG_QUOTE_SINGLE},
+		{"admin'][1]/@pass | /a['", true, "s][1]", FLAG_QUOTE_SINGLE},
+		/* text */
+		{"John", false, "n", FLAG_QUOTE_NONE},
+		{"O'Brien", false, "sn", FLAG_QUOTE_SINGLE},
+		{"salt and pepper", false, "n&n", FLAG_QUOTE_NONE},
+		{"name (optional)", false, "f(n)", FLAG_QUOTE_NONE},
+		{"don't (really)", false, "sn(n)", FLAG_QUOTE_SINGLE},
+		{"42", false, "1", FLAG_QUOTE_NONE},
+		{"", false, "", FLAG_QUOTE_NONE},
+	}
+	for _, test := range tests {
+		result := DetectXPath(test.input)
+		if result.Injection != test.injection || result.Fingerprint != test.fingerprint || result.Flags != test.flags {
+			t.Errorf("%q: expected %v %q %d, got %+v", test.input, test.injection, test.fingerprint, test.flags, result)
+		}
+		is, fingerprint := IsXPath(test.input)
+		if is != test.injection || is && fingerprint != test.fingerprint {
+			t.Errorf("%q: IsXPath gives %v %q", test.input, is, fingerprint)
+		}
+	}
+	if result := DetectXPathFlags("' or '1'='1", FLAG_QUOTE_NONE); result.Injection {
+		t.Errorf("quote none: unexpected %+v", result)
+	}
+}
@@ -0,0 +1,394 @@
+package libinjection
+
+import "strings"
+
+const (
+	//attribute types
+	ATTR_TYPE_NONE     = 0
+	ATTR_TYPE_BLACK    = 1 /* ban always */
+	ATTR_TYPE_URL      = 2 /* attribute value takes a URL-like object */
+	ATTR_TYPE_STYLE    = 3
+	ATTR_TYPE_INDIRECT = 4 /* attribute *name* is given in *value* */
+)
+
+type stringtype struct {
+	name  string
+	atype int
+}
+
+var black_tags = []string{
+	"APPLET",
+	/*    ,"AUDIO" */
+	"BASE",
+	"COMMENT", /* IE http://html5sec.org/#38 */
+	"EMBED",
+	/*   ,  "FORM" */
+	"FRAME",
+	"FRAMESET",
+	"HANDLER", /* Opera SVG, effectively a script tag */
+	"IFRAME",
+	"IMPORT",
+	"ISINDEX",
+	"LINK",
+	"LISTENER",
+	/*    ,"MARQUEE" */
+	"META",
+	"NOSCRIPT",
+	"OBJECT",
+	"SCRIPT",
+	"STYLE",
+	/*    ,"VIDEO" */
+	"VMLFRAME",
+	"XML",
+	"XSS",
+}
+
+var black_attrs = []stringtype{
+	{"ACTION", ATTR_TYPE_URL},             /* form */
+	{"ATTRIBUTENAME", ATTR_TYPE_INDIRECT}, /* SVG allow indirection of attribute names */
+	{"BY", ATTR_TYPE_URL},                 /* SVG */
+	{"BACKGROUND", ATTR_TYPE_URL},         /* IE6, O11 */
+	{"DATAFORMATAS", ATTR_TYPE_BLACK},     /* IE */
+	{"DATASRC", ATTR_TYPE_BLACK},          /* IE */
+	{"DYNSRC", ATTR_TYPE_URL},             /* Obsolete img attribute */
+	{"FILTER", ATTR_TYPE_STYLE},           /* Opera, SVG inline style */
+	{"FORMACTION", ATTR_TYPE_URL},         /* HTML 5 */
+	{"FOLDER", ATTR_TYPE_URL},             /* Only on A tags, IE-only */
+	{"FROM", ATTR_TYPE_URL},               /* SVG */
+	{"HANDLER", ATTR_TYPE_URL},            /* SVG Tiny, Opera */
+	{"HREF", ATTR_TYPE_URL},
+	{"LOWSRC", ATTR_TYPE_URL}, /* Obsolete img attribute */
+	{"POSTER", ATTR_TYPE_URL}, /* Opera 10,11 */
+	{"SRC", ATTR_TYPE_URL},
+	{"STYLE", ATTR_TYPE_STYLE},
+	{"TO", ATTR_TYPE_URL},     /* SVG */
+	{"VALUES", ATTR_TYPE_URL}, /* SVG */
+	{"XLINK:HREF", ATTR_TYPE_URL},
+}
+
+/*
+ * Value of a hex digit, 256 if ch isn't one
+ */
+func hex_decode(ch byte) int {
+	switch {
+	case ch >= '0' && ch <= '9':
+		return int(ch - '0')
+	case ch >= 'a' && ch <= 'f':
+		return int(ch-'a') + 10
+	case ch >= 'A' && ch <= 'F':
+		return int(ch-'A') + 10
+	default:
+		return 256
+	}
+}
+
+/*
+ * Decodes the character at the start of s, only numeric html entities
+ * are decoded. Returns the character (CHAR_EOF if s is empty) and the
+ * number of bytes consumed.
+ */
+func html_decode_char_at(s string) (int, int) {
+	l := len(s)
+	if l == 0 {
+		return CHAR_EOF, 0
+	}
+
+	if s[0] != '&' || l < 2 {
+		return int(s[0]), 1
+	}
+
+	if s[1] != '#' {
+		/* normally this would be for named entities
+		 * but for this case we don't actually care
+		 */
+		return '&', 1
+	}
+
+	if char_at(s, 2) == 'x' || char_at(s, 2) == 'X' {
+		ch := hex_decode(char_at(s, 3))
+		if ch == 256 {
+			/* degenerate case  '&#[?]' */
+			return '&', 1
+		}
+		val := ch
+		i := 4
+		for i < l {
+			if s[i] == ';' {
+				return val, i + 1
+			}
+			ch = hex_decode(s[i])
+			if ch == 256 {
+				return val, i
+			}
+			val = (val * 16) + ch
+			if val > 0x1000FF {
+				return '&', 1
+			}
+			i += 1
+		}
+		return val, i
+	}
+
+	i := 2
+	ch := char_at(s, i)
+	if ch < '0' || ch > '9' {
+		return '&', 1
+	}
+	val := int(ch - '0')
+	i += 1
+	for i < l {
+		ch = s[i]
+		if ch == ';' {
+			return val, i + 1
+		}
+		if ch < '0' || ch > '9' {
+			return val, i
+		}
+		val = (val * 10) + int(ch-'0')
+		if val > 0x1000FF {
+			return '&', 1
+		}
+		i += 1
+	}
+	return val, i
+}
+
+/*
+ * Compares the upper case string a with b, ignoring case and any nulls in b.
+ */
+func cstrcasecmp_with_null(a string, b string) bool {
+	i := 0
+	for j := 0; j < len(b); j++ {
+		cb := b[j]
+		if cb == CHAR_NULL {
+			continue
+		}
+		if cb >= 'a' && cb <= 'z' {
+			cb -= 0x20
+		}
+		if i >= len(a) || a[i] != cb {
+			return false
+		}
+		i += 1
+	}
+	return i == len(a)
+}
+
+/*
+ * Does an HTML encoded string start with an all uppercase string a, case
+ * insensitive! Also ignore any embedded nulls in the HTML string!
+ */
+func htmlencode_startswith(a string, b string) bool {
+	first := true
+	i := 0
+	for len(b) > 0 {
+		if i == len(a) {
+			return true
+		}
+		cb, consumed := html_decode_char_at(b)
+		b = b[consumed:]
+
+		if first && cb <= 32 {
+			/* ignore all leading whitespace and control characters */
+			continue
+		}
+		first = false
+
+		if cb == 0 {
+			/* always ignore null characters in user input */
+			continue
+		}
+
+		if cb == 10 {
+			/* always ignore vertical tab characters in user input */
+			/* who allows this?? */
+			continue
+		}
+
+		if cb >= 'a' && cb <= 'z' {
+			/* upper case */
+			cb -= 0x20
+		}
+
+		if a[i] != byte(cb) {
+			return false
+		}
+		i += 1
+	}
+
+	return i == len(a)
+}
+
+func is_black_tag(s string) bool {
+	if len(s) < 3 {
+		return false
+	}
+
+	for _, black := range black_tags {
+		if cstrcasecmp_with_null(black, s) {
+			return true
+		}
+	}
+
+	/* anything SVG related */
+	if cstrcasecmp_with_null("SVG", s[:3]) {
+		return true
+	}
+
+	/* Anything XSL(t) related */
+	if cstrcasecmp_with_null("XSL", s[:3]) {
+		return true
+	}
+
+	return false
+}
+
+func is_black_attr(s string) int {
+	if len(s) < 2 {
+		return ATTR_TYPE_NONE
+	}
+
+	if len(s) >= 5 {
+		/* JavaScript on.* */
+		if (s[0] == 'o' || s[0] == 'O') && (s[1] == 'n' || s[1] == 'N') {
+			return ATTR_TYPE_BLACK
+		}
+
+		/* XMLNS can be used to create arbitrary tags */
+		if cstrcasecmp_with_null("XMLNS", s[:5]) || cstrcasecmp_with_null("XLINK", s[:5]) {
+			return ATTR_TYPE_BLACK
+		}
+	}
+
+	for _, black := range black_attrs {
+		if cstrcasecmp_with_null(black.name, s) {
+			return black.atype
+		}
+	}
+
+	return ATTR_TYPE_NONE
+}
+
+func is_black_url(s string) bool {
+	/*
+	 * Skip whitespace. The C version reads a signed char, so anything with
+	 * the high bit set is skipped too: Opera sometimes uses UTF-8
+	 * whitespace, and in EUC-JP some of the high bytes are just ignored.
+	 */
+	i := 0
+	for i < len(s) && (s[i] <= 32 || s[i] >= 127) {
+		i += 1
+	}
+	s = s[i:]
+
+	return htmlencode_startswith("DATA", s) || /* data url */
+		htmlencode_startswith("VIEW-SOURCE", s) || /* view source url */
+		htmlencode_startswith("VBSCRIPT", s) || /* obsolete but interesting signal */
+		htmlencode_startswith("JAVA", s) /* covers JAVA, JAVASCRIPT, + colon */
+}
+
+/*
+ * Is input XSS when injected in the given html5 context (DATA_STATE,
+ * VALUE_NO_QUOTE, ...)
+ */
+func libinjection_is_xss(s string, flags int) bool {
+	attr := ATTR_TYPE_NONE
+	h5 := newH5State(s, len(s), flags)
+
+	for h5.libinjection_h5_next() {
+		if h5.token_type != ATTR_VALUE {
+			attr = ATTR_TYPE_NONE
+		}
+
+		token := h5.token()
+		if h5.token_type == DOCTYPE {
+			return true
+		} else if h5.token_type == TAG_NAME_OPEN {
+			if is_black_tag(token) {
+				return true
+			}
+		} else if h5.token_type == ATTR_NAME {
+			attr = is_black_attr(token)
+		} else if h5.token_type == ATTR_VALUE {
+			/*
+			 * IE6,7,8 parsing works a bit differently so
+			 * a whole <script> or other black tag might be hiding
+			 * inside an attribute value under HTML 5 parsing
+			 * See http://html5sec.org/#102
+			 * to avoid doing a full reparse of the value, just
+			 * look for "<".  This probably need adjusting to
+			 * handle escaped characters
+			 */
+			switch attr {
+			case ATTR_TYPE_NONE:
+			case ATTR_TYPE_BLACK:
+				return true
+			case ATTR_TYPE_URL:
+				if is_black_url(token) {
+					return true
+				}
+			case ATTR_TYPE_STYLE:
+				return true
+			case ATTR_TYPE_INDIRECT:
+				/* an attribute name is specified in a _value_ */
+				if is_black_attr(token) != ATTR_TYPE_NONE {
+					return true
+				}
+			}
+			attr = ATTR_TYPE_NONE
+		} else if h5.token_type == TAG_COMMENT {
+			/* IE uses a "`" as a tag ending char */
+			if strings.IndexByte(token, '`') != -1 {
+				return true
+			}
+
+			/* IE conditional comment */
+			if len(token) > 3 {
+				if token[0] == '[' &&
+					(token[1] == 'i' || token[1] == 'I') &&
+					(token[2] == 'f' || token[2] == 'F') {
+					return true
+				}
+				if (token[0] == 'x' || token[0] == 'X') &&
+					(token[1] == 'm' || token[1] == 'M') &&
+					(token[2] == 'l' || token[2] == 'L') {
+					return true
+				}
+			}
+
+			if len(token) > 5 {
+				/*  IE <?import pseudo-tag */
+				if cstrcasecmp_with_null("IMPORT", token[:6]) {
+					return true
+				}
+
+				/*  XML Entity definition */
+				if cstrcasecmp_with_null("ENTITY", token[:6]) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+/*
+ * Returns the first html5 context (DATA_STATE, VALUE_NO_QUOTE, ...) in which
+ * s is XSS, or -1 if it isn't XSS in any of them.
+ */
+func libinjection_xss(s string) int {
+	for _, flags := range []int{DATA_STATE, VALUE_NO_QUOTE, VALUE_SINGLE_QUOTE, VALUE_DOUBLE_QUOTE, VALUE_BACK_QUOTE} {
+		if libinjection_is_xss(s, flags) {
+			return flags
+		}
+	}
+	return -1
+}
+
+/*
+ * IsXSS returns true if input is XSS when injected in any html5 context:
+ * text, or an unquoted, single, double or back quoted attribute value.
+ */
+func IsXSS(input string) bool {
+	return libinjection_xss(input) != -1
+}
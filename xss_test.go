@@ -0,0 +1,33 @@
+package libinjection
+
+import "testing"
+
+func TestIsXSS(t *testing.T) {
+	tests := []struct {
+		input string
+		xss   bool
+	}{
+		{"<script>alert(1);</script>", true},
+		{"><script>alert(1);</script>", true},
+		{"<img src=x onerror=alert(1)>", true},
+		{"\" onmouseover=\"alert(1)", true},
+		{"<a href=\"javascript:alert(1)\">x</a>", true},
+		{"<a href=\"&#106;avascript:alert(1)\">x</a>", true},
+		{"<a href=\" &#x4A;AVA&#x53;CRIPT:alert(1)\">x</a>", true},
+		{"<svg/onload=alert(1)>", true},
+		{"<div style=\"width: expression(alert(1))\">", true},
+		{"<!--[if IE]><script>alert(1)</script><![endif]-->", true},
+		{"<!DOCTYPE html>", true},
+		{"hello world", false},
+		{"<b>bold</b> and <i>italic</i>", false},
+		{"<a href=\"http://example.com/?q=java\">x</a>", false},
+		{"1 < 2 && 3 > 2", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		if xss := IsXSS(test.input); xss != test.xss {
+			t.Errorf("%q: expected %v, got %v", test.input, test.xss, xss)
+		}
+	}
+}
